@@ -10,13 +10,14 @@ import (
 	"egobot/internal/ai"
 	"egobot/internal/config"
 	"egobot/internal/processor"
+	"egobot/internal/watchlist"
 
 	"github.com/gin-gonic/gin"
 	"github.com/robfig/cron/v3"
 	"go.uber.org/fx"
 )
 
-func NewRouter() *gin.Engine {
+func NewRouter(proc *processor.Processor) *gin.Engine {
 	r := gin.Default()
 
 	// Health check endpoint for Railway
@@ -48,6 +49,7 @@ func NewRouter() *gin.Engine {
 				"GET /ping - Health check",
 				"GET /cron/status - Cron job status",
 				"POST /extract - Extract entities from PDF",
+				"GET /usage - Tokens used and estimated cost per run and per day",
 			},
 		})
 	})
@@ -70,38 +72,59 @@ func NewRouter() *gin.Engine {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing entities field (should be a JSON array)"})
 			return
 		}
-		var entities []string
-		if err := json.Unmarshal([]byte(entitiesStr), &entities); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entities JSON: " + err.Error()})
+		// Plain strings, typed entities and subjects are accepted, as in ENTITIES_TO_TRACK
+		entities, err := watchlist.Parse(entitiesStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entities: " + err.Error()})
 			return
 		}
 
+		// Optional filter on announcement kinds, e.g. ["dødsbo","konkursbo"]
+		var kinds []ai.Kind
+		if kindsStr := c.Request.FormValue("kinds"); kindsStr != "" {
+			var kindNames []string
+			if err := json.Unmarshal([]byte(kindsStr), &kindNames); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid kinds JSON: " + err.Error()})
+				return
+			}
+			for _, name := range kindNames {
+				kinds = append(kinds, ai.ParseKind(name))
+			}
+		}
+
+		// no_cache=true analyses the PDF again instead of returning a cached result
+		ctx := c.Request.Context()
+		if c.Request.FormValue("no_cache") == "true" {
+			ctx = ai.BypassCache(ctx)
+		}
+
 		// Pass the file (as multipart.File) and filename to the AI extractor
-		result, err := ai.ExtractEntitiesFromPDFFile(context.Background(), file, header.Filename, entities)
+		response, err := proc.Extractor().ExtractEntitiesFromPDFFile(ctx, file, header.Filename, entities)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		proc.Usage().Record(time.Now(), response.Usage)
+		result := response.Results.MarkPossible(proc.ConfidenceThreshold())
+		if len(kinds) > 0 {
+			result = result.Filter(kinds...)
+		}
 		c.JSON(http.StatusOK, result)
 	})
+
+	// Token usage of the last run and per day, including /extract requests
+	r.GET("/usage", func(c *gin.Context) {
+		c.JSON(http.StatusOK, proc.Usage().Report())
+	})
 	return r
 }
 
-func RunServer(lc fx.Lifecycle, router *gin.Engine) {
+func RunServer(lc fx.Lifecycle, router *gin.Engine, cfg *config.Config, proc *processor.Processor) {
 	server := &http.Server{
 		Addr:    ":8080",
 		Handler: router,
 	}
 
-	// Load configuration for cron job
-	cfg, err := config.Load()
-	if err != nil {
-		panic("Failed to load configuration: " + err.Error())
-	}
-
-	// Create processor for cron job
-	proc := processor.NewProcessor(cfg)
-
 	// Set up cron scheduler
 	scheduler := cron.New()
 
@@ -161,7 +184,7 @@ func RunServer(lc fx.Lifecycle, router *gin.Engine) {
 
 func main() {
 	app := fx.New(
-		fx.Provide(NewRouter),
+		fx.Provide(config.Load, processor.NewProcessor, NewRouter),
 		fx.Invoke(RunServer),
 	)
 	app.Run()
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"egobot/internal/config"
+	"egobot/internal/eval"
+	"egobot/internal/processor"
+)
+
+func main() {
+	// Parse command line flags
+	var (
+		dataset      = flag.String("dataset", "testdata/eval", "Directory with the labelled cases (*.json)")
+		output       = flag.String("out", "", "Write the report to this file instead of stdout")
+		asJSON       = flag.Bool("json", false, "Write the report as JSON instead of text")
+		minPrecision = flag.Float64("min-precision", 0, "Fail if the total precision is below this")
+		minRecall    = flag.Float64("min-recall", 0, "Fail if the total recall is below this")
+		timeout      = flag.Duration("timeout", 30*time.Minute, "Time limit for the whole evaluation")
+	)
+	flag.Parse()
+
+	// The extractor is configured like the processor, e.g. with EXTRACTOR_MODE, LLM_MODEL and PROMPT_DIR
+	cfg, err := config.LoadExtractor()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	extractor, err := processor.NewExtractor(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create extractor: %v", err)
+	}
+
+	cases, err := eval.LoadDataset(*dataset)
+	if err != nil {
+		log.Fatalf("Failed to load dataset: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	report := eval.Run(ctx, extractor, cases)
+
+	// Logs go to stderr, so the report on stdout can be redirected and diffed
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create report: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if *asJSON {
+		err = report.WriteJSON(w)
+	} else {
+		err = report.WriteText(w)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+
+	total := report.Total
+	log.Printf("Evaluated %d cases: precision %.3f, recall %.3f", len(cases), total.Precision, total.Recall)
+	if report.Failed() || total.Precision < *minPrecision || total.Recall < *minRecall {
+		fmt.Fprintln(os.Stderr, "Evaluation failed: an extraction failed or the scores are below the thresholds")
+		os.Exit(1)
+	}
+}
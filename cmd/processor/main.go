@@ -30,7 +30,10 @@ func main() {
 	}
 
 	// Create processor
-	proc := processor.NewProcessor(cfg)
+	proc, err := processor.NewProcessor(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create processor: %v", err)
+	}
 
 	// Create scheduler
 	schedulerConfig := &scheduler.Config{
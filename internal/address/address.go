@@ -0,0 +1,428 @@
+// Package address parses Danish addresses and finds them in free text, comparing
+// street, house number, floor and side, postnummer and by one component at a time.
+package address
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidAddress is returned when a string cannot be parsed as an address
+var ErrInvalidAddress = errors.New("invalid address")
+
+// MinScore is the lowest score at which an address found in text is considered a match
+const MinScore = 0.5
+
+// maxCityWords is the most words taken as the town after a postnummer, e.g. "Nykøbing F" or "Nørre Alslev"
+const maxCityWords = 3
+
+var (
+	houseNumberPattern = regexp.MustCompile(`^(\d{1,3})([A-Za-z])?$`)
+	postcodePattern    = regexp.MustCompile(`^\d{4}$`)
+	floorPattern       = regexp.MustCompile(`^(?:(st|kl)\.?|(\d{1,2})\.)(?:(tv|th|mf)\.?)?$`)
+	bareFloorPattern   = regexp.MustCompile(`^\d{1,2}$`)
+	sidePattern        = regexp.MustCompile(`^(?:(tv|th|mf)\.?|(\d{1,3}))$`)
+	tokenPattern       = regexp.MustCompile(`[^\s,;]+`)
+)
+
+// streetAbbreviations map the abbreviated endings of street names to their full form
+var streetAbbreviations = []struct{ short, long string }{
+	{"vj.", "vej"},
+	{"v.", "vej"},
+	{"gd.", "gade"},
+	{"str.", "stræde"},
+	{"g.", "gade"},
+	{"pl.", "plads"},
+	{"bvd.", "boulevard"},
+	{"boul.", "boulevard"},
+}
+
+// saintPrefixes are the abbreviations of "Sankt" that start street names like "Skt. Pedersstræde"
+var saintPrefixes = []string{"skt.", "skt", "sct.", "sct", "st."}
+
+// countries are trailing words of configured addresses that carry no information
+var countries = []string{"danmark", "denmark"}
+
+// Weights of each component in the score. The street and house number count the most,
+// as they identify the building; floor and side only single out an apartment.
+const (
+	weightStreet   = 4
+	weightNumber   = 3
+	weightPostcode = 2
+	weightCity     = 1
+	weightFloor    = 1
+	weightSide     = 1
+)
+
+// Address is a Danish address split into its components. Components that are not part
+// of the address are empty.
+type Address struct {
+	Street   string // Street name as written, e.g. "Lægårdsvej"
+	Number   string // House number with letter, e.g. "12A"
+	Floor    string // "st", "kl" or the floor number
+	Side     string // "tv", "th", "mf" or a door number
+	Postcode string // Four digit postnummer
+	City     string // Town, e.g. "Aarhus C"
+}
+
+// Match is an occurrence of an address in text
+type Match struct {
+	Address Address // The address as found in the text
+	Text    string  // The part of the text the address was found in
+	Score   float64 // How well it matches the address looked for, from 0 to 1
+}
+
+// token is a word of the text with its position
+type token struct {
+	text       string
+	start, end int
+	comma      bool // Followed by a comma or semicolon, which ends the component
+}
+
+// Parse parses an address such as "Lægårdsvej 12A, 2. th., 8000 Aarhus C". Every component
+// may be left out, but an address must have either a street or a postnummer.
+func Parse(s string) (Address, error) {
+	tokens := tokenize(s)
+	for len(tokens) > 0 && isCountry(tokens[len(tokens)-1].text) {
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	// The street name runs until the first word starting with a digit
+	i := 0
+	for i < len(tokens) && !startsWithDigit(tokens[i].text) {
+		i++
+	}
+	street := joinTokens(tokens[:i])
+
+	rest, n := parseRest(tokens[i:], i > 0 && tokens[i-1].comma)
+	i += n
+
+	// Configured addresses may name the town without a postnummer
+	if rest.Postcode == "" && i < len(tokens) && !startsWithDigit(tokens[i].text) {
+		rest.City = joinTokens(tokens[i:])
+		i = len(tokens)
+	}
+	if i < len(tokens) {
+		return Address{}, fmt.Errorf("%w: unexpected %q in %q", ErrInvalidAddress, tokens[i].text, s)
+	}
+
+	rest.Street = street
+	if rest.Street == "" && rest.Postcode == "" {
+		return Address{}, fmt.Errorf("%w: %q has neither a street nor a postnummer", ErrInvalidAddress, s)
+	}
+	return rest, nil
+}
+
+// String formats the address the way it is written in Statstidende
+func (a Address) String() string {
+	var parts []string
+	if street := strings.TrimSpace(a.Street + " " + a.Number); street != "" {
+		parts = append(parts, street)
+	}
+	if a.Floor != "" {
+		floor := a.Floor + "."
+		if a.Side != "" {
+			floor += " " + a.Side + "."
+		}
+		parts = append(parts, floor)
+	}
+	if town := strings.TrimSpace(a.Postcode + " " + a.City); town != "" {
+		parts = append(parts, town)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Compare scores how well the other address matches this one, from 0 to 1. Only the components
+// of this address count: those missing from the other address lower the score, while those that
+// differ rule out the match. The house number must be present when this address has one.
+func (a Address) Compare(other Address) float64 {
+	total, score := 0, 0
+
+	if a.Street != "" {
+		total += weightStreet
+		if streetKey(a.Street) != streetKey(other.Street) {
+			return 0
+		}
+		score += weightStreet
+	}
+
+	if a.Number != "" {
+		total += weightNumber
+		wantDigits, wantLetter := splitNumber(a.Number)
+		gotDigits, gotLetter := splitNumber(other.Number)
+		switch {
+		case wantDigits != gotDigits:
+			return 0
+		case wantLetter == gotLetter:
+			score += weightNumber
+		case wantLetter == "":
+			// 12A is in the building at number 12
+			score += weightNumber - 1
+		default:
+			return 0
+		}
+	}
+
+	for _, component := range []struct {
+		want, got string
+		weight    int
+		same      func(want, got string) bool
+	}{
+		{a.Postcode, other.Postcode, weightPostcode, equalFold},
+		{a.City, other.City, weightCity, sameCity},
+		{a.Floor, other.Floor, weightFloor, equalFold},
+		{a.Side, other.Side, weightSide, equalFold},
+	} {
+		if component.want == "" {
+			continue
+		}
+		total += component.weight
+		if component.got == "" {
+			continue
+		}
+		if !component.same(component.want, component.got) {
+			return 0
+		}
+		score += component.weight
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return float64(score) / float64(total)
+}
+
+// Find returns the best occurrence of the address in the text. It reports false when the address
+// doesn't occur with a score of at least MinScore.
+func (a Address) Find(text string) (Match, bool) {
+	tokens := tokenize(text)
+	var best Match
+
+	for i := range tokens {
+		street, n := "", 0
+		if a.Street != "" {
+			// The street may be split differently in the text, e.g. "Lægårds Vej"
+			n = matchStreet(tokens[i:], streetKey(a.Street))
+			if n == 0 {
+				continue
+			}
+			street = joinTokens(tokens[i : i+n])
+		} else if !postcodePattern.MatchString(tokens[i].text) {
+			continue
+		}
+
+		rest, m := parseRest(tokens[i+n:], n > 0 && tokens[i+n-1].comma)
+		rest.Street = street
+		if score := a.Compare(rest); score > best.Score {
+			last := tokens[i+n+m-1]
+			best = Match{Address: rest, Text: text[tokens[i].start:last.end], Score: score}
+		}
+	}
+
+	return best, best.Score >= MinScore
+}
+
+// parseRest parses the components following the street: house number, floor and side,
+// postnummer and town. It returns the components and the number of tokens consumed.
+// A comma right after the street means no house number follows.
+func parseRest(tokens []token, afterComma bool) (Address, int) {
+	var a Address
+	i := 0
+
+	if !afterComma && i < len(tokens) {
+		if m := houseNumberPattern.FindStringSubmatch(tokens[i].text); m != nil {
+			a.Number = m[1] + strings.ToUpper(m[2])
+			i++
+			// The letter may be written apart from the number, as in "12 A"
+			if m[2] == "" && !tokens[i-1].comma && i < len(tokens) && isLetter(tokens[i].text) {
+				a.Number += strings.ToUpper(tokens[i].text)
+				i++
+			}
+		}
+	}
+
+	if i < len(tokens) {
+		m := floorPattern.FindStringSubmatch(strings.ToLower(tokens[i].text))
+		if m == nil && a.Number != "" && tokens[i-1].comma && bareFloorPattern.MatchString(tokens[i].text) {
+			// The floor is often written without a dot, as in "Nordre Fasanvej 113, 2"
+			m = []string{tokens[i].text, "", tokens[i].text, ""}
+		}
+		if m != nil {
+			a.Floor = m[1] + m[2]
+			a.Side = m[3]
+			i++
+			if i < len(tokens) && strings.EqualFold(strings.TrimSuffix(tokens[i].text, "."), "sal") {
+				i++
+			}
+			if a.Side == "" && !tokens[i-1].comma && i < len(tokens) {
+				if m := sidePattern.FindStringSubmatch(strings.ToLower(tokens[i].text)); m != nil {
+					a.Side = m[1] + m[2]
+					i++
+				}
+			}
+		}
+	}
+
+	if i < len(tokens) && postcodePattern.MatchString(tokens[i].text) {
+		a.Postcode = tokens[i].text
+		i++
+
+		// The town is the capitalised words after the postnummer
+		var city []string
+		for i < len(tokens) && len(city) < maxCityWords && startsWithUpper(tokens[i].text) {
+			city = append(city, strings.TrimSuffix(tokens[i].text, "."))
+			i++
+			if tokens[i-1].comma || strings.HasSuffix(tokens[i-1].text, ".") {
+				break
+			}
+		}
+		a.City = strings.Join(city, " ")
+	}
+
+	return a, i
+}
+
+// matchStreet returns the number of tokens at the start that spell the street, or 0
+func matchStreet(tokens []token, key string) int {
+	var spelled strings.Builder
+	for i, t := range tokens {
+		if startsWithDigit(t.text) {
+			return 0
+		}
+		if streetKey(joinTokens(tokens[:i+1])) == key {
+			return i + 1
+		}
+		spelled.WriteString(normalizeWord(t.text, i == 0, false))
+		if t.comma || !strings.HasPrefix(key, spelled.String()) {
+			return 0
+		}
+	}
+	return 0
+}
+
+// streetKey normalises a street name for comparison: lower case without spaces or punctuation,
+// with abbreviations written out, so "Lægårdsv." and "Lægaards Vej" are the same street.
+func streetKey(street string) string {
+	words := strings.Fields(street)
+	var b strings.Builder
+	for i, word := range words {
+		b.WriteString(normalizeWord(word, i == 0, i == len(words)-1))
+	}
+	return b.String()
+}
+
+// normalizeWord normalises one word of a street name. The first word may be an abbreviation
+// of "Sankt", and the last may end in an abbreviated street type.
+func normalizeWord(word string, first, last bool) string {
+	w := strings.ToLower(strings.Trim(word, ",;:()"))
+	if first {
+		for _, prefix := range saintPrefixes {
+			if w == prefix {
+				return "sankt"
+			}
+		}
+	}
+	if last {
+		for _, abbreviation := range streetAbbreviations {
+			if strings.HasSuffix(w, abbreviation.short) {
+				w = strings.TrimSuffix(w, abbreviation.short) + abbreviation.long
+				break
+			}
+		}
+	}
+	return foldLetters(w)
+}
+
+// foldLetters drops punctuation and folds the older spellings "aa" and "é" to "å" and "e"
+func foldLetters(s string) string {
+	s = strings.ReplaceAll(s, "aa", "å")
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == 'é':
+			b.WriteRune('e')
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// sameCity reports whether two town names agree, allowing one to leave out the postal district
+// as in "Aarhus" and "Aarhus C"
+func sameCity(want, got string) bool {
+	want, got = cityKey(want)+" ", cityKey(got)+" "
+	return strings.HasPrefix(want, got) || strings.HasPrefix(got, want)
+}
+
+// cityKey normalises each word of a town name
+func cityKey(city string) string {
+	words := strings.Fields(strings.ToLower(city))
+	for i, word := range words {
+		words[i] = foldLetters(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// splitNumber splits a house number into its digits and letter
+func splitNumber(number string) (digits, letter string) {
+	i := strings.IndexFunc(number, func(r rune) bool { return !unicode.IsDigit(r) })
+	if i == -1 {
+		return number, ""
+	}
+	return number[:i], strings.ToUpper(number[i:])
+}
+
+// tokenize splits text into words, noting which are followed by a comma or semicolon
+func tokenize(text string) []token {
+	var tokens []token
+	for _, loc := range tokenPattern.FindAllStringIndex(text, -1) {
+		tokens = append(tokens, token{text: text[loc[0]:loc[1]], start: loc[0], end: loc[1]})
+	}
+	for i := range tokens {
+		if tokens[i].end < len(text) && strings.ContainsRune(",;", rune(text[tokens[i].end])) {
+			tokens[i].comma = true
+		}
+	}
+	return tokens
+}
+
+// joinTokens joins the words of tokens with single spaces
+func joinTokens(tokens []token) string {
+	words := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		words = append(words, t.text)
+	}
+	return strings.Join(words, " ")
+}
+
+func equalFold(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+func isCountry(word string) bool {
+	for _, country := range countries {
+		if strings.EqualFold(strings.TrimSuffix(word, "."), country) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLetter(s string) bool {
+	return len(s) == 1 && unicode.IsLetter(rune(s[0]))
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+func startsWithUpper(s string) bool {
+	for _, r := range s {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
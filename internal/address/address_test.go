@@ -0,0 +1,104 @@
+package address
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Address
+		wantErr  bool
+	}{
+		{input: "Lægårdsvej 12A", expected: Address{Street: "Lægårdsvej", Number: "12A"}},
+		{input: "Lægårdsvej 12 a, 8000 Aarhus C", expected: Address{Street: "Lægårdsvej", Number: "12A", Postcode: "8000", City: "Aarhus C"}},
+		{input: "Nordre Fasanvej 113, 2. th., 2000 Frederiksberg", expected: Address{Street: "Nordre Fasanvej", Number: "113", Floor: "2", Side: "th", Postcode: "2000", City: "Frederiksberg"}},
+		{input: "Husmandsvej 1, st.tv., 4990 Sakskøbing", expected: Address{Street: "Husmandsvej", Number: "1", Floor: "st", Side: "tv", Postcode: "4990", City: "Sakskøbing"}},
+		{input: "Skt. Pedersstræde 4, 1. sal, 1453 København K", expected: Address{Street: "Skt. Pedersstræde", Number: "4", Floor: "1", Postcode: "1453", City: "København K"}},
+		{input: "Husmandsvej 1, Sakskøbing Danmark", expected: Address{Street: "Husmandsvej", Number: "1", City: "Sakskøbing"}},
+		{input: "Nordre Fasanvej 113, 2, 2000 Frederiksberg", expected: Address{Street: "Nordre Fasanvej", Number: "113", Floor: "2", Postcode: "2000", City: "Frederiksberg"}},
+		{input: "Lægårdsvej, 8000 Aarhus C", expected: Address{Street: "Lægårdsvej", Postcode: "8000", City: "Aarhus C"}},
+		{input: "4990 Sakskøbing", expected: Address{Postcode: "4990", City: "Sakskøbing"}},
+		{input: "Lægårdsvej 12A 99", wantErr: true},
+		{input: "Danmark", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			address, err := Parse(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAddress) {
+					t.Errorf("Expected ErrInvalidAddress, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if address != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, address)
+			}
+		})
+	}
+}
+
+func TestAddressString(t *testing.T) {
+	address := Address{Street: "Nordre Fasanvej", Number: "113", Floor: "2", Side: "th", Postcode: "2000", City: "Frederiksberg"}
+	if got := address.String(); got != "Nordre Fasanvej 113, 2. th., 2000 Frederiksberg" {
+		t.Errorf("Unexpected formatting: %q", got)
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		text    string
+		found   bool
+		score   float64
+		match   string
+	}{
+		{name: "exact", address: "Lægårdsvej 12A, 8000 Aarhus C", text: "Afdøde boede Lægårdsvej 12A, 8000 Aarhus C.", found: true, score: 1, match: "Lægårdsvej 12A, 8000 Aarhus C."},
+		{name: "abbreviated street", address: "Lægårdsvej 12A", text: "Lægårdsv. 12 A, 8000 Aarhus C", found: true, score: 1, match: "Lægårdsv. 12 A, 8000 Aarhus C"},
+		{name: "old spelling and split street", address: "Lægårdsvej 12A", text: "Lægaards Vej 12A", found: true, score: 1},
+		{name: "town without postal district", address: "Lægårdsvej 12A, 8000 Aarhus C", text: "Lægårdsvej 12A, 8000 Aarhus", found: true, score: 1},
+		{name: "postnummer left out", address: "Lægårdsvej 12A, 8000 Aarhus C", text: "Lægårdsvej 12A", found: true, score: 0.7},
+		{name: "letter in the text only", address: "Husmandsvej 1", text: "Husmandsvej 1B, 4990 Sakskøbing", found: true, score: 6.0 / 7},
+		{name: "longer house number", address: "Husmandsvej 12", text: "Husmandsvej 120, 4990 Sakskøbing", found: false},
+		{name: "other house number", address: "Husmandsvej 12, 4990 Sakskøbing", text: "Husmandsvej 14, 4990 Sakskøbing", found: false},
+		{name: "other letter", address: "Lægårdsvej 12A", text: "Lægårdsvej 12B", found: false},
+		{name: "house number missing", address: "Lægårdsvej 12A", text: "Lægårdsvej er lukket", found: false},
+		{name: "longer street name", address: "Lægårdsvej 12A", text: "Lægårdsvejen 12A", found: false},
+		{name: "other postnummer", address: "Husmandsvej 1, 4990 Sakskøbing", text: "Husmandsvej 1, 4800 Nykøbing F", found: false},
+		{name: "other apartment", address: "Nordre Fasanvej 113, 2. th.", text: "Nordre Fasanvej 113, 2. tv., 2000 Frederiksberg", found: false},
+		{name: "floor without dot", address: "Nordre Fasanvej 113, 2, 2000 Frederiksberg", text: "ACEZONE ApS CVR-nr.: 39293056 Nordre Fasanvej 113, 2\n2000 Frederiksberg", found: true, score: 1},
+		{name: "floor and side", address: "Nordre Fasanvej 113, 2. th.", text: "Nordre Fasanvej 113, 2.th, 2000 Frederiksberg", found: true, score: 1},
+		{name: "best of several", address: "Husmandsvej 1, 4990 Sakskøbing", text: "Husmandsvej 1, 4800 Nykøbing F og Husmandsvej 1, 4990 Sakskøbing", found: true, score: 1, match: "Husmandsvej 1, 4990 Sakskøbing"},
+		{name: "postnummer only", address: "4990 Sakskøbing", text: "Husmandsvej 1, 4990 Sakskøbing", found: true, score: 1},
+		{name: "saint", address: "Sankt Pedersstræde 4", text: "Skt. Pedersstr. 4, 1453 København K", found: true, score: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, err := Parse(tt.address)
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", tt.address, err)
+			}
+			match, found := address.Find(tt.text)
+			if found != tt.found {
+				t.Fatalf("Expected found %v, got %v (%+v)", tt.found, found, match)
+			}
+			if !found {
+				return
+			}
+			if diff := match.Score - tt.score; diff > 0.001 || diff < -0.001 {
+				t.Errorf("Expected score %.2f, got %.2f", tt.score, match.Score)
+			}
+			if tt.match != "" && match.Text != tt.match {
+				t.Errorf("Expected match %q, got %q", tt.match, match.Text)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Package aitest provides a fake OpenAI Responses and Files API server for tests.
+//
+// Replies are queued up front and served in order, so a test can script a
+// sequence like "rate limited, then completed" and assert on what was sent.
+package aitest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Reply is a canned HTTP response served by the fake server
+type Reply struct {
+	Status int
+	Header http.Header
+	Body   string
+}
+
+// Request is a request received by the fake server
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// JSON decodes the request body into a generic map
+func (r Request) JSON() (map[string]interface{}, error) {
+	var body map[string]interface{}
+	err := json.Unmarshal(r.Body, &body)
+	return body, err
+}
+
+// Server is a fake Responses API backed by httptest.Server
+type Server struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	replies  []Reply
+	requests []Request
+}
+
+// NewServer starts a fake server that is closed when the test finishes.
+// Requests arriving when no reply is queued fail the test and get a 500.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Header: r.Header.Clone(),
+			Body:   body,
+		})
+		if len(s.replies) == 0 {
+			s.mu.Unlock()
+			t.Errorf("aitest: unexpected request %s %s, no reply queued", r.Method, r.URL.Path)
+			http.Error(w, "no reply queued", http.StatusInternalServerError)
+			return
+		}
+		reply := s.replies[0]
+		s.replies = s.replies[1:]
+		s.mu.Unlock()
+
+		for key, values := range reply.Header {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(reply.Status)
+		io.WriteString(w, reply.Body)
+	}))
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+// BaseURL returns the URL to configure as the OpenAI base URL, e.g. http://127.0.0.1:1234/v1
+func (s *Server) BaseURL() string {
+	return s.server.URL + "/v1"
+}
+
+// Enqueue adds replies to be served in order
+func (s *Server) Enqueue(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Completed returns a successful response whose output text is the given answer
+func Completed(model, answer string) Reply {
+	return Reply{Status: http.StatusOK, Body: responseBody("completed", model, answer, 0, 0)}
+}
+
+// CompletedWithUsage returns a successful response that reports the tokens it used
+func CompletedWithUsage(model, answer string, inputTokens, outputTokens int) Reply {
+	return Reply{Status: http.StatusOK, Body: responseBody("completed", model, answer, inputTokens, outputTokens)}
+}
+
+// CompletedJSON returns a successful response whose output text is the value marshalled as JSON
+func CompletedJSON(model string, answer interface{}) Reply {
+	data, err := json.Marshal(answer)
+	if err != nil {
+		panic(err)
+	}
+	return Completed(model, string(data))
+}
+
+// Incomplete returns a 200 response that stopped before completing, e.g. because of max_output_tokens
+func Incomplete(model, reason string) Reply {
+	response := map[string]interface{}{
+		"object": "response",
+		"status": "incomplete",
+		"model":  model,
+		"incomplete_details": map[string]interface{}{
+			"reason": reason,
+		},
+		"output": []interface{}{},
+	}
+	data, _ := json.Marshal(response)
+	return Reply{Status: http.StatusOK, Body: string(data)}
+}
+
+// APIError returns a 200 response carrying an error object
+func APIError(message string) Reply {
+	data, _ := json.Marshal(map[string]interface{}{
+		"object": "response",
+		"status": "failed",
+		"error": map[string]interface{}{
+			"code":    "server_error",
+			"message": message,
+		},
+	})
+	return Reply{Status: http.StatusOK, Body: string(data)}
+}
+
+// RateLimited returns a 429 response
+func RateLimited() Reply {
+	return Reply{
+		Status: http.StatusTooManyRequests,
+		Body:   `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`,
+	}
+}
+
+// ServerError returns an error response with the given status, usually 5xx
+func ServerError(status int) Reply {
+	return Reply{
+		Status: status,
+		Body:   `{"error":{"message":"The server had an error while processing your request","type":"server_error"}}`,
+	}
+}
+
+// FileUploaded returns a Files API response to an upload, with the ID of the new file
+func FileUploaded(id string) Reply {
+	data, _ := json.Marshal(map[string]interface{}{
+		"object":   "file",
+		"id":       id,
+		"purpose":  "user_data",
+		"filename": "upload.pdf",
+	})
+	return Reply{Status: http.StatusOK, Body: string(data)}
+}
+
+// FileDeleted returns a Files API response to the deletion of a file
+func FileDeleted(id string) Reply {
+	data, _ := json.Marshal(map[string]interface{}{
+		"object":  "file",
+		"id":      id,
+		"deleted": true,
+	})
+	return Reply{Status: http.StatusOK, Body: string(data)}
+}
+
+// Malformed returns a 200 response whose body is not valid JSON
+func Malformed() Reply {
+	return Reply{Status: http.StatusOK, Body: `{"object":"response","status":"comp`}
+}
+
+// responseBody builds a Responses API body with a single message output, and a usage block
+// if any tokens are given
+func responseBody(status, model, answer string, inputTokens, outputTokens int) string {
+	response := map[string]interface{}{
+		"object": "response",
+		"status": status,
+		"model":  model,
+		"output": []interface{}{
+			map[string]interface{}{
+				"type":   "message",
+				"role":   "assistant",
+				"status": status,
+				"content": []interface{}{
+					map[string]interface{}{
+						"type":        "output_text",
+						"text":        answer,
+						"annotations": []interface{}{},
+					},
+				},
+			},
+		},
+	}
+	if inputTokens > 0 || outputTokens > 0 {
+		response["usage"] = map[string]interface{}{
+			"input_tokens":  inputTokens,
+			"output_tokens": outputTokens,
+			"total_tokens":  inputTokens + outputTokens,
+		}
+	}
+	data, _ := json.Marshal(response)
+	return string(data)
+}
@@ -0,0 +1,185 @@
+package ai
+
+import (
+	"strings"
+	"time"
+
+	"egobot/internal/watchlist"
+)
+
+// Kind is the type of case a Statstidende announcement concerns
+type Kind string
+
+const (
+	KindDoedsbo       Kind = "dødsbo"        // Death estate
+	KindKonkursbo     Kind = "konkursbo"     // Bankruptcy estate
+	KindTvangsauktion Kind = "tvangsauktion" // Forced sale of real estate
+	KindOther         Kind = "other"         // Any other announcement
+)
+
+// Kinds lists all known announcement kinds in the order they appear in the gazette
+var Kinds = []Kind{KindDoedsbo, KindKonkursbo, KindTvangsauktion, KindOther}
+
+// ParseKind maps a case type as written by the model or in the gazette to a Kind
+func ParseKind(s string) Kind {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "dødsbo", "dødsboer", "doedsbo":
+		return KindDoedsbo
+	case "konkursbo", "konkursboer", "konkurs":
+		return KindKonkursbo
+	case "tvangsauktion", "tvangsauktioner":
+		return KindTvangsauktion
+	default:
+		return KindOther
+	}
+}
+
+// Announcement is a single kundgørelse in Statstidende.
+// Only the fields relevant for its Kind are populated.
+type Announcement struct {
+	Kind Kind   `json:"kind"`
+	ID   string `json:"id,omitempty"`   // Announcement number in Statstidende, e.g. S17072025-23
+	Name string `json:"name,omitempty"` // Deceased person or company name
+
+	// Dødsboer
+	CPR       string     `json:"cpr,omitempty"`
+	DeathDate *time.Time `json:"death_date,omitempty"`
+
+	// Konkursboer
+	CVR          string     `json:"cvr,omitempty"`
+	PetitionDate *time.Time `json:"petition_date,omitempty"`
+
+	// Tvangsauktioner
+	Matrikel string `json:"matrikel,omitempty"`
+
+	// Address of the deceased, the company or the property, depending on Kind
+	Address string `json:"address,omitempty"`
+
+	// Quote is the verbatim announcement text the information was taken from
+	Quote string `json:"quote,omitempty"`
+
+	// Where the announcement mentions the entity in the PDF, so a hit can be checked by hand
+	Page    int      `json:"page,omitempty"`
+	Line    int      `json:"line,omitempty"`    // 1-based line on the page
+	Context []string `json:"context,omitempty"` // The matching lines and the lines around them, within the announcement
+
+	// Strategy is the matching strategy that found the entity in the announcement text
+	Strategy MatchStrategy `json:"strategy,omitempty"`
+
+	// Verified is set when every reported value was found in the PDF text. Unverified lists
+	// the fields whose values were not found, which the model may have made up.
+	Verified   bool     `json:"verified"`
+	Unverified []string `json:"unverified,omitempty"`
+
+	// Confidence from 0 to 1 combines the strategy, the verification and the model's own
+	// assessment, see confidence. Possible is set below the configured threshold.
+	Confidence      float64  `json:"confidence"`
+	ModelConfidence *float64 `json:"model_confidence,omitempty"` // As reported by the model, if it was asked
+	Possible        bool     `json:"possible,omitempty"`
+}
+
+// Match links a watched entity to the announcements that concern it
+type Match struct {
+	Entity        string         `json:"entity"`
+	EntityKind    watchlist.Kind `json:"entity_kind,omitempty"`
+	Subject       string         `json:"subject,omitempty"` // Subject the entity belongs to on the watchlist
+	Prompt        string         `json:"prompt,omitempty"`  // Prompt template and version the entity was analysed with, e.g. advokat@1
+	Announcements []Announcement `json:"announcements"`
+}
+
+// Found reports whether any announcements concern the entity
+func (m Match) Found() bool {
+	return len(m.Announcements) > 0
+}
+
+// ExtractionResult holds one Match per requested entity, in the order the entities were requested
+type ExtractionResult []Match
+
+// Match returns the match for the given entity, if it was requested
+func (r ExtractionResult) Match(entity string) (Match, bool) {
+	for _, match := range r {
+		if match.Entity == entity {
+			return match, true
+		}
+	}
+	return Match{}, false
+}
+
+// Filter returns a copy of the result containing only announcements of the given kinds
+func (r ExtractionResult) Filter(kinds ...Kind) ExtractionResult {
+	filtered := make(ExtractionResult, 0, len(r))
+	for _, match := range r {
+		announcements := []Announcement{}
+		for _, announcement := range match.Announcements {
+			for _, kind := range kinds {
+				if announcement.Kind == kind {
+					announcements = append(announcements, announcement)
+					break
+				}
+			}
+		}
+		match.Announcements = announcements
+		filtered = append(filtered, match)
+	}
+	return filtered
+}
+
+// CountAnnouncements returns the total number of announcements across all matches
+func (r ExtractionResult) CountAnnouncements() int {
+	count := 0
+	for _, match := range r {
+		count += len(match.Announcements)
+	}
+	return count
+}
+
+// CountUnverified returns the number of announcements that could not be verified against the PDF text
+func (r ExtractionResult) CountUnverified() int {
+	count := 0
+	for _, match := range r {
+		for _, announcement := range match.Announcements {
+			if !announcement.Verified {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// CountPossible returns the number of announcements marked as possible matches
+func (r ExtractionResult) CountPossible() int {
+	count := 0
+	for _, match := range r {
+		for _, announcement := range match.Announcements {
+			if announcement.Possible {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// MarkPossible marks the announcements whose confidence is below the threshold as possible
+// matches, and returns the result
+func (r ExtractionResult) MarkPossible(threshold float64) ExtractionResult {
+	for i := range r {
+		for j := range r[i].Announcements {
+			r[i].Announcements[j].Possible = r[i].Announcements[j].Confidence < threshold
+		}
+	}
+	return r
+}
+
+// parseDanishDate parses dates as written in Statstidende (e.g. "14.03.2025" or "17-07-2025")
+func parseDanishDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	for _, layout := range []string{"02.01.2006", "02-01-2006", "2006-01-02", "2.1.2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
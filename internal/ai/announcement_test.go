@@ -0,0 +1,25 @@
+package ai
+
+import (
+	"testing"
+)
+
+func TestParseKind(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Kind
+	}{
+		{"dødsbo", KindDoedsbo},
+		{"Dødsboer", KindDoedsbo},
+		{"konkursbo", KindKonkursbo},
+		{"Tvangsauktioner", KindTvangsauktion},
+		{"andet", KindOther},
+		{"", KindOther},
+	}
+
+	for _, test := range tests {
+		if kind := ParseKind(test.input); kind != test.expected {
+			t.Errorf("ParseKind(%q) = %s, expected %s", test.input, kind, test.expected)
+		}
+	}
+}
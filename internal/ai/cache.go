@@ -0,0 +1,240 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"egobot/internal/watchlist"
+)
+
+// cacheVersion is part of every cache key. Bump it when the cached response format changes.
+const cacheVersion = "2"
+
+// CacheStore stores extraction results by key. Entries expire after their TTL.
+type CacheStore interface {
+	// Get returns the value stored under the key, and false if there is none or it has expired
+	Get(key string) ([]byte, bool, error)
+	// Set stores the value under the key for the given time
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// MemoryCache keeps cached results in memory, so they are lost when the process exits
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache creates an empty in-memory cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryEntry)}
+}
+
+// Get returns the value stored under the key, removing it if it has expired
+func (c *MemoryCache) Get(key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set stores the value under the key and removes expired entries
+func (c *MemoryCache) Set(key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = memoryEntry{value: value, expires: now.Add(ttl)}
+	return nil
+}
+
+// DiskCache keeps cached results as one JSON file per key in a directory, so they
+// survive restarts and can be shared by the server and the processor CLI
+type DiskCache struct {
+	dir string
+}
+
+type diskEntry struct {
+	Expires time.Time       `json:"expires"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// NewDiskCache creates a cache in the directory, creating it if needed
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory %s: %w", dir, err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+// Get reads the value stored under the key, removing the file if it has expired
+func (c *DiskCache) Get(key string) ([]byte, bool, error) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+
+	var entry diskEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false, fmt.Errorf("failed to parse cache entry %s: %w", path, err)
+	}
+	if time.Now().After(entry.Expires) {
+		os.Remove(path)
+		return nil, false, nil
+	}
+	return entry.Value, true, nil
+}
+
+// Set writes the value to a temporary file and renames it, so readers never see a partial entry
+func (c *DiskCache) Set(key string, value []byte, ttl time.Duration) error {
+	data, err := json.Marshal(diskEntry{Expires: time.Now().Add(ttl), Value: value})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	file, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return os.Rename(file.Name(), c.path(key))
+}
+
+// path returns the file of the key. Keys are hex digests, so they are safe file names.
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// bypassCacheKey marks contexts whose extractions must not use cached results
+type bypassCacheKey struct{}
+
+// BypassCache returns a context whose extractions ignore cached results. The fresh results
+// are still cached, replacing the old ones.
+func BypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+// cacheBypassed reports whether the context was created by BypassCache
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return bypass
+}
+
+// cacheKey identifies an extraction by the document, the entities with the prompt template
+// version used for each, and the model. Subjects only group the results, so they are left out.
+func (e *LLMExtractor) cacheKey(source string, entities watchlist.Watchlist) (string, error) {
+	items := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		tmpl, err := e.prompts.Get(entity.Prompt)
+		if err != nil {
+			return "", err
+		}
+		items = append(items, strings.Join([]string{string(entity.Kind), strings.ToLower(strings.TrimSpace(entity.Value)), tmpl.ID()}, "\x00"))
+	}
+	sort.Strings(items)
+
+	hash := sha256.New()
+	for _, part := range append([]string{cacheVersion, source, e.provider.Name(), e.provider.Model()}, items...) {
+		hash.Write([]byte(part))
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// contentSource identifies a document by the hash of its content
+func contentSource(kind string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return kind + ":" + hex.EncodeToString(sum[:])
+}
+
+// readerSource identifies a document by the hash of the content read from the reader, like
+// contentSource
+func readerSource(kind string, r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return kind + ":" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// cached returns the cached response for the document and entities if there is one, and
+// otherwise runs the extraction and caches its result. Failed extractions are not cached.
+func (e *LLMExtractor) cached(ctx context.Context, source string, entities watchlist.Watchlist, extract func() (ExtractionResponse, error)) (ExtractionResponse, error) {
+	if e.cache == nil {
+		return extract()
+	}
+	key, err := e.cacheKey(source, entities)
+	if err != nil {
+		// The extraction reports the unknown prompt template
+		return extract()
+	}
+
+	if cacheBypassed(ctx) {
+		log.Printf("Bypassing the cache for %s", source)
+	} else if data, ok, err := e.cache.Get(key); err != nil {
+		log.Printf("Failed to read the cache, analysing again: %v", err)
+	} else if ok {
+		var response ExtractionResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			log.Printf("Failed to decode the cached result, analysing again: %v", err)
+		} else {
+			log.Printf("Using the cached result for %s, no tokens spent", source)
+			// Put the results in the order and with the subjects of this request
+			response = mergeResponses(entities, []ExtractionResponse{response})
+			response.Usage = Usage{}
+			return response, nil
+		}
+	}
+
+	response, err := extract()
+	if err != nil {
+		return response, err
+	}
+	data, err := json.Marshal(response)
+	if err == nil {
+		err = e.cache.Set(key, data, e.cacheTTL)
+	}
+	if err != nil {
+		log.Printf("Failed to cache the result for %s: %v", source, err)
+	}
+	return response, nil
+}
@@ -0,0 +1,202 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"egobot/internal/ai/aitest"
+	"egobot/internal/pdf"
+	"egobot/internal/prompt"
+	"egobot/internal/watchlist"
+)
+
+func TestCacheStores(t *testing.T) {
+	disk, err := NewDiskCache(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatalf("NewDiskCache failed: %v", err)
+	}
+
+	for name, store := range map[string]CacheStore{"memory": NewMemoryCache(), "disk": disk} {
+		t.Run(name, func(t *testing.T) {
+			if _, ok, err := store.Get("missing"); ok || err != nil {
+				t.Errorf("Expected a miss, got %v, %v", ok, err)
+			}
+
+			if err := store.Set("fresh", []byte(`{"a":1}`), time.Hour); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if value, ok, err := store.Get("fresh"); !ok || err != nil || string(value) != `{"a":1}` {
+				t.Errorf("Expected the stored value, got %s, %v, %v", value, ok, err)
+			}
+
+			if err := store.Set("expired", []byte(`{}`), -time.Second); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if _, ok, _ := store.Get("expired"); ok {
+				t.Error("Expected the expired entry to be a miss")
+			}
+		})
+	}
+
+	// Expired files are removed when read
+	if _, err := os.Stat(disk.path("expired")); !os.IsNotExist(err) {
+		t.Errorf("Expected the expired entry to be removed, got %v", err)
+	}
+}
+
+func TestExtractEntitiesFromText_Cache(t *testing.T) {
+	deathDate := time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)
+	answer := matchesPayload{Matches: []matchPayload{{
+		Entity:         "Person Nummer2",
+		AnnouncementID: "S17072025-2",
+		CaseType:       "dødsbo",
+		Name:           "Person Nummer2",
+		DeathDate:      deathDate.Format("02.01.2006"),
+	}}}
+	data, _ := json.Marshal(answer)
+	reply := aitest.CompletedWithUsage("gpt-4o-mini", string(data), 1000, 10)
+	server := aitest.NewServer(t)
+	server.Enqueue(reply, reply, reply)
+
+	store, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithCache(store, time.Hour)
+	text := joinNotices(sampleNotices(3))
+	entities := watchlist.Watchlist{
+		{Kind: watchlist.KindPerson, Value: "Person Nummer1"},
+		{Kind: watchlist.KindPerson, Value: "Person Nummer2", Subject: "Client A"},
+	}
+
+	first, err := extractor.ExtractEntitiesFromText(context.Background(), text, entities)
+	if err != nil {
+		t.Fatalf("ExtractEntitiesFromText failed: %v", err)
+	}
+	if first.Usage.Requests != 1 {
+		t.Fatalf("Expected the first extraction to call the model, got %+v", first.Usage)
+	}
+
+	// The same entities in another order and under another subject hit the cache
+	reordered := watchlist.Watchlist{
+		{Kind: watchlist.KindPerson, Value: "Person Nummer2", Subject: "Client B"},
+		{Kind: watchlist.KindPerson, Value: "Person Nummer1"},
+	}
+	second, err := extractor.ExtractEntitiesFromText(context.Background(), text, reordered)
+	if err != nil {
+		t.Fatalf("ExtractEntitiesFromText failed: %v", err)
+	}
+	if len(server.Requests()) != 1 || second.Usage != (Usage{}) {
+		t.Fatalf("Expected a cached result without requests, got %d requests and %+v", len(server.Requests()), second.Usage)
+	}
+	if second.Results[0].Entity != "Person Nummer2" || second.Results[0].Subject != "Client B" {
+		t.Errorf("Expected the results in the order and with the subjects of the request, got %+v", second.Results)
+	}
+	if !reflect.DeepEqual(second.Results[0].Announcements, first.Results[1].Announcements) {
+		t.Errorf("Expected the cached announcements %+v, got %+v", first.Results[1].Announcements, second.Results[0].Announcements)
+	}
+
+	// Another prompt version, another document or a bypass call the model again
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "advokat.tmpl"), []byte(`{{define "version"}}3{{end}}{{.Task}}`), 0o644)
+	prompts, err := prompt.Load(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	extractor.WithPrompts(prompts)
+	if _, err := extractor.ExtractEntitiesFromText(context.Background(), text, entities); err != nil {
+		t.Fatalf("ExtractEntitiesFromText failed: %v", err)
+	}
+	if _, err := extractor.ExtractEntitiesFromText(context.Background(), joinNotices(sampleNotices(4)), entities); err != nil {
+		t.Fatalf("ExtractEntitiesFromText failed: %v", err)
+	}
+	if len(server.Requests()) != 3 {
+		t.Fatalf("Expected a new prompt version and a new document to miss the cache, got %d requests", len(server.Requests()))
+	}
+
+	server.Enqueue(reply)
+	bypassed, err := extractor.ExtractEntitiesFromText(BypassCache(context.Background()), text, entities)
+	if err != nil {
+		t.Fatalf("ExtractEntitiesFromText failed: %v", err)
+	}
+	if len(server.Requests()) != 4 || bypassed.Usage.Requests != 1 {
+		t.Errorf("Expected the bypass to call the model, got %d requests", len(server.Requests()))
+	}
+}
+
+func TestExtractEntitiesFromPDFURL_CacheByLayout(t *testing.T) {
+	pdfURL := servePDF(t)
+	server := aitest.NewServer(t)
+	server.Enqueue(aitest.Completed("gpt-4o-mini", `{"matches": []}`), aitest.Completed("gpt-4o-mini", `{"matches": []}`))
+
+	store := NewMemoryCache()
+	entities := watchlist.FromStrings([]string{"ACEZONE ApS"})
+	for _, layout := range []pdf.Layout{pdf.LayoutPlain, pdf.LayoutColumns, pdf.LayoutColumns} {
+		extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithCache(store, time.Hour).WithLayout(layout)
+		if _, err := extractor.ExtractEntitiesFromPDFURL(context.Background(), pdfURL, entities); err != nil {
+			t.Fatalf("ExtractEntitiesFromPDFURL failed with the %s layout: %v", layout, err)
+		}
+	}
+
+	// A result verified against the text of one layout is not served for the other
+	if len(server.Requests()) != 2 {
+		t.Errorf("Expected one request per layout, got %d", len(server.Requests()))
+	}
+}
+
+// endlessStream is a stream that never ends
+type endlessStream struct{}
+
+func (endlessStream) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestExtractEntitiesFromPDFFile_CacheTooLarge(t *testing.T) {
+	maxSize := pdf.MaxSize
+	pdf.MaxSize = 1 << 10
+	defer func() { pdf.MaxSize = maxSize }()
+
+	server := aitest.NewServer(t)
+	entities := watchlist.FromStrings([]string{"ACEZONE ApS"})
+	for _, fileUpload := range []bool{false, true} {
+		extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithCache(NewMemoryCache(), time.Hour).WithFileUpload(fileUpload)
+		for name, file := range map[string]io.Reader{
+			"reader": bytes.NewReader(make([]byte, pdf.MaxSize+1)),
+			"stream": endlessStream{},
+		} {
+			// The size is checked before the PDF is hashed, so a stream that never ends fails too
+			if _, err := extractor.ExtractEntitiesFromPDFFile(context.Background(), file, "large.pdf", entities); !errors.Is(err, pdf.ErrTooLarge) {
+				t.Errorf("Expected ErrTooLarge for a large %s with file upload %v, got %v", name, fileUpload, err)
+			}
+		}
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("Expected no requests, got %d", len(server.Requests()))
+	}
+}
+
+func TestExtractEntitiesFromText_CacheSkipsErrors(t *testing.T) {
+	server := aitest.NewServer(t)
+	server.Enqueue(aitest.ServerError(400))
+	server.Enqueue(aitest.Completed("gpt-4o-mini", `{"matches": []}`))
+
+	extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithCache(NewMemoryCache(), time.Hour)
+	text := joinNotices(sampleNotices(3))
+	entities := watchlist.FromStrings([]string{"Person Nummer1"})
+
+	if _, err := extractor.ExtractEntitiesFromText(context.Background(), text, entities); err == nil {
+		t.Fatal("Expected the extraction to fail")
+	}
+	if _, err := extractor.ExtractEntitiesFromText(context.Background(), text, entities); err != nil {
+		t.Fatalf("Expected the retry to call the model again, got %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cassette modes, selecting whether a CassetteProvider records or replays the model's answers
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+// ErrNoRecording is returned when replaying a request that was never recorded
+var ErrNoRecording = errors.New("no recorded answer for the request")
+
+// Interaction is a recorded request and the model's answer, stored as one JSON file per request
+type Interaction struct {
+	Recorded time.Time          `json:"recorded"`
+	Provider string             `json:"provider"`
+	Model    string             `json:"model"`
+	Request  recordedRequest    `json:"request"`
+	Response CompletionResponse `json:"response"`
+	// FileInputUnsupported records that the provider could not read the PDF from the URL
+	FileInputUnsupported bool `json:"file_input_unsupported,omitempty"`
+}
+
+// recordedRequest is the part of a CompletionRequest that decides the answer
+type recordedRequest struct {
+	Prompt  string      `json:"prompt"`
+	FileURL string      `json:"file_url,omitempty"`
+	File    string      `json:"file,omitempty"` // SHA-256 of an uploaded PDF, as the file ID differs per upload
+	Schema  *JSONSchema `json:"schema,omitempty"`
+}
+
+// CassetteProvider records the requests of another provider and the answers to a directory,
+// or replays recorded answers without calling the provider, so a run can be reproduced offline
+type CassetteProvider struct {
+	provider LLMProvider
+	dir      string
+	replay   bool
+
+	mu    sync.Mutex
+	files map[string]string // SHA-256 of the uploaded PDFs by file ID
+}
+
+// NewCassetteProvider wraps the provider, recording to or replaying from the directory.
+// When replaying, the provider only names the provider and model the recordings were made with.
+func NewCassetteProvider(provider LLMProvider, dir, mode string) (*CassetteProvider, error) {
+	if err := OpenCassette(dir, mode); err != nil {
+		return nil, err
+	}
+	return &CassetteProvider{provider: provider, dir: dir, replay: mode == CassetteReplay, files: make(map[string]string)}, nil
+}
+
+// OpenCassette creates the cassette directory to record to, or checks that the directory to
+// replay from exists
+func OpenCassette(dir, mode string) error {
+	switch mode {
+	case CassetteRecord:
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create cassette directory %s: %w", dir, err)
+		}
+	case CassetteReplay:
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("failed to open cassette directory: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown cassette mode: %s", mode)
+	}
+	return nil
+}
+
+// Name identifies the wrapped provider in logs
+func (p *CassetteProvider) Name() string {
+	return p.provider.Name()
+}
+
+// Model returns the model of the wrapped provider
+func (p *CassetteProvider) Model() string {
+	return p.provider.Model()
+}
+
+// Complete replays the recorded answer to the request, or sends it and records the answer.
+// Failed requests are not recorded, except for providers that can't read PDFs from a URL.
+func (p *CassetteProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	interaction := Interaction{
+		Provider: p.Name(),
+		Model:    p.Model(),
+		Request:  recordedRequest{Prompt: req.Prompt, FileURL: req.FileURL, Schema: req.Schema},
+	}
+	if req.FileID != "" {
+		p.mu.Lock()
+		interaction.Request.File = p.files[req.FileID]
+		p.mu.Unlock()
+	}
+	path, err := p.path(interaction)
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+
+	if p.replay {
+		return p.load(path, req)
+	}
+
+	completion, err := p.provider.Complete(ctx, req)
+	switch {
+	case errors.Is(err, ErrFileInputUnsupported):
+		interaction.FileInputUnsupported = true
+	case err != nil:
+		return completion, err
+	default:
+		interaction.Response = completion
+	}
+	interaction.Recorded = time.Now()
+
+	if err := writeInteraction(path, interaction); err != nil {
+		log.Printf("Failed to record the answer of %s: %v", p.Name(), err)
+	}
+	return completion, err
+}
+
+// UploadFile uploads the PDF with the wrapped provider, and remembers its content so requests
+// reading it are recorded by content. When replaying nothing is uploaded.
+func (p *CassetteProvider) UploadFile(ctx context.Context, filename string, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	uploader, ok := p.provider.(FileUploader)
+	if !ok {
+		return "", ErrFileInputUnsupported
+	}
+	fileID := "cassette-" + hash
+	if !p.replay {
+		var err error
+		if fileID, err = uploader.UploadFile(ctx, filename, data); err != nil {
+			return "", err
+		}
+	}
+
+	p.mu.Lock()
+	p.files[fileID] = hash
+	p.mu.Unlock()
+	return fileID, nil
+}
+
+// DeleteFile deletes an uploaded file with the wrapped provider, unless replaying
+func (p *CassetteProvider) DeleteFile(ctx context.Context, fileID string) error {
+	p.mu.Lock()
+	delete(p.files, fileID)
+	p.mu.Unlock()
+	uploader, ok := p.provider.(FileUploader)
+	if !ok {
+		return ErrFileInputUnsupported
+	}
+	if p.replay {
+		return nil
+	}
+	return uploader.DeleteFile(ctx, fileID)
+}
+
+// load returns the answer recorded for the request
+func (p *CassetteProvider) load(path string, req CompletionRequest) (CompletionResponse, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return CompletionResponse{}, fmt.Errorf("%w in %s (file URL %q, %d chars of prompt)", ErrNoRecording, p.dir, req.FileURL, len(req.Prompt))
+	}
+	if err != nil {
+		return CompletionResponse{}, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	var interaction Interaction
+	if err := json.Unmarshal(data, &interaction); err != nil {
+		return CompletionResponse{}, fmt.Errorf("failed to parse recording %s: %w", path, err)
+	}
+	if interaction.FileInputUnsupported {
+		return CompletionResponse{}, ErrFileInputUnsupported
+	}
+	log.Printf("Replaying the answer recorded %s from %s", interaction.Recorded.Format(time.RFC3339), path)
+	return interaction.Response, nil
+}
+
+// path returns the file of the interaction, named by a hash of the provider, the model and the
+// request, so the same request made on another day finds the recording
+func (p *CassetteProvider) path(interaction Interaction) (string, error) {
+	data, err := json.Marshal([]interface{}{interaction.Provider, interaction.Model, interaction.Request})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return filepath.Join(p.dir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// writeInteraction writes the interaction to a temporary file and renames it, like the disk cache
+func writeInteraction(path string, interaction Interaction) error {
+	data, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recording: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// CassetteTransport records the PDFs downloaded through it to the cassette directory, or serves
+// the recorded PDFs without using the network, so the downloads that verify the model's answers
+// are replayed with the answers
+type CassetteTransport struct {
+	transport http.RoundTripper
+	dir       string // Directory of the downloads within the cassette directory
+	replay    bool
+}
+
+// NewCassetteTransport wraps the transport, http.DefaultTransport if nil, recording to or
+// replaying from the directory
+func NewCassetteTransport(transport http.RoundTripper, dir, mode string) (*CassetteTransport, error) {
+	if err := OpenCassette(dir, mode); err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "downloads")
+	if mode == CassetteRecord {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create cassette directory %s: %w", dir, err)
+		}
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &CassetteTransport{transport: transport, dir: dir, replay: mode == CassetteReplay}, nil
+}
+
+// RoundTrip serves the recorded download of the URL, or downloads it and records the PDF once it
+// has been read to the end. Only successful downloads are recorded.
+func (t *CassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sum := sha256.Sum256([]byte(req.URL.String()))
+	path := filepath.Join(t.dir, hex.EncodeToString(sum[:])+".pdf")
+	if t.replay {
+		return t.load(path, req)
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	file, err := os.CreateTemp(t.dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		log.Printf("Failed to record the download of %s: %v", req.URL, err)
+		return resp, nil
+	}
+	resp.Body = &recordingBody{body: resp.Body, file: file, path: path}
+	return resp, nil
+}
+
+// load returns the recorded download as a response. A download that was never recorded is
+// answered with 404 rather than an error, so it is not retried.
+func (t *CassetteTransport) load(path string, req *http.Request) (*http.Response, error) {
+	resp := &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		body := fmt.Sprintf("no recorded download of %s in %s", req.URL, t.dir)
+		resp.StatusCode, resp.Status = http.StatusNotFound, "404 Not Found"
+		resp.Body, resp.ContentLength = io.NopCloser(strings.NewReader(body)), int64(len(body))
+		return resp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	log.Printf("Replaying the download of %s from %s", req.URL, path)
+	resp.StatusCode, resp.Status = http.StatusOK, "200 OK"
+	resp.Header.Set("Content-Type", "application/pdf")
+	resp.Body, resp.ContentLength = file, info.Size()
+	return resp, nil
+}
+
+// recordingBody copies a response body to a temporary file as it is read, and renames the file
+// to the recording when the body has been read to the end. A body closed before is not recorded.
+type recordingBody struct {
+	body io.ReadCloser
+	file *os.File
+	path string
+	err  error // First error writing the recording
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if b.err == nil && n > 0 {
+		_, b.err = b.file.Write(p[:n])
+	}
+	if err == io.EOF && b.file != nil {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	if b.file != nil {
+		b.file.Close()
+		os.Remove(b.file.Name())
+		b.file = nil
+	}
+	return b.body.Close()
+}
+
+// finish closes the temporary file and renames it to the recording
+func (b *recordingBody) finish() {
+	name := b.file.Name()
+	if err := b.file.Close(); b.err == nil {
+		b.err = err
+	}
+	b.file = nil
+	if b.err == nil {
+		b.err = os.Rename(name, b.path)
+	}
+	if b.err != nil {
+		os.Remove(name)
+		log.Printf("Failed to record the download to %s: %v", b.path, b.err)
+	}
+}
@@ -0,0 +1,176 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"egobot/internal/ai/aitest"
+	"egobot/internal/watchlist"
+)
+
+func TestCassetteProvider_RecordAndReplay(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cassettes")
+	answer := `{"matches":[{"entity":"Person Nummer2","announcement_id":"S17072025-2","case_type":"dødsbo","name":"Person Nummer2"}]}`
+	server := aitest.NewServer(t)
+	server.Enqueue(aitest.CompletedWithUsage("gpt-4o-mini-2024-07-18", answer, 1000, 20))
+
+	recorder, err := NewCassetteProvider(newTestResponsesProvider(server), dir, CassetteRecord)
+	if err != nil {
+		t.Fatalf("NewCassetteProvider failed: %v", err)
+	}
+	text := joinNotices(sampleNotices(3))
+	entities := watchlist.FromStrings([]string{"Person Nummer2"})
+	recorded, err := NewLLMExtractor(recorder).ExtractEntitiesFromText(context.Background(), text, entities)
+	if err != nil {
+		t.Fatalf("ExtractEntitiesFromText failed: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v", files)
+	}
+
+	// Replaying doesn't call the model, which would fail as no reply is queued
+	player, err := NewCassetteProvider(newTestResponsesProvider(server), dir, CassetteReplay)
+	if err != nil {
+		t.Fatalf("NewCassetteProvider failed: %v", err)
+	}
+	replayed, err := NewLLMExtractor(player).ExtractEntitiesFromText(context.Background(), text, entities)
+	if err != nil {
+		t.Fatalf("ExtractEntitiesFromText failed: %v", err)
+	}
+	if len(server.Requests()) != 1 {
+		t.Errorf("Expected the replay not to call the model, got %d requests", len(server.Requests()))
+	}
+	if replayed.RawResponse != recorded.RawResponse || replayed.Usage != recorded.Usage {
+		t.Errorf("Expected the recorded answer %+v, got %+v", recorded, replayed)
+	}
+
+	// Another document was never recorded
+	_, err = NewLLMExtractor(player).ExtractEntitiesFromText(context.Background(), joinNotices(sampleNotices(4)), entities)
+	if !errors.Is(err, ErrNoRecording) {
+		t.Errorf("Expected ErrNoRecording, got %v", err)
+	}
+}
+
+func TestCassetteProvider_FileInputUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	req := CompletionRequest{Prompt: "Analyser denne udgave af statstidende", FileURL: "https://example.com/statstidende.pdf"}
+
+	recorder, _ := NewCassetteProvider(NewChatCompletionsProvider("http://localhost:11434/v1", "", "llama3.1"), dir, CassetteRecord)
+	if _, err := recorder.Complete(context.Background(), req); !errors.Is(err, ErrFileInputUnsupported) {
+		t.Fatalf("Expected ErrFileInputUnsupported, got %v", err)
+	}
+
+	// The fallback to the text pipeline is replayed too
+	player, _ := NewCassetteProvider(NewChatCompletionsProvider("http://localhost:11434/v1", "", "llama3.1"), dir, CassetteReplay)
+	if _, err := player.Complete(context.Background(), req); !errors.Is(err, ErrFileInputUnsupported) {
+		t.Errorf("Expected the replay to return ErrFileInputUnsupported, got %v", err)
+	}
+	if _, err := player.UploadFile(context.Background(), "a.pdf", []byte("%PDF-1.4")); !errors.Is(err, ErrFileInputUnsupported) {
+		t.Errorf("Expected uploads to be unsupported, got %v", err)
+	}
+}
+
+func TestCassetteProvider_UploadedFile(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("%PDF-1.4 sample")
+	server := aitest.NewServer(t)
+	server.Enqueue(aitest.FileUploaded("file-abc"), aitest.Completed("gpt-4o-mini", `{"matches":[]}`), aitest.FileDeleted("file-abc"))
+
+	recorder, _ := NewCassetteProvider(newTestResponsesProvider(server), dir, CassetteRecord)
+	fileID, err := recorder.UploadFile(context.Background(), "a.pdf", data)
+	if err != nil || fileID != "file-abc" {
+		t.Fatalf("Expected the upload to be sent, got %q, %v", fileID, err)
+	}
+	if _, err := recorder.Complete(context.Background(), CompletionRequest{Prompt: "Analyser", FileID: fileID}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if err := recorder.DeleteFile(context.Background(), fileID); err != nil {
+		t.Fatalf("DeleteFile failed: %v", err)
+	}
+
+	// The replay finds the recording by the content of the PDF, as the file ID differs per upload
+	player, _ := NewCassetteProvider(newTestResponsesProvider(server), dir, CassetteReplay)
+	fileID, err = player.UploadFile(context.Background(), "b.pdf", data)
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	if completion, err := player.Complete(context.Background(), CompletionRequest{Prompt: "Analyser", FileID: fileID}); err != nil || completion.Text != `{"matches":[]}` {
+		t.Errorf("Expected the recorded answer, got %+v, %v", completion, err)
+	}
+	if err := player.DeleteFile(context.Background(), fileID); err != nil {
+		t.Errorf("DeleteFile failed: %v", err)
+	}
+	if len(server.Requests()) != 3 {
+		t.Errorf("Expected the replay not to call the API, got %d requests", len(server.Requests()))
+	}
+}
+
+func TestNewCassetteProvider_Errors(t *testing.T) {
+	provider := NewChatCompletionsProvider("http://localhost:11434/v1", "", "llama3.1")
+	if _, err := NewCassetteProvider(provider, filepath.Join(t.TempDir(), "missing"), CassetteReplay); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected an error for a missing cassette directory, got %v", err)
+	}
+	if _, err := NewCassetteProvider(provider, t.TempDir(), "rewind"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestCassetteTransport_RecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	data := readSamplePDF(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/statstidende.pdf" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+
+	recorder, err := NewCassetteTransport(nil, dir, CassetteRecord)
+	if err != nil {
+		t.Fatalf("NewCassetteTransport failed: %v", err)
+	}
+	client := &http.Client{Transport: recorder}
+	if _, err := downloadPDF(context.Background(), client, server.URL+"/statstidende.pdf"); err != nil {
+		t.Fatalf("downloadPDF failed: %v", err)
+	}
+	// Failed downloads are not recorded
+	if _, err := downloadPDF(context.Background(), client, server.URL+"/missing.pdf"); err == nil {
+		t.Fatal("Expected the missing PDF to fail")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "downloads", "*")); len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v", files)
+	}
+
+	// Replaying doesn't use the network
+	server.Close()
+	player, err := NewCassetteTransport(nil, dir, CassetteReplay)
+	if err != nil {
+		t.Fatalf("NewCassetteTransport failed: %v", err)
+	}
+	client = &http.Client{Transport: player}
+	replayed, err := downloadPDF(context.Background(), client, server.URL+"/statstidende.pdf")
+	if err != nil {
+		t.Fatalf("downloadPDF failed: %v", err)
+	}
+	if !bytes.Equal(replayed, data) {
+		t.Errorf("Expected the recorded PDF, got %d bytes", len(replayed))
+	}
+
+	// A download that was never recorded fails without being retried
+	started := time.Now()
+	if _, err := downloadPDF(context.Background(), client, server.URL+"/other.pdf"); err == nil || !strings.Contains(err.Error(), "HTTP 404 - no recorded download") {
+		t.Errorf("Expected no recorded download, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the missing recording not to be retried, took %v", elapsed)
+	}
+}
@@ -0,0 +1,195 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"egobot/internal/httpretry"
+)
+
+// ChatCompletionsProvider talks to an OpenAI-compatible chat completions endpoint,
+// e.g. a local Ollama, vLLM or LM Studio server, so documents never leave our network
+type ChatCompletionsProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+	retry   httpretry.Policy
+}
+
+// NewChatCompletionsProvider creates a provider for an OpenAI-compatible server.
+// The API key is optional, as most local servers don't require one.
+func NewChatCompletionsProvider(baseURL, apiKey, model string) *ChatCompletionsProvider {
+	return &ChatCompletionsProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client: &http.Client{
+			// Local models are considerably slower than the hosted API
+			Timeout: 5 * time.Minute,
+		},
+		retry: httpretry.Default,
+	}
+}
+
+// Name identifies the provider in logs
+func (p *ChatCompletionsProvider) Name() string {
+	return "OpenAI-compatible"
+}
+
+// Model returns the model the provider sends requests to
+func (p *ChatCompletionsProvider) Model() string {
+	return p.model
+}
+
+// Complete sends the prompt to the chat completions endpoint
+func (p *ChatCompletionsProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	if req.FileURL != "" || req.FileID != "" {
+		return CompletionResponse{}, ErrFileInputUnsupported
+	}
+
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+
+	return completeChat(ctx, p.client, p.Name(), p.baseURL+"/chat/completions", headers, p.model, req, p.retry)
+}
+
+// AzureOpenAIProvider talks to an Azure OpenAI chat completions deployment
+type AzureOpenAIProvider struct {
+	endpoint   string
+	deployment string
+	apiVersion string
+	apiKey     string
+	client     *http.Client
+	retry      httpretry.Policy
+}
+
+// DefaultAzureAPIVersion is used when no API version is configured
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// NewAzureOpenAIProvider creates a provider for an Azure OpenAI deployment
+func NewAzureOpenAIProvider(endpoint, deployment, apiVersion, apiKey string) *AzureOpenAIProvider {
+	if apiVersion == "" {
+		apiVersion = DefaultAzureAPIVersion
+	}
+	return &AzureOpenAIProvider{
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		deployment: deployment,
+		apiVersion: apiVersion,
+		apiKey:     apiKey,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		retry: httpretry.Default,
+	}
+}
+
+// Name identifies the provider in logs
+func (p *AzureOpenAIProvider) Name() string {
+	return "Azure OpenAI"
+}
+
+// Model returns the deployment the provider sends requests to
+func (p *AzureOpenAIProvider) Model() string {
+	return p.deployment
+}
+
+// Complete sends the prompt to the Azure OpenAI deployment
+func (p *AzureOpenAIProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	if req.FileURL != "" || req.FileID != "" {
+		return CompletionResponse{}, ErrFileInputUnsupported
+	}
+
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		p.endpoint, url.PathEscape(p.deployment), url.QueryEscape(p.apiVersion))
+	headers := map[string]string{
+		"api-key": p.apiKey,
+	}
+
+	// The deployment determines the model, but the field is harmless to send
+	return completeChat(ctx, p.client, p.Name(), endpoint, headers, p.deployment, req, p.retry)
+}
+
+// completeChat sends a single user message to a chat completions endpoint and returns the answer
+func completeChat(ctx context.Context, client *http.Client, name, endpoint string, headers map[string]string, model string, req CompletionRequest, retry httpretry.Policy) (CompletionResponse, error) {
+	requestBody := map[string]interface{}{
+		"model": model,
+		"messages": []map[string]interface{}{
+			{
+				"role":    "user",
+				"content": req.Prompt,
+			},
+		},
+	}
+	if req.Schema != nil {
+		requestBody["response_format"] = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   req.Schema.Name,
+				"strict": true,
+				"schema": req.Schema.Schema,
+			},
+		}
+	}
+
+	body, err := postJSON(ctx, client, name, endpoint, headers, requestBody, retry)
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+
+	return parseChatCompletionBody(body)
+}
+
+// parseChatCompletionBody extracts the answer text from a chat completions response body
+func parseChatCompletionBody(body []byte) (CompletionResponse, error) {
+	var response struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+				Refusal string `json:"refusal"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+		Error interface{} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return CompletionResponse{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if response.Error != nil {
+		return CompletionResponse{}, fmt.Errorf("API returned error: %v", response.Error)
+	}
+	if len(response.Choices) == 0 {
+		return CompletionResponse{}, fmt.Errorf("no choices in response")
+	}
+
+	choice := response.Choices[0]
+	if choice.Message.Refusal != "" {
+		return CompletionResponse{}, fmt.Errorf("model refused to answer: %s", choice.Message.Refusal)
+	}
+	if choice.FinishReason == "length" {
+		return CompletionResponse{}, fmt.Errorf("response not completed, finish reason: %s", choice.FinishReason)
+	}
+
+	log.Printf("Received answer, length: %d", len(choice.Message.Content))
+
+	return CompletionResponse{
+		Text:         choice.Message.Content,
+		Model:        response.Model,
+		InputTokens:  response.Usage.PromptTokens,
+		OutputTokens: response.Usage.CompletionTokens,
+	}, nil
+}
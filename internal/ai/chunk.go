@@ -0,0 +1,238 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"egobot/internal/gazette"
+	"egobot/internal/watchlist"
+)
+
+// charsPerToken is a conservative estimate of characters per token for Danish gazette text,
+// which has many numbers and names that split into short tokens
+const charsPerToken = 3
+
+// chunkShare is the share of the context window filled with announcements. The rest is left
+// for the instructions and the answer, which repeats the relevant details of each match.
+const chunkShare = 0.5
+
+// maxChunkTokens caps the chunks of models with very large context windows, as the model
+// misses more details in long inputs and a failed request costs more to repeat
+const maxChunkTokens = 30000
+
+// minChunkTokens keeps chunks useful for models with very small context windows
+const minChunkTokens = 1000
+
+// maxConcurrentChunks is the number of chunks sent to the provider at the same time
+const maxConcurrentChunks = 4
+
+// defaultContextWindow is assumed for models that are not known, e.g. self-hosted models
+const defaultContextWindow = 8192
+
+// modelContextWindows are the context windows in tokens of known models, by model name prefix
+var modelContextWindows = map[string]int{
+	"gpt-5":         400000,
+	"gpt-4.1":       1047576,
+	"gpt-4o":        128000,
+	"gpt-4-turbo":   128000,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 16385,
+	"o1":            200000,
+	"o3":            200000,
+	"o4-mini":       200000,
+	"llama3.1":      128000,
+	"llama3.2":      128000,
+	"llama3.3":      128000,
+	"llama3":        8192,
+	"qwen2.5":       32768,
+	"mistral":       32768,
+	"gemma2":        8192,
+}
+
+// ContextWindow returns the context window in tokens of the model, matching the longest known
+// prefix of its name. Unknown models get a small default window.
+func ContextWindow(model string) int {
+	model = strings.ToLower(model)
+	window, longest := defaultContextWindow, 0
+	for prefix, tokens := range modelContextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			window, longest = tokens, len(prefix)
+		}
+	}
+	return window
+}
+
+// estimateTokens estimates the number of tokens in the text
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// chunkTokens returns the number of tokens of announcements that fit in one request,
+// given the tokens already taken by the instructions
+func (e *LLMExtractor) chunkTokens(promptTokens int) int {
+	tokens := int(float64(e.contextWindow)*chunkShare) - promptTokens
+	if tokens > maxChunkTokens {
+		tokens = maxChunkTokens
+	}
+	if tokens < minChunkTokens {
+		tokens = minChunkTokens
+	}
+	return tokens
+}
+
+// chunkNotices splits the announcements into chunks of at most maxTokens, keeping announcements
+// whole. Announcements that are too long on their own are split on line boundaries.
+func chunkNotices(notices []gazette.Notice, maxTokens int, format func([]gazette.Notice) string) [][]gazette.Notice {
+	var chunks [][]gazette.Notice
+	var current []gazette.Notice
+	currentTokens := 0
+
+	for _, notice := range notices {
+		for _, part := range splitNotice(notice, maxTokens, format) {
+			tokens := estimateTokens(format([]gazette.Notice{part}))
+			if len(current) > 0 && currentTokens+tokens > maxTokens {
+				chunks = append(chunks, current)
+				current, currentTokens = nil, 0
+			}
+			current = append(current, part)
+			currentTokens += tokens
+		}
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// splitNotice splits an announcement that doesn't fit in a chunk into parts of whole lines
+func splitNotice(notice gazette.Notice, maxTokens int, format func([]gazette.Notice) string) []gazette.Notice {
+	if estimateTokens(format([]gazette.Notice{notice})) <= maxTokens {
+		return []gazette.Notice{notice}
+	}
+	log.Printf("Announcement %s is longer than %d tokens, splitting it on line boundaries", notice.ID, maxTokens)
+
+	var parts []gazette.Notice
+	part := gazette.Notice{Section: notice.Section, Heading: notice.Heading, ID: notice.ID}
+	for _, line := range notice.Lines {
+		if len(part.Lines) > 0 && estimateTokens(format([]gazette.Notice{part}))+estimateTokens(line.Text) > maxTokens {
+			parts = append(parts, part)
+			part.Lines = nil
+		}
+		part.Lines = append(part.Lines, line)
+	}
+	return append(parts, part)
+}
+
+// extractChunks splits the announcements into chunks that fit the model's context window,
+// sends the chunks concurrently and merges the answers into a single response. The prompt
+// function builds the full prompt for a chunk.
+func (e *LLMExtractor) extractChunks(ctx context.Context, notices []gazette.Notice, entities watchlist.Watchlist, format func([]gazette.Notice) string, prompt func(text string) string) (ExtractionResponse, error) {
+	maxTokens := e.chunkTokens(estimateTokens(prompt("")))
+	chunks := chunkNotices(notices, maxTokens, format)
+	log.Printf("Split %d announcements into %d chunks of at most %d tokens (context window %d)", len(notices), len(chunks), maxTokens, e.contextWindow)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	responses := make([]ExtractionResponse, len(chunks))
+	errs := make([]error, len(chunks))
+	semaphore := make(chan struct{}, maxConcurrentChunks)
+	var wg sync.WaitGroup
+
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk []gazette.Notice) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			responses[i], errs[i] = e.requestMatches(ctx, CompletionRequest{Prompt: prompt(format(chunk))}, entities)
+			if errs[i] != nil {
+				// The result would be incomplete, so don't spend tokens on the remaining chunks
+				cancel()
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	// Report the chunk that failed rather than those cancelled because of it
+	var firstErr error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("chunk %d of %d: %w", i+1, len(chunks), err)
+		if firstErr == nil || (errors.Is(firstErr, context.Canceled) && !errors.Is(err, context.Canceled)) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return ExtractionResponse{}, firstErr
+	}
+
+	return mergeResponses(entities, responses), nil
+}
+
+// mergeResponses merges the answers for each chunk or prompt into one response with one match
+// per entity, dropping announcements that were reported more than once
+func mergeResponses(entities watchlist.Watchlist, responses []ExtractionResponse) ExtractionResponse {
+	result := emptyResult(entities)
+	seen := make(map[string]bool)
+	var raw []string
+	var usage Usage
+
+	for _, response := range responses {
+		raw = append(raw, response.RawResponse)
+		usage = usage.Add(response.Usage)
+		for _, match := range response.Results {
+			index := -1
+			for i := range result {
+				if sameEntity(result[i], match.Entity) {
+					index = i
+					break
+				}
+			}
+			if index == -1 {
+				result = append(result, Match{Entity: match.Entity, EntityKind: match.EntityKind, Subject: match.Subject, Announcements: []Announcement{}})
+				index = len(result) - 1
+			}
+			if result[index].Prompt == "" {
+				result[index].Prompt = match.Prompt
+			}
+
+			for _, announcement := range match.Announcements {
+				key := result[index].Entity + "\x00" + announcementKey(announcement)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				result[index].Announcements = append(result[index].Announcements, announcement)
+			}
+		}
+	}
+
+	if len(responses) > 1 {
+		log.Printf("Merged %d answers into %d announcements for %d entities", len(responses), result.CountAnnouncements(), len(entities))
+	}
+	return ExtractionResponse{Results: result, RawResponse: strings.Join(raw, "\n"), Usage: usage}
+}
+
+// announcementKey identifies an announcement for deduplication. An announcement split over two
+// chunks may be reported for each part, with the same number and details.
+func announcementKey(announcement Announcement) string {
+	fields := []string{
+		string(announcement.Kind),
+		announcement.ID,
+		strings.ToLower(announcement.Name),
+		announcement.CPR,
+		announcement.CVR,
+		strings.ToLower(announcement.Matrikel),
+		strings.ToLower(announcement.Address),
+	}
+	return strings.Join(fields, "\x00")
+}
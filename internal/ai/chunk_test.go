@@ -0,0 +1,158 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"egobot/internal/ai/aitest"
+	"egobot/internal/gazette"
+	"egobot/internal/watchlist"
+)
+
+// sampleNotices returns n dødsbo announcements of roughly the same length
+func sampleNotices(n int) []gazette.Notice {
+	lines := []string{"Dødsboer", "Proklama"}
+	for i := 1; i <= n; i++ {
+		lines = append(lines,
+			fmt.Sprintf("S17072025-%d", i),
+			"Afdøde",
+			fmt.Sprintf("Person Nummer%d", i),
+			fmt.Sprintf("Husmandsvej %d, 4990 Sakskøbing", i),
+			"Skifteretten i Nykøbing Falster opfordrer boets kreditorer til at anmelde deres krav inden 8 uger.",
+		)
+	}
+	return gazette.SplitText(strings.Join(lines, "\n"))
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := map[string]int{
+		"gpt-4o-mini":               128000,
+		"gpt-4o-mini-2024-07-18":    128000,
+		"gpt-4":                     8192,
+		"gpt-4-turbo":               128000,
+		"GPT-4.1-nano":              1047576,
+		"llama3.1:8b-instruct-q4_0": 128000,
+		"my-azure-deployment":       defaultContextWindow,
+	}
+	for model, expected := range tests {
+		if got := ContextWindow(model); got != expected {
+			t.Errorf("ContextWindow(%q) = %d, expected %d", model, got, expected)
+		}
+	}
+}
+
+func TestChunkNotices(t *testing.T) {
+	notices := sampleNotices(40)
+	maxTokens := 500
+
+	chunks := chunkNotices(notices, maxTokens, formatNotices)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+
+	var ids []string
+	for i, chunk := range chunks {
+		if tokens := estimateTokens(formatNotices(chunk)); tokens > maxTokens {
+			t.Errorf("Chunk %d has %d tokens, more than %d", i, tokens, maxTokens)
+		}
+		for _, notice := range chunk {
+			if len(notice.Lines) != len(notices[len(ids)].Lines) {
+				t.Errorf("Expected announcement %s to be kept whole", notice.ID)
+			}
+			ids = append(ids, notice.ID)
+		}
+	}
+	// Every announcement is in exactly one chunk, in document order
+	for i, notice := range notices {
+		if i >= len(ids) || ids[i] != notice.ID {
+			t.Fatalf("Expected announcements in order, got %v", ids)
+		}
+	}
+}
+
+func TestChunkNotices_LongAnnouncement(t *testing.T) {
+	lines := []string{"Konkursboer", "Dekret", "S17072025-23"}
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("Linje %d af en meget lang kundgørelse om fordringer og kreditorer.", i))
+	}
+	notices := gazette.SplitText(strings.Join(lines, "\n"))
+
+	chunks := chunkNotices(notices, 300, formatNotices)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the announcement to be split, got %d chunks", len(chunks))
+	}
+	total := 0
+	for _, chunk := range chunks {
+		if len(chunk) != 1 || chunk[0].ID != "S17072025-23" {
+			t.Fatalf("Expected each chunk to hold a part of the announcement, got %+v", chunk)
+		}
+		total += len(chunk[0].Lines)
+	}
+	if total != len(notices[0].Lines) {
+		t.Errorf("Expected all %d lines to be kept, got %d", len(notices[0].Lines), total)
+	}
+}
+
+func TestExtractEntitiesFromText_Chunks(t *testing.T) {
+	notices := sampleNotices(40)
+	text := joinNotices(notices)
+
+	// Chunks are answered in the order they arrive, so every answer reports the same
+	// announcement and the merged result must contain it only once
+	server := aitest.NewServer(t)
+	answer := matchesPayload{Matches: []matchPayload{{
+		Entity:         "Person Nummer7",
+		AnnouncementID: "S17072025-7",
+		CaseType:       "dødsbo",
+		Name:           "Person Nummer7",
+		Address:        "Husmandsvej 7, 4990 Sakskøbing",
+	}}}
+	for i := 0; i < 20; i++ {
+		server.Enqueue(aitest.CompletedJSON("gpt-4", answer))
+	}
+
+	extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithContextWindow(3000)
+	response, err := extractor.ExtractEntitiesFromText(context.Background(), text, watchlist.FromStrings([]string{"Person Nummer7", "Person Nummer33"}))
+	if err != nil {
+		t.Fatalf("ExtractEntitiesFromText failed: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) < 2 {
+		t.Fatalf("Expected the text to be sent in several chunks, got %d requests", len(requests))
+	}
+	for _, notice := range notices {
+		sent := 0
+		for _, request := range requests {
+			if strings.Contains(string(request.Body), notice.ID+`\n`) {
+				sent++
+			}
+		}
+		if sent != 1 {
+			t.Errorf("Expected announcement %s to be sent once, got %d", notice.ID, sent)
+		}
+	}
+
+	if len(response.Results) != 2 || response.Results.CountAnnouncements() != 1 {
+		t.Fatalf("Expected a single merged announcement, got %+v", response.Results)
+	}
+	match, _ := response.Results.Match("Person Nummer7")
+	if !match.Found() || !match.Announcements[0].Verified {
+		t.Errorf("Expected a verified announcement for Person Nummer7, got %+v", match.Announcements)
+	}
+}
+
+func TestExtractEntitiesFromText_ChunkFails(t *testing.T) {
+	server := aitest.NewServer(t)
+	for i := 0; i < 20; i++ {
+		server.Enqueue(aitest.ServerError(500))
+	}
+
+	extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithContextWindow(3000)
+	_, err := extractor.ExtractEntitiesFromText(context.Background(), joinNotices(sampleNotices(40)), watchlist.FromStrings([]string{"Person Nummer7"}))
+	if err == nil || !strings.Contains(err.Error(), "HTTP 500") {
+		t.Errorf("Expected the failed chunk to fail the extraction, got %v", err)
+	}
+}
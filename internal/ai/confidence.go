@@ -0,0 +1,66 @@
+package ai
+
+import "slices"
+
+// DefaultConfidenceThreshold is the confidence below which a match is reported as possible
+const DefaultConfidenceThreshold = 0.6
+
+// strategyConfidence is how much a match by each strategy is trusted. Identification numbers
+// are unambiguous, while a partial match may well be another person or company.
+var strategyConfidence = map[MatchStrategy]float64{
+	StrategyCPR:        1,
+	StrategyCVR:        1,
+	StrategyExact:      0.9,
+	StrategyAddress:    0.85,
+	StrategyNormalized: 0.8,
+	StrategyAllParts:   0.6,
+	StrategyPartial:    0.3,
+}
+
+// Weights of the signals combined into the confidence of an announcement
+const (
+	strategyWeight     = 0.5
+	verificationWeight = 0.25
+	modelWeight        = 0.25
+)
+
+// confidence combines the signals known about the announcement into a score from 0 to 1: the
+// strategy that found the entity, the verification of the values and the model's own
+// assessment. checked is set when the announcement was compared with the PDF text, so a
+// missing strategy means the entity was not found. Signals that are unknown are left out.
+func confidence(a Announcement, checked bool) float64 {
+	var sum, weights float64
+	if checked {
+		sum += strategyWeight * strategyConfidence[a.Strategy]
+		weights += strategyWeight
+
+		verification := 0.5 // Some values the model reported are not in the text
+		switch {
+		case a.Verified:
+			verification = 1
+		case slices.Contains(a.Unverified, "entity"):
+			verification = 0
+		}
+		sum += verificationWeight * verification
+		weights += verificationWeight
+	}
+	if a.ModelConfidence != nil {
+		sum += modelWeight * *a.ModelConfidence
+		weights += modelWeight
+	}
+	if weights == 0 {
+		return 0.5 // Nothing is known either way
+	}
+	return sum / weights
+}
+
+// clampConfidence limits a confidence reported by the model to the range 0 to 1
+func clampConfidence(c float64) float64 {
+	switch {
+	case c < 0:
+		return 0
+	case c > 1:
+		return 1
+	}
+	return c
+}
@@ -0,0 +1,49 @@
+package ai
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConfidence(t *testing.T) {
+	high, low := 0.9, 0.2
+
+	tests := []struct {
+		name         string
+		announcement Announcement
+		checked      bool
+		expected     float64
+	}{
+		{"nothing known", Announcement{}, false, 0.5},
+		{"model only", Announcement{ModelConfidence: &high}, false, 0.9},
+		{"cpr verified", Announcement{Strategy: StrategyCPR, Verified: true}, true, 1},
+		{"exact verified with model", Announcement{Strategy: StrategyExact, Verified: true, ModelConfidence: &high}, true, 0.45 + 0.25 + 0.225},
+		{"partial verified", Announcement{Strategy: StrategyPartial, Verified: true}, true, (0.15 + 0.25) / 0.75},
+		{"values unverified", Announcement{Strategy: StrategyExact, Unverified: []string{"cpr"}, ModelConfidence: &low}, true, 0.45 + 0.125 + 0.05},
+		{"entity not found", Announcement{Unverified: []string{"entity"}, ModelConfidence: &high}, true, 0.225},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := confidence(test.announcement, test.checked); math.Abs(got-test.expected) > 1e-9 {
+				t.Errorf("Expected confidence %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestExtractionResult_MarkPossible(t *testing.T) {
+	result := ExtractionResult{
+		{Entity: "A", Announcements: []Announcement{{Confidence: 0.9}, {Confidence: 0.59}}},
+		{Entity: "B", Announcements: []Announcement{{Confidence: 0.6, Possible: true}}},
+	}
+
+	result.MarkPossible(0.6)
+	if result.CountPossible() != 1 || !result[0].Announcements[1].Possible {
+		t.Errorf("Expected only the announcement below the threshold to be possible, got %+v", result)
+	}
+
+	if result.MarkPossible(0).CountPossible() != 0 {
+		t.Errorf("Expected no possible matches with threshold 0, got %+v", result)
+	}
+}
@@ -3,362 +3,516 @@ package ai
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
-)
 
-// ExtractionResult maps each entity to its extracted information.
-type ExtractionResult map[string]string
+	"egobot/internal/address"
+	"egobot/internal/gazette"
+	"egobot/internal/ident"
+	"egobot/internal/pdf"
+	"egobot/internal/prompt"
+	"egobot/internal/watchlist"
+)
 
-// ExtractionResponse contains both the parsed results and the raw OpenAI response
+// ExtractionResponse contains both the parsed results and the raw model response
 type ExtractionResponse struct {
 	Results     ExtractionResult
-	RawResponse string
+	RawResponse string // Raw model output, kept for debugging only
+	Usage       Usage  // Tokens spent on the extraction, zero if the model wasn't called
 }
 
-// ExtractEntitiesFromPDFURL uses OpenAI's file_url parameter to analyze PDFs directly from URLs
-func ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities []string) (ExtractionResponse, error) {
-	log.Printf("Starting PDF analysis for URL: %s", pdfURL)
+// LLMExtractor extracts entities from Statstidende PDFs using a language model
+type LLMExtractor struct {
+	provider      LLMProvider
+	client        *http.Client // Used to download PDFs for providers that cannot read them from a URL
+	contextWindow int          // Context window of the model in tokens, which decides the chunk size
+	prompts       *prompt.Set  // Prompt templates, selected per entity
+	prices        Prices       // Model prices used to estimate the cost of each request
+	cache         CacheStore   // Optional cache of extraction results
+	cacheTTL      time.Duration
+	fileUpload    bool       // Upload local PDFs for the model to read, see WithFileUpload
+	layout        pdf.Layout // Order of the text read from PDFs
+
+	uploadsMu sync.Mutex
+	uploads   map[string]string // File IDs of uploaded PDFs by content, kept while their extraction fails
+}
 
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return ExtractionResponse{}, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+// NewLLMExtractor creates an extractor that sends its prompts to the given provider
+func NewLLMExtractor(provider LLMProvider) *LLMExtractor {
+	return &LLMExtractor{
+		provider: provider,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		contextWindow: ContextWindow(provider.Model()),
+		prompts:       prompt.Default(),
+		prices:        DefaultPrices,
+		uploads:       make(map[string]string),
 	}
+}
 
-	// Create the entity list for the prompt
-	entityList := strings.Join(entities, "\n- ")
-	if len(entityList) > 0 {
-		entityList = "- " + entityList
+// WithContextWindow overrides the context window derived from the model name, e.g. for
+// self-hosted models or Azure deployments whose name doesn't reveal the model. Zero is ignored.
+func (e *LLMExtractor) WithContextWindow(tokens int) *LLMExtractor {
+	if tokens > 0 {
+		e.contextWindow = tokens
 	}
+	return e
+}
+
+// WithPrompts replaces the embedded prompt templates, e.g. with templates loaded from a directory
+func (e *LLMExtractor) WithPrompts(prompts *prompt.Set) *LLMExtractor {
+	e.prompts = prompts
+	return e
+}
 
-	log.Printf("Entities to look for: \n%s", entityList)
+// WithPrices replaces the default model prices used to estimate the cost of the requests. Nil is ignored.
+func (e *LLMExtractor) WithPrices(prices Prices) *LLMExtractor {
+	if prices != nil {
+		e.prices = prices
+	}
+	return e
+}
 
-	// Use the Danish prompt for Statstidende analysis
-	userPrompt := fmt.Sprintf(`Du er advokat med speciale i konkursboer, dødsboer og tvangsauktioner. Du forstår hvilken information der er relevant for hver type af sag. Analyser denne udgave af statstidende og find relevant info for de adresser (herunder postnumre, bynavne), personnavne, cpr-numre, virkosmhedsnavne, og cvr-numre, som jeg giver dig. Medtag udelukkende følgende information for hver sagstype:
-	- Dødsboer: navn, cpr, adresse, dødsdato
-	- Konkursboer: virksomhedsnavn, cvr, hvornår konkursbegæring er modtaget
-	- Tvangsauktioner: matrikel og/eller adresse på ejendom
+// WithCache caches the results in the store for the given time, so a retried run or a
+// publication received twice is not analysed again
+func (e *LLMExtractor) WithCache(store CacheStore, ttl time.Duration) *LLMExtractor {
+	e.cache = store
+	e.cacheTTL = ttl
+	return e
+}
 
-	Find relevant information for følgende:
-	%s
+// WithFileUpload lets the model read local PDFs itself, by uploading them to providers that
+// support it, instead of sending the text of the announcements that mention an entity
+func (e *LLMExtractor) WithFileUpload(enabled bool) *LLMExtractor {
+	e.fileUpload = enabled
+	return e
+}
 
-	Betragt hvert af punkterne isoleret, de har ikke noget med hinanden at gøre og skal analyseres separat. Hvert punkt kan optræde flere gange (fx adresse der deles af virksomhed og person), medtag i de tilfælde alle matches.`, entityList)
+// WithLayout selects the order in which the text of PDFs is read, e.g. pdf.LayoutColumns to
+// keep the columns of a gazette drawn row by row apart
+func (e *LLMExtractor) WithLayout(layout pdf.Layout) *LLMExtractor {
+	e.layout = layout
+	return e
+}
 
-	// Create HTTP client
-	client := &http.Client{
-		Timeout: 60 * time.Second,
-	}
+// WithTransport replaces the transport PDFs are downloaded with, e.g. a CassetteTransport to
+// record or replay the downloads
+func (e *LLMExtractor) WithTransport(transport http.RoundTripper) *LLMExtractor {
+	e.client.Transport = transport
+	return e
+}
 
-	// Prepare the request payload using the new Responses API format
-	requestBody := map[string]interface{}{
-		"model": "gpt-4o-mini", // 200k tokens per minut limit (should be enough for 1000 pages)
-		"input": []map[string]interface{}{
-			{
-				"role": "user",
-				"content": []map[string]interface{}{
-					{
-						"type":     "input_file",
-						"file_url": pdfURL,
-					},
-					{
-						"type": "input_text",
-						"text": userPrompt,
-					},
-				},
-			},
-		},
+// sourceKind returns the kind of source in cache keys, marked with the layout unless it is
+// plain. The text sent to the model and the text its answers are verified against depend on
+// the layout, and so may the cached result.
+func (e *LLMExtractor) sourceKind(kind string) string {
+	if e.layout == pdf.LayoutColumns {
+		return kind + "-columns"
 	}
+	return kind
+}
 
-	// Convert to JSON
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return ExtractionResponse{}, fmt.Errorf("failed to marshal request: %w", err)
+// ExtractEntitiesFromPDFURL lets the model read the PDF directly from the URL. Providers
+// that can't do that get the PDF downloaded and analysed through the local text pipeline.
+// Results are cached by URL, which identifies the publication.
+func (e *LLMExtractor) ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	return e.cached(ctx, e.sourceKind("url")+":"+pdfURL, entities, func() (ExtractionResponse, error) {
+		return e.extractPDFURL(ctx, pdfURL, entities)
+	})
+}
+
+// extractPDFURL analyses the PDF at the URL without the cache
+func (e *LLMExtractor) extractPDFURL(ctx context.Context, pdfURL string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	log.Printf("Starting PDF analysis for URL: %s (provider: %s, model: %s)", pdfURL, e.provider.Name(), e.provider.Model())
+
+	response, err := e.extractByPrompt(entities, func(tmpl *prompt.Template, entities watchlist.Watchlist) (ExtractionResponse, error) {
+		instructions, err := renderPrompt(tmpl, "Analyser denne udgave af statstidende", entities)
+		if err != nil {
+			return ExtractionResponse{}, err
+		}
+		return e.requestMatches(ctx, CompletionRequest{Prompt: instructions, FileURL: pdfURL}, entities)
+	})
+	if err == nil {
+		// The model read the PDF itself, so it has to be downloaded to verify the answer
+		response.Results = e.verifyURL(ctx, pdfURL, response.Results)
+		return response, nil
+	}
+	if !errors.Is(err, ErrFileInputUnsupported) {
+		return ExtractionResponse{}, err
 	}
 
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/responses", bytes.NewBuffer(jsonData))
+	log.Printf("%s cannot read PDFs from a URL, analysing the text locally instead", e.provider.Name())
+	data, err := downloadPDF(ctx, e.client, pdfURL)
 	if err != nil {
-		return ExtractionResponse{}, fmt.Errorf("failed to create request: %w", err)
+		return ExtractionResponse{}, err
 	}
+	return e.ExtractEntitiesFromPDFFile(ctx, bytes.NewReader(data), pdfURL, entities)
+}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+// ExtractEntitiesFromText analyzes already extracted (and filtered) gazette text, in chunks
+// split on announcement boundaries if it doesn't fit in the model's context window
+func (e *LLMExtractor) ExtractEntitiesFromText(ctx context.Context, text string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	return e.cached(ctx, contentSource("text", []byte(text)), entities, func() (ExtractionResponse, error) {
+		return e.extractText(ctx, text, entities)
+	})
+}
 
-	// Initial delay
-	delay := 1 * time.Second
-	maxRetries := 3
+// extractText analyses the text without the cache
+func (e *LLMExtractor) extractText(ctx context.Context, text string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	log.Printf("Starting text analysis (%d chars, provider: %s, model: %s)", len(text), e.provider.Name(), e.provider.Model())
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			log.Printf("Attempt %d/%d, waiting %v before retry...", attempt+1, maxRetries, delay)
-			time.Sleep(delay)
+	notices := gazette.SplitText(text)
+	response, err := e.extractByPrompt(entities, func(tmpl *prompt.Template, entities watchlist.Watchlist) (ExtractionResponse, error) {
+		instructions, err := renderPrompt(tmpl, "Analyser følgende uddrag af statstidende", entities)
+		if err != nil {
+			return ExtractionResponse{}, err
 		}
+		return e.extractChunks(ctx, notices, entities, joinNotices, func(text string) string {
+			return instructions + "\n\nUddrag:\n" + text
+		})
+	})
+	if err != nil {
+		return ExtractionResponse{}, err
+	}
+	response.Results = verifyResult(response.Results, notices)
+	return response, nil
+}
+
+// renderPrompt renders the prompt template for the entities. The task describes what is
+// being analysed (the whole issue or an excerpt).
+func renderPrompt(tmpl *prompt.Template, task string, entities watchlist.Watchlist) (string, error) {
+	log.Printf("Entities to look for with prompt %s: %s", tmpl.ID(), strings.Join(entities.Values(), ", "))
+	return tmpl.Render(prompt.Data{Task: task, Entities: entities})
+}
 
-		// Make the request
-		resp, err := client.Do(req)
+// extractByPrompt runs the extraction once for each prompt template the entities use and merges
+// the results, recording the template and its version with each match
+func (e *LLMExtractor) extractByPrompt(entities watchlist.Watchlist, extract func(tmpl *prompt.Template, entities watchlist.Watchlist) (ExtractionResponse, error)) (ExtractionResponse, error) {
+	names := entities.Prompts()
+	responses := make([]ExtractionResponse, 0, len(names))
+	for _, name := range names {
+		tmpl, err := e.prompts.Get(name)
 		if err != nil {
-			log.Printf("HTTP request error (attempt %d): %v", attempt+1, err)
-			if attempt < maxRetries-1 {
-				delay = delay * 2
-				if delay > 60*time.Second {
-					delay = 60 * time.Second
-				}
-				continue
-			}
-			return ExtractionResponse{}, fmt.Errorf("failed to make HTTP request: %w", err)
+			return ExtractionResponse{}, err
 		}
-		defer resp.Body.Close()
-
-		// Read response
-		body, err := io.ReadAll(resp.Body)
+		response, err := extract(tmpl, entities.Prompt(name))
 		if err != nil {
-			return ExtractionResponse{}, fmt.Errorf("failed to read response body: %w", err)
+			return ExtractionResponse{}, err
 		}
-
-		// Check if request was successful
-		if resp.StatusCode != http.StatusOK {
-			log.Printf("OpenAI API error (attempt %d): HTTP %d - %s", attempt+1, resp.StatusCode, string(body))
-
-			// Check if it's a rate limit error
-			if resp.StatusCode == 429 {
-				if attempt < maxRetries-1 {
-					delay = delay * 2
-					if delay > 60*time.Second {
-						delay = 60 * time.Second
-					}
-					continue
-				} else {
-					return ExtractionResponse{}, fmt.Errorf("rate limit exceeded after %d retries", maxRetries)
-				}
-			} else {
-				return ExtractionResponse{}, fmt.Errorf("OpenAI API error: HTTP %d - %s", resp.StatusCode, string(body))
-			}
+		for i := range response.Results {
+			response.Results[i].Prompt = tmpl.ID()
 		}
+		responses = append(responses, response)
+	}
 
-		// Parse response
-		var response map[string]interface{}
-		if err := json.Unmarshal(body, &response); err != nil {
-			return ExtractionResponse{}, fmt.Errorf("failed to parse response: %w", err)
-		}
+	if len(responses) == 1 {
+		return responses[0], nil
+	}
+	return mergeResponses(entities, responses), nil
+}
 
-		// Check for API-level errors in the response
-		if errorField, exists := response["error"]; exists && errorField != nil {
-			return ExtractionResponse{}, fmt.Errorf("OpenAI API returned error: %v", errorField)
-		}
+// requestMatches sends the request to the provider and parses the structured answer
+func (e *LLMExtractor) requestMatches(ctx context.Context, req CompletionRequest, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	req.Schema = matchesSchema()
 
-		// Check if response is completed
-		status, ok := response["status"].(string)
-		if !ok || status != "completed" {
-			return ExtractionResponse{}, fmt.Errorf("response not completed, status: %v", status)
-		}
+	completion, err := e.provider.Complete(ctx, req)
+	if err != nil {
+		return ExtractionResponse{}, err
+	}
 
-		// Extract the answer from the new Responses API format
-		output, ok := response["output"].([]interface{})
-		if !ok || len(output) == 0 {
-			return ExtractionResponse{}, fmt.Errorf("no output in response")
-		}
+	// Parse the structured answer into typed matches
+	allResults, err := parseMatches(completion.Text, entities)
+	if err != nil {
+		return ExtractionResponse{}, err
+	}
 
-		outputItem, ok := output[0].(map[string]interface{})
-		if !ok {
-			return ExtractionResponse{}, fmt.Errorf("invalid output format")
-		}
+	// Price by the model that answered, which is more specific than the configured name
+	model := completion.Model
+	if model == "" {
+		model = e.provider.Model()
+	}
+	usage := Usage{
+		Requests:     1,
+		InputTokens:  completion.InputTokens,
+		OutputTokens: completion.OutputTokens,
+		Cost:         e.prices.Cost(model, completion.InputTokens, completion.OutputTokens),
+	}
 
-		content, ok := outputItem["content"].([]interface{})
-		if !ok || len(content) == 0 {
-			return ExtractionResponse{}, fmt.Errorf("no content in output")
-		}
+	log.Printf("Extraction completed, found %d announcements for %d entities (%d input and %d output tokens, $%.4f)",
+		allResults.CountAnnouncements(), len(entities), usage.InputTokens, usage.OutputTokens, usage.Cost)
+	return ExtractionResponse{
+		Results:     allResults,
+		RawResponse: completion.Text,
+		Usage:       usage,
+	}, nil
+}
 
-		contentItem, ok := content[0].(map[string]interface{})
-		if !ok {
-			return ExtractionResponse{}, fmt.Errorf("invalid content format")
-		}
+// MatchStrategy names the strategy that matched an entity in the text
+type MatchStrategy string
+
+const (
+	StrategyExact      MatchStrategy = "exact"      // Substring match ignoring case and spaces
+	StrategyAllParts   MatchStrategy = "all_parts"  // Every word of the entity occurs in the text
+	StrategyNormalized MatchStrategy = "normalized" // Substring match ignoring spaces, dashes and dots
+	StrategyPartial    MatchStrategy = "partial"    // At least two words of the entity occur in the text
+	StrategyCPR        MatchStrategy = "cpr"        // The entity is a CPR number found in the text, in any format
+	StrategyCVR        MatchStrategy = "cvr"        // The entity is a CVR number found in the text, in any format
+	StrategyAddress    MatchStrategy = "address"    // The entity is an address whose components match those in the text
+)
 
-		answer, ok := contentItem["text"].(string)
-		if !ok {
-			return ExtractionResponse{}, fmt.Errorf("invalid text format")
-		}
+// entityStrategies lists the strategies tried for each kind of watchlist entity, in order.
+// Names must match as a whole, while addresses are compared component by component.
+var entityStrategies = map[watchlist.Kind][]MatchStrategy{
+	watchlist.KindCPR:      {StrategyCPR},
+	watchlist.KindCVR:      {StrategyCVR},
+	watchlist.KindPerson:   {StrategyExact, StrategyAllParts},
+	watchlist.KindCompany:  {StrategyExact, StrategyNormalized, StrategyAllParts},
+	watchlist.KindAddress:  {StrategyAddress},
+	watchlist.KindMatrikel: {StrategyExact, StrategyNormalized},
+	watchlist.KindAny:      {StrategyExact, StrategyAllParts, StrategyNormalized, StrategyPartial},
+}
 
-		log.Printf("Received answer, length: %d", len(answer))
+// unparsedAddressStrategies are tried for addresses that cannot be parsed into components
+var unparsedAddressStrategies = []MatchStrategy{StrategyExact, StrategyNormalized, StrategyAllParts}
 
-		// Parse results - look for each entity in the response
-		allResults := make(ExtractionResult)
-		for _, entity := range entities {
-			if idx := strings.Index(strings.ToLower(answer), strings.ToLower(entity)); idx != -1 {
-				rest := answer[idx:]
-				end := strings.Index(rest, "\n\n")
-				if end == -1 {
-					end = len(rest)
-				}
-				entityInfo := strings.TrimSpace(rest[:end])
-
-				// Set the result for this entity
-				allResults[entity] = entityInfo
-			} else {
-				// Entity not found in response
-				allResults[entity] = "No information found."
-			}
+// matchEntity tries the strategies for the entity's kind in order and returns the first that matches
+func matchEntity(text string, entity watchlist.Entity) (MatchStrategy, bool) {
+	strategies, ok := entityStrategies[entity.Kind]
+	if !ok {
+		strategies = entityStrategies[watchlist.KindAny]
+	}
+	if entity.Kind == watchlist.KindAddress {
+		if _, err := address.Parse(entity.Value); err != nil {
+			strategies = unparsedAddressStrategies
 		}
-
-		log.Printf("Extraction completed, found info for %d entities", len(allResults))
-		return ExtractionResponse{
-			Results:     allResults,
-			RawResponse: answer,
-		}, nil
 	}
-
-	return ExtractionResponse{}, fmt.Errorf("failed to process document after %d attempts", maxRetries)
+	for _, strategy := range strategies {
+		if matchStrategy(strategy, text, entity.Value) {
+			return strategy, true
+		}
+	}
+	return "", false
 }
 
-// extractRelevantSections extracts only sections that contain the target entities
-func extractRelevantSections(text string, entities []string) string {
-	// Split text into sentences for more granular filtering
-	sentences := strings.Split(text, ". ")
-	var relevantSentences []string
-
-	// Track which entities we've found
-	foundEntities := make(map[string]bool)
-
-	for _, sentence := range sentences {
-		sentenceLower := strings.ToLower(sentence)
+// matchStrategy reports whether the entity is found in the text using the given strategy
+func matchStrategy(strategy MatchStrategy, text, entity string) bool {
+	switch strategy {
+	case StrategyCPR:
+		// CPR and CVR numbers are compared with the numbers recognised in the text, so they
+		// match in any format but never as part of a longer number
+		cpr, err := ident.ParseCPR(entity)
+		if err != nil {
+			return false
+		}
+		for _, found := range ident.FindCPRs(text) {
+			if found == cpr {
+				return true
+			}
+		}
+		return false
 
-		// Check if sentence contains any of the target entities
-		for _, entity := range entities {
-			if findEntityInText(sentence, entity) {
-				relevantSentences = append(relevantSentences, sentence)
-				foundEntities[entity] = true
-				break
+	case StrategyCVR:
+		cvr, err := ident.ParseCVR(entity)
+		if err != nil {
+			return false
+		}
+		for _, found := range ident.FindCVRs(text) {
+			if found == cvr {
+				return true
 			}
 		}
+		return false
 
-		// Also include sentences with business keywords
-		// We include sentences containing business keywords (like bankruptcy, death estate, etc.)
-		// because they often provide important context about the entities we're tracking, even if
-		// the entities aren't directly mentioned in those sentences. For example, a sentence with
-		// "dødsbo" (death estate) might explain what happened to a person we're tracking, while
-		// a sentence with "konkurs" (bankruptcy) might explain what happened to their business.
-		businessKeywords := []string{
-			"frivillig likvidation", "dødsbo", "konkurs", "tvangsauktion", "fusion",
-			"skifteret", "sagsnummer", "cpr", "cvr", "adresse", "dødsdato",
+	case StrategyAddress:
+		// Street and house number must match, and the other components must agree where the
+		// text has them, so Husmandsvej 12 doesn't match Husmandsvej 120 or another postnummer
+		watched, err := address.Parse(entity)
+		if err != nil {
+			return false
+		}
+		_, found := watched.Find(text)
+		return found
+
+	case StrategyExact:
+		// Normalize both text and entity for comparison
+		normalizedText := strings.ToLower(strings.ReplaceAll(text, " ", ""))
+		normalizedEntity := strings.ToLower(strings.ReplaceAll(entity, " ", ""))
+		return strings.Contains(normalizedText, normalizedEntity)
+
+	case StrategyAllParts:
+		// Split entity into parts and check each part
+		entityParts := strings.Fields(entity)
+		if len(entityParts) <= 1 {
+			return false
+		}
+		for _, part := range entityParts {
+			if !strings.Contains(strings.ToLower(text), strings.ToLower(part)) {
+				return false
+			}
 		}
+		return true
 
-		for _, keyword := range businessKeywords {
-			if strings.Contains(sentenceLower, keyword) {
-				relevantSentences = append(relevantSentences, sentence)
-				break
+	case StrategyNormalized:
+		// Check for common variations (e.g., "0605410146" vs "06 05 41 01 46")
+		// Remove spaces and special characters for comparison
+		cleanText := strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(text, " ", ""), "-", ""), ".", "")
+		cleanEntity := strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(entity, " ", ""), "-", ""), ".", "")
+		return strings.Contains(strings.ToLower(cleanText), strings.ToLower(cleanEntity))
+
+	case StrategyPartial:
+		// For longer entities like addresses, check if major parts are present
+		if len(entity) <= 5 {
+			return false
+		}
+		words := strings.Fields(entity)
+		if len(words) < 2 {
+			return false
+		}
+		// Check if at least 2 words from the entity are found
+		foundWords := 0
+		for _, word := range words {
+			if len(word) > 2 && strings.Contains(strings.ToLower(text), strings.ToLower(word)) {
+				foundWords++
 			}
 		}
+		return foundWords >= 2
 	}
+	return false
+}
 
-	// If we found relevant content, return it
-	if len(relevantSentences) > 0 {
-		filteredText := strings.Join(relevantSentences, ". ")
-		log.Printf("Section extraction: found %d relevant sentences", len(relevantSentences))
-		return filteredText
+// ExtractEntitiesFromPDFFile splits the PDF into announcements and analyses only those that
+// mention an entity, or uploads the PDF if file upload is enabled. Results are cached by the
+// hash of the PDF content.
+func (e *LLMExtractor) ExtractEntitiesFromPDFFile(ctx context.Context, file io.Reader, filename string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	if e.cache == nil && !e.fileUpload {
+		return e.extractPDFFile(ctx, file, filename, entities)
 	}
-
-	// If no relevant content found, return first 1000 characters
-	log.Printf("No relevant sections found, using first 1000 characters")
-	if len(text) > 1000 {
-		return text[:1000]
+	// The size is checked before the PDF is hashed or buffered, and a reader that can seek is
+	// read in place
+	section, err := pdf.Section(file)
+	if err != nil {
+		return ExtractionResponse{}, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	if e.fileUpload {
+		data, err := io.ReadAll(io.NewSectionReader(section, 0, section.Size()))
+		if err != nil {
+			return ExtractionResponse{}, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		source := contentSource(e.sourceKind("upload"), data)
+		return e.cached(ctx, source, entities, func() (ExtractionResponse, error) {
+			return e.extractUploadedPDF(ctx, source, data, filename, entities)
+		})
 	}
-	return text
+	source, err := readerSource(e.sourceKind("pdf"), io.NewSectionReader(section, 0, section.Size()))
+	if err != nil {
+		return ExtractionResponse{}, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	return e.cached(ctx, source, entities, func() (ExtractionResponse, error) {
+		return e.extractPDFFile(ctx, io.NewSectionReader(section, 0, section.Size()), filename, entities)
+	})
 }
 
-// findEntityInText performs robust entity matching with various strategies
-func findEntityInText(text, entity string) bool {
-	// Normalize both text and entity for comparison
-	normalizedText := strings.ToLower(strings.ReplaceAll(text, " ", ""))
-	normalizedEntity := strings.ToLower(strings.ReplaceAll(entity, " ", ""))
+// extractPDFFile analyses the PDF without the cache
+func (e *LLMExtractor) extractPDFFile(ctx context.Context, file io.Reader, filename string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	log.Printf("Starting PDF analysis for file: %s", filename)
 
-	// Strategy 1: Direct substring match
-	if strings.Contains(normalizedText, normalizedEntity) {
-		return true
+	doc, err := e.layout.Read(file)
+	if err != nil {
+		return ExtractionResponse{}, fmt.Errorf("failed to extract text from %s: %w", filename, err)
 	}
+	notices := gazette.Split(doc.Pages)
+	log.Printf("Extracted %d announcements from %d pages of %s", len(notices), doc.NumPages, filename)
+
+	// Early termination: don't spend tokens on documents that don't mention any entity
+	relevant := relevantNotices(notices, entities)
+	if len(relevant) == 0 {
+		log.Printf("None of the %d entities found in %s, skipping analysis", len(entities), filename)
+		return ExtractionResponse{Results: emptyResult(entities)}, nil
+	}
+	log.Printf("Sending %d announcements for analysis", len(relevant))
 
-	// Strategy 2: Split entity into parts and check each part
-	entityParts := strings.Fields(entity)
-	if len(entityParts) > 1 {
-		allPartsFound := true
-		for _, part := range entityParts {
-			if !strings.Contains(strings.ToLower(text), strings.ToLower(part)) {
-				allPartsFound = false
-				break
-			}
+	response, err := e.extractByPrompt(entities, func(tmpl *prompt.Template, entities watchlist.Watchlist) (ExtractionResponse, error) {
+		// Only send the announcements that mention the entities using this prompt
+		groupNotices := relevantNotices(relevant, entities)
+		if len(groupNotices) == 0 {
+			return ExtractionResponse{Results: emptyResult(entities)}, nil
 		}
-		if allPartsFound {
-			return true
+		instructions, err := renderPrompt(tmpl, "Analyser følgende kundgørelser fra statstidende", entities)
+		if err != nil {
+			return ExtractionResponse{}, err
 		}
+		return e.extractChunks(ctx, groupNotices, entities, formatNotices, func(text string) string {
+			return instructions + "\n\n" + noticesInstructions + "\n\n" + text
+		})
+	})
+	if err != nil {
+		return ExtractionResponse{}, err
 	}
+	response.Results = verifyResult(response.Results, notices)
+	return response, nil
+}
 
-	// Strategy 3: Check for common variations (e.g., "0605410146" vs "06 05 41 01 46")
-	// Remove spaces and special characters for comparison
-	cleanText := strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(text, " ", ""), "-", ""), ".", "")
-	cleanEntity := strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(entity, " ", ""), "-", ""), ".", "")
-
-	if strings.Contains(strings.ToLower(cleanText), strings.ToLower(cleanEntity)) {
-		return true
-	}
+// noticesInstructions tells the model how the announcements are separated
+const noticesInstructions = `Kundgørelserne er adskilt og indledes hver med "Kundgørelse" efterfulgt af nummer, sektion og overskrift. Oplysninger må kun kombineres inden for den samme kundgørelse, aldrig på tværs af kundgørelser.`
 
-	// Strategy 4: Check for partial matches (for addresses)
-	if len(entity) > 5 {
-		// For longer entities like addresses, check if major parts are present
-		words := strings.Fields(entity)
-		if len(words) >= 2 {
-			// Check if at least 2 words from the entity are found
-			foundWords := 0
-			for _, word := range words {
-				if len(word) > 2 && strings.Contains(strings.ToLower(text), strings.ToLower(word)) {
-					foundWords++
-				}
-			}
-			if foundWords >= 2 {
-				return true
+// relevantNotices returns the announcements that mention at least one of the entities
+func relevantNotices(notices []gazette.Notice, entities watchlist.Watchlist) []gazette.Notice {
+	var relevant []gazette.Notice
+	for _, notice := range notices {
+		text := notice.Text()
+		for _, entity := range entities {
+			if matches(text, entity) {
+				relevant = append(relevant, notice)
+				break
 			}
 		}
 	}
-
-	return false
+	return relevant
 }
 
-// ExtractEntitiesFromPDFFile uses comprehensive document processing with early termination
-func ExtractEntitiesFromPDFFile(ctx context.Context, file io.Reader, filename string, entities []string) (ExtractionResult, error) {
-	panic("this should not be called")
-}
-
-// extractUltraRelevantContent extracts only the most relevant content containing the target entities
-func extractUltraRelevantContent(text string, entities []string) string {
-	// Split into sentences
-	sentences := strings.Split(text, ". ")
-	var ultraRelevantSentences []string
-
-	// Only include sentences that directly contain the target entities
-	for _, sentence := range sentences {
-		for _, entity := range entities {
-			if findEntityInText(sentence, entity) {
-				ultraRelevantSentences = append(ultraRelevantSentences, sentence)
-				break
+// formatNotices formats the announcements for the prompt, each introduced by its number and section
+func formatNotices(notices []gazette.Notice) string {
+	var sb strings.Builder
+	for _, notice := range notices {
+		sb.WriteString("Kundgørelse")
+		if notice.ID != "" {
+			sb.WriteString(" " + notice.ID)
+		}
+		if notice.Section != "" {
+			sb.WriteString(" (" + notice.Section)
+			if notice.Heading != "" {
+				sb.WriteString(", " + notice.Heading)
 			}
+			sb.WriteString(")")
 		}
+		sb.WriteString(":\n")
+		sb.WriteString(notice.Text())
+		sb.WriteString("\n\n")
 	}
+	return sb.String()
+}
 
-	// If we found sentences with entities, return them
-	if len(ultraRelevantSentences) > 0 {
-		result := strings.Join(ultraRelevantSentences, ". ")
-		log.Printf("Ultra-aggressive filtering: found %d sentences with target entities", len(ultraRelevantSentences))
-		return result
+// joinNotices joins the text of the announcements, for text that is sent without headers
+func joinNotices(notices []gazette.Notice) string {
+	texts := make([]string, 0, len(notices))
+	for _, notice := range notices {
+		texts = append(texts, notice.Text())
 	}
+	return strings.Join(texts, "\n")
+}
 
-	// If no sentences with entities found, return first 500 characters
-	log.Printf("No sentences with target entities found, using first 500 characters")
-	if len(text) > 500 {
-		return text[:500]
+// emptyResult returns a result with no announcements for each of the entities
+func emptyResult(entities watchlist.Watchlist) ExtractionResult {
+	result := make(ExtractionResult, 0, len(entities))
+	for _, entity := range entities {
+		result = append(result, Match{Entity: entity.Value, EntityKind: entity.Kind, Subject: entity.Subject, Announcements: []Announcement{}})
 	}
-	return text
+	return result
 }
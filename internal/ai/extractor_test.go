@@ -0,0 +1,220 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"egobot/internal/ai/aitest"
+	"egobot/internal/gazette"
+	"egobot/internal/pdf"
+	"egobot/internal/prompt"
+	"egobot/internal/watchlist"
+)
+
+func TestExtractEntitiesFromPDFFile_EarlyTermination(t *testing.T) {
+	// The provider is never called: the entities are not in the document
+	extractor := NewLLMExtractor(NewOpenAIResponsesProvider("", "", ""))
+
+	file, err := os.Open("../../statstidende_sample.pdf")
+	if err != nil {
+		t.Fatalf("Failed to open sample PDF: %v", err)
+	}
+	defer file.Close()
+
+	entities := watchlist.FromStrings([]string{"Xyzzy Plugh", "9999999999"})
+	response, err := extractor.ExtractEntitiesFromPDFFile(context.Background(), file, "statstidende_sample.pdf", entities)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(response.Results) != len(entities) {
+		t.Fatalf("Expected %d matches, got %d", len(entities), len(response.Results))
+	}
+	if response.Results.CountAnnouncements() != 0 {
+		t.Errorf("Expected no announcements, got %d", response.Results.CountAnnouncements())
+	}
+	if response.RawResponse != "" {
+		t.Error("Expected no raw response when the model is not called")
+	}
+}
+
+func TestExtractEntitiesFromPDFFile_Layout(t *testing.T) {
+	data := readSamplePDF(t)
+	entities := watchlist.FromStrings([]string{"ACEZONE ApS"})
+
+	// The text sent to the model keeps the printed lines of the column layout
+	for _, test := range []struct {
+		layout pdf.Layout
+		text   string
+	}{
+		{pdf.LayoutPlain, `Ved dekret af 16.07.2025 har Sø- og Handelsrettens skifteret taget`},
+		{pdf.LayoutColumns, `Ved dekret af 16.07.2025 har Sø- og\nHandelsrettens skifteret taget`},
+	} {
+		server := aitest.NewServer(t)
+		server.Enqueue(aitest.Completed("gpt-4o-mini", `{"matches":[]}`))
+
+		extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithLayout(test.layout)
+		if _, err := extractor.ExtractEntitiesFromPDFFile(context.Background(), bytes.NewReader(data), "statstidende_sample.pdf", entities); err != nil {
+			t.Fatalf("ExtractEntitiesFromPDFFile failed with the %s layout: %v", test.layout, err)
+		}
+		requests := server.Requests()
+		if len(requests) != 1 || !strings.Contains(string(requests[0].Body), test.text) {
+			t.Errorf("Expected the %s layout to send %q", test.layout, test.text)
+		}
+	}
+}
+
+func TestDownloadPDF_TooLarge(t *testing.T) {
+	maxSize := pdf.MaxSize
+	pdf.MaxSize = 1 << 10
+	defer func() { pdf.MaxSize = maxSize }()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Query().Has("chunked") {
+			w.(http.Flusher).Flush()
+		}
+		w.Write(make([]byte, pdf.MaxSize+1))
+	}))
+	defer server.Close()
+
+	// Rejected by the Content-Length, or while reading a response without one
+	for _, url := range []string{server.URL + "/large.pdf", server.URL + "/large.pdf?chunked"} {
+		requests.Store(0)
+		if _, err := downloadPDF(context.Background(), server.Client(), url); !errors.Is(err, pdf.ErrTooLarge) {
+			t.Errorf("Expected ErrTooLarge for %s, got %v", url, err)
+		}
+		if got := requests.Load(); got != 1 {
+			t.Errorf("Expected the download not to be retried, got %d requests", got)
+		}
+	}
+}
+
+func TestRelevantNotices(t *testing.T) {
+	notices := gazette.SplitText(strings.Join([]string{
+		"Dødsboer", "Proklama",
+		"S17072025-152", "Afdøde", "CPR-nr.: 080162-0450", "Jette Fries Lundsted", "Husmandsvej 1",
+		"S17072025-154", "Afdøde", "Dorte Bente Jørgensen", "Fabriksvej 8",
+		"S17072025-173", "Afdøde", "Lis Hessel", "Husmandsvej 14, 4990 Sakskøbing",
+	}, "\n"))
+
+	// Entities of unspecified kind fall back to partial matches, unlike addresses
+	entities := watchlist.Watchlist{cpr("0801620450"), {Value: "Husmandsvej 12, 4990 Sakskøbing"}}
+	relevant := relevantNotices(notices, entities)
+	if len(relevant) != 2 || relevant[0].ID != "S17072025-152" || relevant[1].ID != "S17072025-173" {
+		t.Fatalf("Expected announcements 152 and 173, got %+v", relevant)
+	}
+
+	text := formatNotices(relevant)
+	if !strings.HasPrefix(text, "Kundgørelse S17072025-152 (Dødsboer, Proklama):\nS17072025-152\nAfdøde") {
+		t.Errorf("Unexpected formatting: %q", text)
+	}
+	if strings.Contains(text, "Dorte Bente Jørgensen") {
+		t.Error("Expected unrelated announcements to be dropped")
+	}
+}
+
+func TestMatchEntity(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entity   watchlist.Entity
+		expected MatchStrategy
+		found    bool
+	}{
+		{name: "CPR with dash", text: "CPR-nr.: 080162-0450", entity: cpr("0801620450"), expected: StrategyCPR, found: true},
+		{name: "CPR inside longer number", text: "Kontonr. 908016204501", entity: cpr("0801620450"), found: false},
+		{name: "CVR", text: "CVR-nr.: 39293056", entity: watchlist.Entity{Kind: watchlist.KindCVR, Value: "39293056"}, expected: StrategyCVR, found: true},
+		{name: "CVR inside CPR", text: "CPR-nr.: 1392930560", entity: watchlist.Entity{Kind: watchlist.KindCVR, Value: "39293056"}, found: false},
+		{name: "person with middle name", text: "Jette Fries Lundsted", entity: watchlist.Entity{Kind: watchlist.KindPerson, Value: "Jette Lundsted"}, expected: StrategyAllParts, found: true},
+		{name: "person needs all names", text: "Jette Hansen, Lundsted Allé", entity: watchlist.Entity{Kind: watchlist.KindPerson, Value: "Jette Fries Lundsted"}, found: false},
+		{name: "address with parts left out", text: "Husmandsvej 1, 4990 Sakskøbing", entity: addressEntity("Husmandsvej 1, Sakskøbing Danmark"), expected: StrategyAddress, found: true},
+		{name: "address with abbreviations", text: "Lægårdsv. 12 A, st. tv., 8000 Aarhus C", entity: addressEntity("Lægårdsvej 12A, 8000 Aarhus C"), expected: StrategyAddress, found: true},
+		{name: "address with other house number", text: "Husmandsvej 14, 4990 Sakskøbing", entity: addressEntity("Husmandsvej 12, 4990 Sakskøbing"), found: false},
+		{name: "address with longer house number", text: "Husmandsvej 120", entity: addressEntity("Husmandsvej 12"), found: false},
+		{name: "unparsed address", text: "Ejendommen Strandvejen 12-14, 2930 Klampenborg", entity: addressEntity("Strandvejen 12-14"), expected: StrategyExact, found: true},
+		{name: "town", text: "Husmandsvej 1, 4990 Sakskøbing", entity: addressEntity("Sakskøbing"), expected: StrategyAddress, found: true},
+		{name: "company with punctuation", text: "J&K ENTREPRISE ApS", entity: watchlist.Entity{Kind: watchlist.KindCompany, Value: "J&K Entreprise ApS"}, expected: StrategyExact, found: true},
+		{name: "unspecified kind tries everything", text: "CVR: 12-34-56-78", entity: watchlist.Entity{Value: "12345678"}, expected: StrategyNormalized, found: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, found := matchEntity(tt.text, tt.entity)
+			if found != tt.found || strategy != tt.expected {
+				t.Errorf("Expected %q (found %v), got %q (found %v)", tt.expected, tt.found, strategy, found)
+			}
+		})
+	}
+}
+
+// addressEntity returns an address entity
+func addressEntity(value string) watchlist.Entity {
+	return watchlist.Entity{Kind: watchlist.KindAddress, Value: value}
+}
+
+// cpr returns a CPR entity
+func cpr(value string) watchlist.Entity {
+	return watchlist.Entity{Kind: watchlist.KindCPR, Value: value}
+}
+
+func TestExtractEntitiesFromText_Prompts(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ejendom.tmpl"), []byte(`{{define "version"}}3{{end}}Find ejendomme: {{range .Entities}}{{.Value}}; {{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prompts, err := prompt.Load(dir, "")
+	if err != nil {
+		t.Fatalf("Failed to load prompts: %v", err)
+	}
+
+	server := aitest.NewServer(t)
+	server.Enqueue(aitest.CompletedJSON("gpt-4o-mini", matchesPayload{Matches: []matchPayload{{Entity: "Person Nummer1", AnnouncementID: "S17072025-1", CaseType: "dødsbo", Name: "Person Nummer1"}}}))
+	server.Enqueue(aitest.CompletedJSON("gpt-4o-mini", matchesPayload{Matches: []matchPayload{{Entity: "Husmandsvej 2", AnnouncementID: "S17072025-2", CaseType: "dødsbo", Address: "Husmandsvej 2, 4990 Sakskøbing"}}}))
+
+	// The default prompt is used first, as the first entity doesn't name a template
+	entities := watchlist.Watchlist{
+		{Kind: watchlist.KindPerson, Value: "Person Nummer1"},
+		{Kind: watchlist.KindAddress, Value: "Husmandsvej 2", Prompt: "ejendom"},
+		{Kind: watchlist.KindPerson, Value: "Person Nummer3"},
+	}
+	extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithPrompts(prompts)
+	response, err := extractor.ExtractEntitiesFromText(context.Background(), joinNotices(sampleNotices(3)), entities)
+	if err != nil {
+		t.Fatalf("ExtractEntitiesFromText failed: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("Expected one request per prompt, got %d", len(requests))
+	}
+	if body := string(requests[0].Body); !strings.Contains(body, "Du er advokat") || !strings.Contains(body, "Person Nummer3 (personnavn)") || strings.Contains(body, "Husmandsvej 2 (") {
+		t.Errorf("Expected the default prompt with its entities, got %s", body)
+	}
+	if body := string(requests[1].Body); !strings.Contains(body, "Find ejendomme: Husmandsvej 2;") {
+		t.Errorf("Expected the ejendom prompt, got %s", body)
+	}
+
+	// The results keep the order of the watchlist and record the prompt version
+	expected := []string{"advokat@2", "ejendom@3", "advokat@2"}
+	if len(response.Results) != len(expected) {
+		t.Fatalf("Expected %d results, got %+v", len(expected), response.Results)
+	}
+	for i, match := range response.Results {
+		if match.Entity != entities[i].Value || match.Prompt != expected[i] {
+			t.Errorf("Expected %s with prompt %s, got %s with %s", entities[i].Value, expected[i], match.Entity, match.Prompt)
+		}
+	}
+	if response.Results.CountAnnouncements() != 2 {
+		t.Errorf("Expected an announcement from each prompt, got %+v", response.Results)
+	}
+}
@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"egobot/internal/httpretry"
+	"egobot/internal/pdf"
+)
+
+// postJSON sends the request body as JSON and returns the response body of a successful response.
+// Transient failures are retried according to the policy.
+func postJSON(ctx context.Context, client *http.Client, name, url string, headers map[string]string, requestBody interface{}, retry httpretry.Policy) ([]byte, error) {
+	// Convert to JSON
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	_, body, err := retry.Do(ctx, client, name, func(ctx context.Context) (*http.Request, error) {
+		// The body is consumed when sent, so every attempt gets a fresh reader
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s API error: %w", name, err)
+	}
+	return body, nil
+}
+
+// downloadPDF downloads a PDF so it can be analysed locally
+func downloadPDF(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	log.Printf("Downloading PDF from: %s", url)
+
+	// The PDF is rejected by its Content-Length before it is read, and otherwise read no
+	// further than the largest PDF that is analysed
+	policy := httpretry.Default
+	policy.MaxBodySize = pdf.MaxSize
+	_, data, err := policy.Do(ctx, client, "PDF download", func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", url, nil)
+	})
+	if errors.Is(err, httpretry.ErrBodyTooLarge) {
+		return nil, fmt.Errorf("failed to download PDF: %w: %w", pdf.ErrTooLarge, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download PDF: %w", err)
+	}
+
+	log.Printf("Successfully downloaded PDF (%d bytes)", len(data))
+	return data, nil
+}
@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"egobot/internal/gazette"
+	"egobot/internal/ident"
+	"egobot/internal/pdf"
+	"egobot/internal/watchlist"
+)
+
+// matcherContextLines is the number of lines kept before and after a matching line
+const matcherContextLines = 3
+
+// MatcherExtractor finds entities in Statstidende PDFs without a language model, using the same
+// strategies as the prompt filtering. It is free, deterministic and every hit can be audited.
+type MatcherExtractor struct {
+	client *http.Client // Used to download PDFs from a URL
+	layout pdf.Layout   // Order of the text read from PDFs
+}
+
+// NewMatcherExtractor creates a new local matcher
+func NewMatcherExtractor() *MatcherExtractor {
+	return &MatcherExtractor{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// WithLayout selects the order in which the text of PDFs is read
+func (m *MatcherExtractor) WithLayout(layout pdf.Layout) *MatcherExtractor {
+	m.layout = layout
+	return m
+}
+
+// ExtractEntitiesFromPDFURL downloads the PDF and matches the entities locally
+func (m *MatcherExtractor) ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	data, err := downloadPDF(ctx, m.client, pdfURL)
+	if err != nil {
+		return ExtractionResponse{}, err
+	}
+	return m.ExtractEntitiesFromPDFFile(ctx, bytes.NewReader(data), pdfURL, entities)
+}
+
+// ExtractEntitiesFromPDFFile matches the entities against each announcement in the PDF
+func (m *MatcherExtractor) ExtractEntitiesFromPDFFile(ctx context.Context, file io.Reader, filename string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	log.Printf("Starting local matching for file: %s", filename)
+
+	doc, err := m.layout.Read(file)
+	if err != nil {
+		return ExtractionResponse{}, fmt.Errorf("failed to extract text from %s: %w", filename, err)
+	}
+
+	notices := gazette.Split(doc.Pages)
+	result := matchNotices(notices, entities)
+	log.Printf("Local matching completed, found %d hits for %d entities in %d announcements", result.CountAnnouncements(), len(entities), len(notices))
+	return ExtractionResponse{Results: result}, nil
+}
+
+// matchNotices matches every entity against each announcement, reporting at most one hit per
+// announcement. Lines are matched one at a time, and joined with the next line to find entities
+// that wrap, so the hit points at the exact place in the PDF.
+func matchNotices(notices []gazette.Notice, entities watchlist.Watchlist) ExtractionResult {
+	result := emptyResult(entities)
+
+	for _, notice := range notices {
+		lines := noticeLines(notice)
+
+		for j, entity := range entities {
+			start, end, strategy, found := matchLines(lines, entity)
+			if !found {
+				continue
+			}
+
+			announcement := Announcement{
+				Kind:     ParseKind(notice.Section),
+				ID:       notice.ID,
+				Quote:    joinLines(lines[start:end]),
+				Page:     notice.Lines[start].Page,
+				Line:     notice.Lines[start].Number,
+				Context:  contextLines(lines, start, end),
+				Strategy: strategy,
+				Verified: true, // Found in the text itself
+			}
+
+			// An announcement may list the numbers of several people or companies, so the
+			// watched number or the one closest to the match is reported
+			announcement.CPR = nearestNumber(lines, start, end, func(line string) string {
+				if cprs := ident.FindCPRs(line); len(cprs) > 0 {
+					return cprs[0].Digits()
+				}
+				return ""
+			})
+			announcement.CVR = nearestNumber(lines, start, end, func(line string) string {
+				if cvrs := ident.FindCVRs(line); len(cvrs) > 0 {
+					return cvrs[0].String()
+				}
+				return ""
+			})
+			switch entity.Kind {
+			case watchlist.KindCPR:
+				if cpr, err := ident.ParseCPR(entity.Value); err == nil {
+					announcement.CPR = cpr.Digits()
+				}
+			case watchlist.KindCVR:
+				if cvr, err := ident.ParseCVR(entity.Value); err == nil {
+					announcement.CVR = cvr.String()
+				}
+			}
+
+			announcement.Confidence = confidence(announcement, true)
+			result[j].Announcements = append(result[j].Announcements, announcement)
+		}
+	}
+
+	return result
+}
+
+// noticeLines returns the text of each line of the notice
+func noticeLines(notice gazette.Notice) []string {
+	lines := make([]string, 0, len(notice.Lines))
+	for _, line := range notice.Lines {
+		lines = append(lines, line.Text)
+	}
+	return lines
+}
+
+// matchLines returns the first line (or pair of lines, for entities that wrap) matching the entity
+func matchLines(lines []string, entity watchlist.Entity) (start, end int, strategy MatchStrategy, found bool) {
+	for i, line := range lines {
+		strategy, found = matchEntity(line, entity)
+		end = i + 1
+
+		// An address may continue on the next line with a postnummer or floor that rules it out
+		if found && strategy == StrategyAddress && i+1 < len(lines) && !matches(line+" "+lines[i+1], entity) {
+			found = false
+		}
+
+		// Prefer a stronger match over a partial one when the entity continues on the next line
+		if (!found || strategy == StrategyPartial) && i+1 < len(lines) && !matches(lines[i+1], entity) {
+			if joined, ok := matchEntity(line+" "+lines[i+1], entity); ok && (!found || joined != StrategyPartial) {
+				strategy, found, end = joined, true, i+2
+			}
+		}
+
+		if found {
+			return i, end, strategy, true
+		}
+	}
+	return 0, 0, "", false
+}
+
+// nearestNumber returns the number found by find on the matched lines from start to end, or
+// else on the closest line around them. On a tie the line before wins, as the numbers of the
+// deceased in Statstidende come before the name. It returns "" if no line has a number.
+func nearestNumber(lines []string, start, end int, find func(line string) string) string {
+	for _, line := range lines[start:end] {
+		if number := find(line); number != "" {
+			return number
+		}
+	}
+	for d := 1; start-d >= 0 || end-1+d < len(lines); d++ {
+		if start-d >= 0 {
+			if number := find(lines[start-d]); number != "" {
+				return number
+			}
+		}
+		if end-1+d < len(lines) {
+			if number := find(lines[end-1+d]); number != "" {
+				return number
+			}
+		}
+	}
+	return ""
+}
+
+// matches reports whether the entity is found in the text
+func matches(text string, entity watchlist.Entity) bool {
+	_, found := matchEntity(text, entity)
+	return found
+}
+
+// contextLines returns the lines from start to end with up to matcherContextLines on either side
+func contextLines(lines []string, start, end int) []string {
+	from := start - matcherContextLines
+	if from < 0 {
+		from = 0
+	}
+	to := end + matcherContextLines
+	if to > len(lines) {
+		to = len(lines)
+	}
+
+	context := make([]string, 0, to-from)
+	for _, line := range lines[from:to] {
+		context = append(context, strings.TrimSpace(line))
+	}
+	return context
+}
+
+// joinLines joins trimmed lines with a single space
+func joinLines(lines []string) string {
+	trimmed := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed = append(trimmed, strings.TrimSpace(line))
+	}
+	return strings.Join(trimmed, " ")
+}
@@ -0,0 +1,147 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"egobot/internal/gazette"
+	"egobot/internal/pdf"
+	"egobot/internal/watchlist"
+)
+
+func TestMatcherExtractor_SamplePDF(t *testing.T) {
+	file, err := os.Open("../../statstidende_sample.pdf")
+	if err != nil {
+		t.Fatalf("Failed to open sample PDF: %v", err)
+	}
+	defer file.Close()
+
+	entities := watchlist.FromStrings([]string{"Karl Erik Hansen", "1307360752", "Xyzzy Plugh"})
+	response, err := NewMatcherExtractor().ExtractEntitiesFromPDFFile(context.Background(), file, "statstidende_sample.pdf", entities)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(response.Results) != len(entities) {
+		t.Fatalf("Expected %d matches, got %d", len(entities), len(response.Results))
+	}
+
+	match, _ := response.Results.Match("Karl Erik Hansen")
+	if !match.Found() {
+		t.Fatal("Expected Karl Erik Hansen to be found")
+	}
+	hit := match.Announcements[0]
+	if hit.Kind != KindDoedsbo {
+		t.Errorf("Expected kind %s, got %s", KindDoedsbo, hit.Kind)
+	}
+	if hit.Strategy != StrategyExact {
+		t.Errorf("Expected strategy %s, got %s", StrategyExact, hit.Strategy)
+	}
+	if hit.Confidence < 0.9 {
+		t.Errorf("Expected an exact match found in the text to be confident, got %v", hit.Confidence)
+	}
+	if hit.ID != "S17072025-3" {
+		t.Errorf("Expected announcement S17072025-3, got %s", hit.ID)
+	}
+	if hit.Page < 2 || hit.Line == 0 {
+		t.Errorf("Expected a page and line, got page %d line %d", hit.Page, hit.Line)
+	}
+	if hit.CPR != "0611370555" {
+		t.Errorf("Expected the CPR number of the deceased, got %q", hit.CPR)
+	}
+	if !containsLine(hit.Context, "CPR-nr.: 0611370555") {
+		t.Errorf("Expected the CPR number in the context, got %v", hit.Context)
+	}
+
+	if match, _ := response.Results.Match("1307360752"); !match.Found() {
+		t.Error("Expected the CPR number to be found")
+	}
+	if match, _ := response.Results.Match("Xyzzy Plugh"); match.Found() {
+		t.Errorf("Expected no hits for Xyzzy Plugh, got %+v", match.Announcements)
+	}
+}
+
+func TestMatcherExtractor_Layout(t *testing.T) {
+	data := readSamplePDF(t)
+	entities := watchlist.FromStrings([]string{"ACEZONE ApS"})
+
+	// The column layout cites the printed lines
+	response, err := NewMatcherExtractor().WithLayout(pdf.LayoutColumns).ExtractEntitiesFromPDFFile(context.Background(), bytes.NewReader(data), "statstidende_sample.pdf", entities)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	match, _ := response.Results.Match("ACEZONE ApS")
+	if len(match.Announcements) != 1 {
+		t.Fatalf("Expected one hit for ACEZONE ApS, got %+v", match.Announcements)
+	}
+	if hit := match.Announcements[0]; hit.Page != 44 || hit.CVR != "39293056" || !containsLine(hit.Context, "Handelsrettens skifteret taget") {
+		t.Errorf("Expected ACEZONE ApS on page 44 with its printed lines, got %+v", hit)
+	}
+}
+
+func TestMatchNotices(t *testing.T) {
+	notices := gazette.Split([]pdf.Page{
+		{Number: 3, Text: "Dødsboer\nProklama\nS17072025-152\nAfdøde\nCPR-nr.: 080162-0450\nJette Fries\nLundsted\nHusmandsvej 1"},
+		{Number: 4, Text: "Tvangsauktioner\nAuktion\nS17072025-60\nMatr.nr. 12a Nykøbing\nHusmandsvej 1, 4800 Nykøbing F\nHusmandsvej 1 set igen"},
+	})
+
+	result := matchNotices(notices, watchlist.FromStrings([]string{"0801620450", "Jette Fries Lundsted", "Husmandsvej 1"}))
+
+	cpr, _ := result.Match("0801620450")
+	if len(cpr.Announcements) != 1 || cpr.Announcements[0].Strategy != StrategyCPR {
+		t.Fatalf("Expected one CPR hit for the CPR number, got %+v", cpr.Announcements)
+	}
+	if hit := cpr.Announcements[0]; hit.Page != 3 || hit.Line != 5 || hit.ID != "S17072025-152" {
+		t.Errorf("Expected S17072025-152 on page 3 line 5, got %s on page %d line %d", hit.ID, hit.Page, hit.Line)
+	}
+
+	// The name wraps onto the next line
+	name, _ := result.Match("Jette Fries Lundsted")
+	if len(name.Announcements) != 1 || name.Announcements[0].Quote != "Jette Fries Lundsted" {
+		t.Errorf("Expected the wrapped name to be found once, got %+v", name.Announcements)
+	}
+
+	// One hit per announcement, and the context stays within it
+	address, _ := result.Match("Husmandsvej 1")
+	if len(address.Announcements) != 2 {
+		t.Fatalf("Expected 2 hits for the address, got %d", len(address.Announcements))
+	}
+	if address.Announcements[0].Kind != KindDoedsbo || address.Announcements[1].Kind != KindTvangsauktion {
+		t.Errorf("Expected kinds from the section headings, got %s and %s", address.Announcements[0].Kind, address.Announcements[1].Kind)
+	}
+	if containsLine(address.Announcements[0].Context, "S17072025-60") {
+		t.Errorf("Expected context to stop at the announcement boundary, got %v", address.Announcements[0].Context)
+	}
+}
+
+func TestMatchNotices_SeveralNumbers(t *testing.T) {
+	notices := gazette.Split([]pdf.Page{
+		{Number: 5, Text: "Dødsboer\nProklama\nS17072025-152\nAfdøde\nCPR-nr.: 080162-0450\nDødsdato: 14.03.2025\nJette Fries Lundsted\nHusmandsvej 1\nTidligere afdød ægtefælle\nCPR-nr.: 060541-0146\nDødsdato: 02.01.2020\nOle Lundsted"},
+	})
+
+	result := matchNotices(notices, watchlist.FromStrings([]string{"Jette Fries Lundsted", "Ole Lundsted", "0605410146"}))
+
+	// Each person is reported with their own number, not the first in the announcement
+	for _, test := range []struct{ entity, cpr string }{
+		{"Jette Fries Lundsted", "0801620450"},
+		{"Ole Lundsted", "0605410146"},
+		{"0605410146", "0605410146"},
+	} {
+		match, _ := result.Match(test.entity)
+		if len(match.Announcements) != 1 || match.Announcements[0].CPR != test.cpr {
+			t.Errorf("Expected %s with CPR %s, got %+v", test.entity, test.cpr, match.Announcements)
+		}
+	}
+}
+
+// containsLine reports whether one of the lines equals the given line
+func containsLine(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
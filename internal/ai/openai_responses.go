@@ -0,0 +1,243 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"egobot/internal/httpretry"
+)
+
+// DefaultOpenAIModel is used when no model is configured.
+// 200k tokens per minute limit (should be enough for 1000 pages)
+const DefaultOpenAIModel = "gpt-4o-mini"
+
+// DefaultOpenAIBaseURL is used when no base URL is configured
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAIResponsesProvider talks to OpenAI's Responses API, which can read PDFs from a URL
+type OpenAIResponsesProvider struct {
+	apiKey   string
+	model    string
+	endpoint string
+	files    string // Files API endpoint, for PDFs uploaded ahead of the request
+	client   *http.Client
+	retry    httpretry.Policy
+}
+
+// NewOpenAIResponsesProvider creates a provider for the OpenAI Responses API.
+// The base URL allows pointing the provider at a proxy or a fake server in tests.
+func NewOpenAIResponsesProvider(baseURL, apiKey, model string) *OpenAIResponsesProvider {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	return &OpenAIResponsesProvider{
+		apiKey:   apiKey,
+		model:    model,
+		endpoint: strings.TrimRight(baseURL, "/") + "/responses",
+		files:    strings.TrimRight(baseURL, "/") + "/files",
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		retry: httpretry.Default,
+	}
+}
+
+// Name identifies the provider in logs
+func (p *OpenAIResponsesProvider) Name() string {
+	return "OpenAI"
+}
+
+// Model returns the model the provider sends requests to
+func (p *OpenAIResponsesProvider) Model() string {
+	return p.model
+}
+
+// Complete sends the prompt (and PDF URL or uploaded PDF, if any) to the Responses API
+func (p *OpenAIResponsesProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	content := []map[string]interface{}{}
+	if req.FileURL != "" {
+		content = append(content, map[string]interface{}{
+			"type":     "input_file",
+			"file_url": req.FileURL,
+		})
+	}
+	if req.FileID != "" {
+		content = append(content, map[string]interface{}{
+			"type":    "input_file",
+			"file_id": req.FileID,
+		})
+	}
+	content = append(content, map[string]interface{}{
+		"type": "input_text",
+		"text": req.Prompt,
+	})
+
+	// Prepare the request payload using the new Responses API format
+	requestBody := map[string]interface{}{
+		"model": p.model,
+		"input": []map[string]interface{}{
+			{
+				"role":    "user",
+				"content": content,
+			},
+		},
+	}
+	if req.Schema != nil {
+		requestBody["text"] = map[string]interface{}{
+			"format": map[string]interface{}{
+				"type":   "json_schema",
+				"name":   req.Schema.Name,
+				"strict": true,
+				"schema": req.Schema.Schema,
+			},
+		}
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + p.apiKey,
+	}
+
+	body, err := postJSON(ctx, p.client, p.Name(), p.endpoint, headers, requestBody, p.retry)
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+
+	return parseResponsesAPIBody(body)
+}
+
+// uploadExpiry is how long OpenAI keeps an uploaded PDF that is not deleted, e.g. because the
+// process stopped before it could clean up
+const uploadExpiry = 24 * time.Hour
+
+// UploadFile uploads the PDF to the Files API for the model to read as user data
+func (p *OpenAIResponsesProvider) UploadFile(ctx context.Context, filename string, data []byte) (string, error) {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	writer.WriteField("purpose", "user_data")
+	writer.WriteField("expires_after[anchor]", "created_at")
+	writer.WriteField("expires_after[seconds]", strconv.Itoa(int(uploadExpiry.Seconds())))
+	part, err := writer.CreateFormFile("file", path.Base(filename))
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload: %w", err)
+	}
+	part.Write(data)
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	// An upload that may have reached the server is not retried, as every attempt that
+	// arrives creates a file
+	policy := p.retry
+	policy.NotIdempotent = true
+	_, body, err := policy.Do(ctx, p.client, p.Name()+" file upload", func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", p.files, bytes.NewReader(form.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s file upload failed: %w", p.Name(), err)
+	}
+
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &file); err != nil || file.ID == "" {
+		return "", fmt.Errorf("%s file upload returned no file ID: %s", p.Name(), body)
+	}
+	log.Printf("Uploaded %s (%d bytes) as %s", filename, len(data), file.ID)
+	return file.ID, nil
+}
+
+// DeleteFile deletes an uploaded file from the Files API
+func (p *OpenAIResponsesProvider) DeleteFile(ctx context.Context, fileID string) error {
+	_, _, err := p.retry.Do(ctx, p.client, p.Name()+" file deletion", func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "DELETE", p.files+"/"+url.PathEscape(fileID), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", fileID, err)
+	}
+	log.Printf("Deleted uploaded file %s", fileID)
+	return nil
+}
+
+// parseResponsesAPIBody extracts the answer text from a Responses API response body
+func parseResponsesAPIBody(body []byte) (CompletionResponse, error) {
+	// Parse response
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return CompletionResponse{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Check for API-level errors in the response
+	if errorField, exists := response["error"]; exists && errorField != nil {
+		return CompletionResponse{}, fmt.Errorf("OpenAI API returned error: %v", errorField)
+	}
+
+	// Check if response is completed
+	status, ok := response["status"].(string)
+	if !ok || status != "completed" {
+		return CompletionResponse{}, fmt.Errorf("response not completed, status: %v", status)
+	}
+
+	// Extract the answer from the new Responses API format
+	output, ok := response["output"].([]interface{})
+	if !ok || len(output) == 0 {
+		return CompletionResponse{}, fmt.Errorf("no output in response")
+	}
+
+	outputItem, ok := output[0].(map[string]interface{})
+	if !ok {
+		return CompletionResponse{}, fmt.Errorf("invalid output format")
+	}
+
+	content, ok := outputItem["content"].([]interface{})
+	if !ok || len(content) == 0 {
+		return CompletionResponse{}, fmt.Errorf("no content in output")
+	}
+
+	contentItem, ok := content[0].(map[string]interface{})
+	if !ok {
+		return CompletionResponse{}, fmt.Errorf("invalid content format")
+	}
+
+	answer, ok := contentItem["text"].(string)
+	if !ok {
+		return CompletionResponse{}, fmt.Errorf("invalid text format")
+	}
+
+	log.Printf("Received answer, length: %d", len(answer))
+
+	model, _ := response["model"].(string)
+	completion := CompletionResponse{
+		Text:  answer,
+		Model: model,
+	}
+	if usage, ok := response["usage"].(map[string]interface{}); ok {
+		inputTokens, _ := usage["input_tokens"].(float64)
+		outputTokens, _ := usage["output_tokens"].(float64)
+		completion.InputTokens, completion.OutputTokens = int(inputTokens), int(outputTokens)
+	}
+	return completion, nil
+}
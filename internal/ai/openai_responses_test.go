@@ -0,0 +1,242 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"egobot/internal/ai/aitest"
+	"egobot/internal/watchlist"
+)
+
+// newTestResponsesProvider creates a provider pointed at the fake server, with retries that don't slow the tests down
+func newTestResponsesProvider(server *aitest.Server) *OpenAIResponsesProvider {
+	provider := NewOpenAIResponsesProvider(server.BaseURL(), "sk-test", "gpt-4o-mini")
+	provider.retry.BaseDelay = time.Millisecond
+	return provider
+}
+
+// servePDF serves the sample PDF, which the extractor downloads to verify the model's answer
+func servePDF(t *testing.T) string {
+	data, err := os.ReadFile("../../statstidende_sample.pdf")
+	if err != nil {
+		t.Skipf("Sample PDF not available: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/statstidende.pdf"
+}
+
+func TestOpenAIResponsesProvider_ExtractFromURL(t *testing.T) {
+	pdfURL := servePDF(t)
+	server := aitest.NewServer(t)
+	server.Enqueue(aitest.CompletedJSON("gpt-4o-mini-2024-07-18", matchesPayload{
+		Matches: []matchPayload{
+			{
+				Entity:         "ACEZONE ApS",
+				AnnouncementID: "S17072025-23",
+				CaseType:       "konkursbo",
+				Name:           "ACEZONE ApS",
+				CVR:            "39293056",
+				Address:        "Nordre Fasanvej 113, 2, 2000 Frederiksberg",
+				PetitionDate:   "15.07.2025",
+				Quote:          "under konkursbehandling på grundlag af en begæring modtaget den 15.07.2025",
+			},
+			{
+				// Made up by the model: the CVR number doesn't occur in the announcement
+				Entity:         "ACEZONE ApS",
+				AnnouncementID: "S17072025-23",
+				CaseType:       "konkursbo",
+				Name:           "ACEZONE ApS",
+				CVR:            "61126228",
+			},
+		},
+	}))
+
+	extractor := NewLLMExtractor(newTestResponsesProvider(server))
+	response, err := extractor.ExtractEntitiesFromPDFURL(context.Background(), pdfURL, watchlist.FromStrings([]string{"ACEZONE ApS", "Lægårdsvej 12A"}))
+	if err != nil {
+		t.Fatalf("ExtractEntitiesFromPDFURL failed: %v", err)
+	}
+
+	if len(response.Results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(response.Results))
+	}
+	match, _ := response.Results.Match("ACEZONE ApS")
+	if len(match.Announcements) != 2 || match.Announcements[0].CVR != "39293056" {
+		t.Fatalf("Unexpected announcements for ACEZONE ApS: %+v", match.Announcements)
+	}
+	if !match.Announcements[0].Verified {
+		t.Errorf("Expected the announcement to be verified against the PDF, unverified: %v", match.Announcements[0].Unverified)
+	}
+	if match.Announcements[1].Verified || len(match.Announcements[1].Unverified) != 1 || match.Announcements[1].Unverified[0] != "cvr" {
+		t.Errorf("Expected the made up CVR number to be flagged, got %+v", match.Announcements[1])
+	}
+	if match, _ := response.Results.Match("Lægårdsvej 12A"); match.Found() {
+		t.Errorf("Expected no announcements for Lægårdsvej 12A, got %+v", match.Announcements)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(requests))
+	}
+	request := requests[0]
+	if request.Path != "/v1/responses" {
+		t.Errorf("Expected path /v1/responses, got %s", request.Path)
+	}
+	if got := request.Header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("Expected bearer token, got %q", got)
+	}
+
+	body, err := request.JSON()
+	if err != nil {
+		t.Fatalf("Request body is not JSON: %v", err)
+	}
+	if body["model"] != "gpt-4o-mini" {
+		t.Errorf("Expected model gpt-4o-mini, got %v", body["model"])
+	}
+	if !strings.Contains(string(request.Body), `"file_url":"`+pdfURL+`"`) {
+		t.Errorf("Expected the PDF URL as input_file, got %s", request.Body)
+	}
+	format := body["text"].(map[string]interface{})["format"].(map[string]interface{})
+	if format["type"] != "json_schema" || format["strict"] != true {
+		t.Errorf("Expected strict json_schema format, got %v", format)
+	}
+}
+
+func TestOpenAIResponsesProvider_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		replies      []aitest.Reply
+		wantErr      string
+		wantRequests int
+	}{
+		{
+			name:         "retries after rate limit",
+			replies:      []aitest.Reply{aitest.RateLimited(), aitest.RateLimited(), aitest.Completed("gpt-4o-mini", `{"matches":[]}`)},
+			wantRequests: 3,
+		},
+		{
+			name:         "rate limit exhausts retries",
+			replies:      []aitest.Reply{aitest.RateLimited(), aitest.RateLimited(), aitest.RateLimited(), aitest.RateLimited()},
+			wantErr:      "rate limit exceeded",
+			wantRequests: 4,
+		},
+		{
+			name:         "retries after server error",
+			replies:      []aitest.Reply{aitest.ServerError(http.StatusInternalServerError), aitest.ServerError(http.StatusBadGateway), aitest.Completed("gpt-4o-mini", `{"matches":[]}`)},
+			wantRequests: 3,
+		},
+		{
+			name:         "server error exhausts retries",
+			replies:      []aitest.Reply{aitest.ServerError(http.StatusServiceUnavailable), aitest.ServerError(http.StatusServiceUnavailable), aitest.ServerError(http.StatusServiceUnavailable), aitest.ServerError(http.StatusServiceUnavailable)},
+			wantErr:      "HTTP 503",
+			wantRequests: 4,
+		},
+		{
+			name:         "client error is not retried",
+			replies:      []aitest.Reply{aitest.ServerError(http.StatusBadRequest)},
+			wantErr:      "HTTP 400",
+			wantRequests: 1,
+		},
+		{
+			name:         "incomplete response",
+			replies:      []aitest.Reply{aitest.Incomplete("gpt-4o-mini", "max_output_tokens")},
+			wantErr:      "response not completed, status: incomplete",
+			wantRequests: 1,
+		},
+		{
+			name:         "error in response body",
+			replies:      []aitest.Reply{aitest.APIError("The model is overloaded")},
+			wantErr:      "The model is overloaded",
+			wantRequests: 1,
+		},
+		{
+			name:         "malformed body",
+			replies:      []aitest.Reply{aitest.Malformed()},
+			wantErr:      "failed to parse response",
+			wantRequests: 1,
+		},
+		{
+			name:         "answer is not valid JSON",
+			replies:      []aitest.Reply{aitest.Completed("gpt-4o-mini", "Jeg fandt ingen matches.")},
+			wantErr:      "failed to parse structured answer",
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := aitest.NewServer(t)
+			server.Enqueue(tt.replies...)
+
+			extractor := NewLLMExtractor(newTestResponsesProvider(server))
+			_, err := extractor.ExtractEntitiesFromPDFURL(context.Background(), "https://example.com/statstidende.pdf", watchlist.FromStrings([]string{"ACEZONE ApS"}))
+
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+
+			requests := server.Requests()
+			if len(requests) != tt.wantRequests {
+				t.Fatalf("Expected %d requests, got %d", tt.wantRequests, len(requests))
+			}
+			// Every attempt must carry the full request body
+			for i, request := range requests {
+				if _, err := request.JSON(); err != nil {
+					t.Errorf("Request %d has an invalid body: %v", i+1, err)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAIResponsesProvider_UploadFileRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		replies      []aitest.Reply
+		wantErr      string
+		wantRequests int
+	}{
+		{
+			name:         "retries after rate limit",
+			replies:      []aitest.Reply{aitest.RateLimited(), aitest.FileUploaded("file-1")},
+			wantRequests: 2,
+		},
+		{
+			// The file may have been created, so a retry could create another
+			name:         "server error is not retried",
+			replies:      []aitest.Reply{aitest.ServerError(http.StatusInternalServerError)},
+			wantErr:      "HTTP 500",
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := aitest.NewServer(t)
+			server.Enqueue(tt.replies...)
+
+			_, err := newTestResponsesProvider(server).UploadFile(context.Background(), "statstidende.pdf", []byte("%PDF-1.4"))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if got := len(server.Requests()); got != tt.wantRequests {
+				t.Errorf("Expected %d requests, got %d", tt.wantRequests, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Supported LLM providers
+const (
+	ProviderOpenAI           = "openai"            // OpenAI Responses API
+	ProviderOpenAICompatible = "openai-compatible" // Chat completions on a self-hosted server (Ollama, vLLM, LM Studio)
+	ProviderAzure            = "azure"             // Azure OpenAI chat completions
+)
+
+// ErrFileInputUnsupported is returned by providers that cannot read PDFs themselves.
+// Callers should extract the text locally and send that instead.
+var ErrFileInputUnsupported = errors.New("provider does not support PDF file input")
+
+// LLMProvider sends a single prompt to a language model and returns its answer
+type LLMProvider interface {
+	// Name identifies the provider in logs
+	Name() string
+	// Model returns the model the provider sends requests to
+	Model() string
+	// Complete sends the request and returns the model's text answer
+	Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error)
+}
+
+// FileUploader is implemented by providers that can take PDFs uploaded ahead of the request, so
+// the model reads a local PDF itself. Providers wrapping another return ErrFileInputUnsupported
+// if the wrapped provider can't.
+type FileUploader interface {
+	// UploadFile uploads the PDF and returns the ID to send as CompletionRequest.FileID
+	UploadFile(ctx context.Context, filename string, data []byte) (string, error)
+	// DeleteFile deletes an uploaded file once it is no longer needed
+	DeleteFile(ctx context.Context, fileID string) error
+}
+
+// CompletionRequest is a provider-independent request for a structured answer
+type CompletionRequest struct {
+	Prompt  string      // Instructions, followed by the gazette text when analysing text
+	FileURL string      // Optional URL of a PDF the model should read itself
+	FileID  string      // Optional ID of a PDF uploaded with FileUploader.UploadFile
+	Schema  *JSONSchema // Optional schema the answer must conform to
+}
+
+// CompletionResponse is the model's answer to a CompletionRequest
+type CompletionResponse struct {
+	Text         string `json:"text"`
+	Model        string `json:"model"`         // Model that produced the answer, as reported by the API
+	InputTokens  int    `json:"input_tokens"`  // Tokens in the prompt (and PDF), as reported by the API
+	OutputTokens int    `json:"output_tokens"` // Tokens in the answer, as reported by the API
+}
+
+// JSONSchema describes the structured output the model must return
+type JSONSchema struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+// ProviderConfig holds the settings needed to create an LLMProvider
+type ProviderConfig struct {
+	Provider string // One of ProviderOpenAI, ProviderOpenAICompatible or ProviderAzure
+	APIKey   string
+	Model    string
+	BaseURL  string // Base URL of the OpenAI API or an OpenAI-compatible server, e.g. http://localhost:11434/v1
+
+	AzureEndpoint   string // e.g. https://my-resource.openai.azure.com
+	AzureDeployment string
+	AzureAPIVersion string
+}
+
+// NewProvider creates the LLMProvider selected by the configuration
+func NewProvider(config ProviderConfig) (LLMProvider, error) {
+	switch config.Provider {
+	case "", ProviderOpenAI:
+		if config.APIKey == "" {
+			return nil, fmt.Errorf("API key is required for provider %s", ProviderOpenAI)
+		}
+		return NewOpenAIResponsesProvider(config.BaseURL, config.APIKey, config.Model), nil
+	case ProviderOpenAICompatible:
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("base URL is required for provider %s", ProviderOpenAICompatible)
+		}
+		return NewChatCompletionsProvider(config.BaseURL, config.APIKey, config.Model), nil
+	case ProviderAzure:
+		if config.AzureEndpoint == "" || config.AzureDeployment == "" || config.APIKey == "" {
+			return nil, fmt.Errorf("endpoint, deployment and API key are required for provider %s", ProviderAzure)
+		}
+		return NewAzureOpenAIProvider(config.AzureEndpoint, config.AzureDeployment, config.AzureAPIVersion, config.APIKey), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", config.Provider)
+	}
+}
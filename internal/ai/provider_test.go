@@ -0,0 +1,96 @@
+package ai
+
+import (
+	"testing"
+)
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   ProviderConfig
+		expected string
+		model    string
+		wantErr  bool
+	}{
+		{
+			name:     "default is OpenAI",
+			config:   ProviderConfig{APIKey: "sk-test"},
+			expected: "OpenAI",
+			model:    DefaultOpenAIModel,
+		},
+		{
+			name:     "OpenAI-compatible",
+			config:   ProviderConfig{Provider: ProviderOpenAICompatible, BaseURL: "http://localhost:11434/v1", Model: "llama3.1"},
+			expected: "OpenAI-compatible",
+			model:    "llama3.1",
+		},
+		{
+			name:     "Azure",
+			config:   ProviderConfig{Provider: ProviderAzure, APIKey: "key", AzureEndpoint: "https://example.openai.azure.com", AzureDeployment: "gpt-4o-mini"},
+			expected: "Azure OpenAI",
+			model:    "gpt-4o-mini",
+		},
+		{
+			name:    "OpenAI without API key",
+			config:  ProviderConfig{Provider: ProviderOpenAI},
+			wantErr: true,
+		},
+		{
+			name:    "OpenAI-compatible without base URL",
+			config:  ProviderConfig{Provider: ProviderOpenAICompatible},
+			wantErr: true,
+		},
+		{
+			name:    "unknown provider",
+			config:  ProviderConfig{Provider: "mystery"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		provider, err := NewProvider(test.config)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got provider %s", test.name, provider.Name())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", test.name, err)
+			continue
+		}
+		if provider.Name() != test.expected {
+			t.Errorf("%s: expected provider %s, got %s", test.name, test.expected, provider.Name())
+		}
+		if provider.Model() != test.model {
+			t.Errorf("%s: expected model %s, got %s", test.name, test.model, provider.Model())
+		}
+	}
+}
+
+func TestParseChatCompletionBody(t *testing.T) {
+	body := []byte(`{
+		"model": "llama3.1",
+		"choices": [{"message": {"role": "assistant", "content": "{\"matches\": []}"}, "finish_reason": "stop"}],
+		"usage": {"prompt_tokens": 1200, "completion_tokens": 35, "total_tokens": 1235}
+	}`)
+
+	completion, err := parseChatCompletionBody(body)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if completion.Text != `{"matches": []}` {
+		t.Errorf("Unexpected answer: %s", completion.Text)
+	}
+	if completion.Model != "llama3.1" {
+		t.Errorf("Expected model llama3.1, got %s", completion.Model)
+	}
+	if completion.InputTokens != 1200 || completion.OutputTokens != 35 {
+		t.Errorf("Expected 1200 input and 35 output tokens, got %d and %d", completion.InputTokens, completion.OutputTokens)
+	}
+
+	truncated := []byte(`{"choices": [{"message": {"content": "{\"matches\": ["}, "finish_reason": "length"}]}`)
+	if _, err := parseChatCompletionBody(truncated); err == nil {
+		t.Error("Expected error for truncated answer")
+	}
+}
@@ -0,0 +1,195 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// fileInputTokens is the estimate for a request where the model reads a PDF from a URL, whose
+// size is unknown until the answer reports it. A Statstidende issue is usually smaller.
+const fileInputTokens = 30000
+
+// outputTokens is the estimate for the answer, which lists the matches found
+const outputTokens = 1000
+
+// RateLimiter limits the requests and tokens sent per minute. Requests reserve their share
+// up front and wait until the limits allow it, so concurrent work queues up in order instead
+// of running into 429 responses.
+type RateLimiter struct {
+	mu                sync.Mutex
+	requestsPerMinute float64
+	tokensPerMinute   float64
+	requests          float64 // Requests available now, negative if reserved ahead
+	tokens            float64 // Tokens available now, negative if reserved ahead
+	last              time.Time
+	now               func() time.Time
+}
+
+// NewRateLimiter creates a limiter for the given requests and tokens per minute. A limit of
+// zero is not enforced. The limiter starts full, allowing a burst of a minute's worth.
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	return &RateLimiter{
+		requestsPerMinute: float64(requestsPerMinute),
+		tokensPerMinute:   float64(tokensPerMinute),
+		requests:          float64(requestsPerMinute),
+		tokens:            float64(tokensPerMinute),
+		last:              time.Now(),
+		now:               time.Now,
+	}
+}
+
+// Wait blocks until a request of the given estimated size may be sent, or the context is done
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	delay := l.reserve(tokens)
+	if delay <= 0 {
+		return nil
+	}
+
+	log.Printf("Rate limit reached, waiting %v before sending the request", delay.Round(time.Millisecond))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the reservation back to the requests queued after this one
+		l.release(tokens)
+		return ctx.Err()
+	}
+}
+
+// adjust corrects the tokens reserved for a request once the actual number is known
+func (l *RateLimiter) adjust(estimated, actual int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens += float64(estimated - actual)
+	if l.tokens > l.tokensPerMinute {
+		l.tokens = l.tokensPerMinute
+	}
+}
+
+// release gives back the reservation of a request that was not sent
+func (l *RateLimiter) release(tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.requests = minFloat(l.requests+1, l.requestsPerMinute)
+	l.tokens = minFloat(l.tokens+minFloat(float64(tokens), l.tokensPerMinute), l.tokensPerMinute)
+}
+
+// reserve takes a request and the tokens from the limiter and returns how long the caller
+// must wait before the limiter would have had them
+func (l *RateLimiter) reserve(tokens int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+
+	var delay time.Duration
+	if l.requestsPerMinute > 0 {
+		l.requests--
+		delay = maxDuration(delay, shortfall(l.requests, l.requestsPerMinute))
+	}
+	if l.tokensPerMinute > 0 {
+		// A request larger than the limit could never be sent, so it waits for a full minute
+		l.tokens -= minFloat(float64(tokens), l.tokensPerMinute)
+		delay = maxDuration(delay, shortfall(l.tokens, l.tokensPerMinute))
+	}
+	return delay
+}
+
+// refill adds what the limits allow for the time since the last refill, up to a minute's worth
+func (l *RateLimiter) refill() {
+	now := l.now()
+	minutes := now.Sub(l.last).Minutes()
+	l.last = now
+	l.requests = minFloat(l.requests+minutes*l.requestsPerMinute, l.requestsPerMinute)
+	l.tokens = minFloat(l.tokens+minutes*l.tokensPerMinute, l.tokensPerMinute)
+}
+
+// shortfall returns how long it takes to refill a negative balance at the given rate per minute
+func shortfall(available, perMinute float64) time.Duration {
+	if available >= 0 {
+		return 0
+	}
+	return time.Duration(-available / perMinute * float64(time.Minute))
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// RateLimitedProvider sends the requests of another provider through a rate limiter, which
+// may be shared by several providers using the same API key
+type RateLimitedProvider struct {
+	provider LLMProvider
+	limiter  *RateLimiter
+}
+
+// NewRateLimitedProvider wraps the provider with the limiter
+func NewRateLimitedProvider(provider LLMProvider, limiter *RateLimiter) *RateLimitedProvider {
+	return &RateLimitedProvider{provider: provider, limiter: limiter}
+}
+
+// Name identifies the wrapped provider in logs
+func (p *RateLimitedProvider) Name() string {
+	return p.provider.Name()
+}
+
+// Model returns the model of the wrapped provider
+func (p *RateLimitedProvider) Model() string {
+	return p.provider.Model()
+}
+
+// UploadFile uploads the PDF with the wrapped provider. Uploads don't count against the limits.
+func (p *RateLimitedProvider) UploadFile(ctx context.Context, filename string, data []byte) (string, error) {
+	uploader, ok := p.provider.(FileUploader)
+	if !ok {
+		return "", ErrFileInputUnsupported
+	}
+	return uploader.UploadFile(ctx, filename, data)
+}
+
+// DeleteFile deletes an uploaded file with the wrapped provider
+func (p *RateLimitedProvider) DeleteFile(ctx context.Context, fileID string) error {
+	uploader, ok := p.provider.(FileUploader)
+	if !ok {
+		return ErrFileInputUnsupported
+	}
+	return uploader.DeleteFile(ctx, fileID)
+}
+
+// Complete waits for the limiter before sending the request, and corrects the reserved tokens
+// with those the API reports having used
+func (p *RateLimitedProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	estimated := estimateTokens(req.Prompt) + outputTokens
+	if req.FileURL != "" || req.FileID != "" {
+		estimated += fileInputTokens
+	}
+	if err := p.limiter.Wait(ctx, estimated); err != nil {
+		return CompletionResponse{}, err
+	}
+
+	completion, err := p.provider.Complete(ctx, req)
+	switch {
+	case errors.Is(err, ErrFileInputUnsupported):
+		// Nothing was sent, the text is sent in another request
+		p.limiter.release(estimated)
+	case err == nil && completion.InputTokens+completion.OutputTokens > 0:
+		p.limiter.adjust(estimated, completion.InputTokens+completion.OutputTokens)
+	}
+	return completion, err
+}
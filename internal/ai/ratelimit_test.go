@@ -0,0 +1,104 @@
+package ai
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"egobot/internal/ai/aitest"
+)
+
+// newTestRateLimiter creates a limiter whose clock only moves when the test advances it
+func newTestRateLimiter(requestsPerMinute, tokensPerMinute int) (*RateLimiter, func(time.Duration)) {
+	now := time.Date(2025, 7, 17, 6, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(requestsPerMinute, tokensPerMinute)
+	limiter.last = now
+	limiter.now = func() time.Time { return now }
+	return limiter, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestRateLimiter_Requests(t *testing.T) {
+	limiter, advance := newTestRateLimiter(2, 0)
+
+	// A minute's worth is available at once, then requests queue up behind each other
+	expected := []time.Duration{0, 0, 30 * time.Second, time.Minute}
+	for i, want := range expected {
+		if got := limiter.reserve(100); got != want {
+			t.Errorf("Request %d: expected to wait %v, got %v", i+1, want, got)
+		}
+	}
+
+	// A minute later the queued requests have been sent
+	advance(time.Minute)
+	if got := limiter.reserve(100); got != 30*time.Second {
+		t.Errorf("Expected the queue to have moved a minute, got %v", got)
+	}
+}
+
+func TestRateLimiter_Tokens(t *testing.T) {
+	limiter, _ := newTestRateLimiter(0, 1000)
+
+	if got := limiter.reserve(600); got != 0 {
+		t.Errorf("Expected no wait, got %v", got)
+	}
+	if got := limiter.reserve(600); got != 12*time.Second {
+		t.Errorf("Expected to wait for 200 tokens, got %v", got)
+	}
+
+	// The answers used fewer tokens than estimated
+	limiter.adjust(600, 300)
+	limiter.adjust(600, 300)
+	if got := limiter.reserve(400); got != 0 {
+		t.Errorf("Expected the refund to be available, got %v", got)
+	}
+
+	// A request larger than the limit waits for a full minute rather than forever
+	limiter, _ = newTestRateLimiter(0, 1000)
+	limiter.reserve(1000)
+	if got := limiter.reserve(5000); got != time.Minute {
+		t.Errorf("Expected to wait a minute, got %v", got)
+	}
+}
+
+func TestRateLimiter_WaitCanceled(t *testing.T) {
+	limiter, _ := newTestRateLimiter(1, 0)
+	limiter.reserve(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx, 0); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	// The canceled request gave its place back
+	if got := limiter.reserve(0); got != time.Minute {
+		t.Errorf("Expected to wait a minute, got %v", got)
+	}
+}
+
+func TestRateLimitedProvider(t *testing.T) {
+	server := aitest.NewServer(t)
+	server.Enqueue(aitest.CompletedWithUsage("gpt-4o-mini", `{"matches": []}`, 400, 100))
+
+	limiter, _ := newTestRateLimiter(10, 100000)
+	provider := NewRateLimitedProvider(newTestResponsesProvider(server), limiter)
+	if _, err := provider.Complete(context.Background(), CompletionRequest{Prompt: "Analyser"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	// The estimate was replaced by the 500 tokens the API reported
+	if limiter.requests != 9 || limiter.tokens != 99500 {
+		t.Errorf("Expected 9 requests and 99500 tokens left, got %v and %v", limiter.requests, limiter.tokens)
+	}
+
+	// Requests the provider can't send are given back
+	chat := NewRateLimitedProvider(NewChatCompletionsProvider("http://localhost:1/v1", "", "llama3.1"), limiter)
+	if _, err := chat.Complete(context.Background(), CompletionRequest{Prompt: "Analyser", FileURL: "https://example.com/a.pdf"}); err != ErrFileInputUnsupported {
+		t.Fatalf("Expected ErrFileInputUnsupported, got %v", err)
+	}
+	if limiter.requests != 9 || limiter.tokens != 99500 {
+		t.Errorf("Expected the reservation to be given back, got %v and %v", limiter.requests, limiter.tokens)
+	}
+	if _, err := chat.UploadFile(context.Background(), "a.pdf", []byte("%PDF-1.4")); err != ErrFileInputUnsupported {
+		t.Errorf("Expected uploads to be unsupported, got %v", err)
+	}
+}
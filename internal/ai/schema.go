@@ -0,0 +1,168 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"egobot/internal/ident"
+	"egobot/internal/watchlist"
+)
+
+// matchPayload is a single match as returned by the model. Dates are kept as
+// the strings the model wrote and converted when building the Announcement.
+type matchPayload struct {
+	Entity         string `json:"entity"`
+	AnnouncementID string `json:"announcement_id"`
+	CaseType       string `json:"case_type"`
+	Name           string `json:"name"`
+	CPR            string `json:"cpr"`
+	CVR            string `json:"cvr"`
+	Address        string `json:"address"`
+	Matrikel       string `json:"matrikel"`
+	DeathDate      string `json:"death_date"`
+	PetitionDate   string `json:"petition_date"`
+	Quote          string `json:"quote"`
+	// Confidence is the model's own assessment from 0 to 1 that the announcement concerns the entity
+	Confidence *float64 `json:"confidence,omitempty"`
+}
+
+// matchesPayload is the JSON document the model is asked to return
+type matchesPayload struct {
+	Matches []matchPayload `json:"matches"`
+}
+
+// announcement converts the model's match into the typed Announcement
+func (p matchPayload) announcement() Announcement {
+	announcement := Announcement{
+		Kind:         ParseKind(p.CaseType),
+		ID:           strings.TrimSpace(p.AnnouncementID),
+		Name:         strings.TrimSpace(p.Name),
+		CPR:          normalizeCPR(p.CPR),
+		DeathDate:    parseDanishDate(p.DeathDate),
+		CVR:          normalizeCVR(p.CVR),
+		PetitionDate: parseDanishDate(p.PetitionDate),
+		Matrikel:     strings.TrimSpace(p.Matrikel),
+		Address:      strings.TrimSpace(p.Address),
+		Quote:        strings.TrimSpace(p.Quote),
+	}
+	if p.Confidence != nil {
+		c := clampConfidence(*p.Confidence)
+		announcement.ModelConfidence = &c
+	}
+	announcement.Confidence = confidence(announcement, false)
+	return announcement
+}
+
+// normalizeCPR returns the digits of a valid CPR number, or the trimmed input as the model wrote it
+func normalizeCPR(s string) string {
+	if cpr, err := ident.ParseCPR(s); err == nil {
+		return cpr.Digits()
+	}
+	return strings.TrimSpace(s)
+}
+
+// normalizeCVR returns the digits of a valid CVR number, or the trimmed input as the model wrote it
+func normalizeCVR(s string) string {
+	if cvr, err := ident.ParseCVR(s); err == nil {
+		return cvr.String()
+	}
+	return strings.TrimSpace(s)
+}
+
+// matchesSchema returns the JSON schema that forces the model to answer with a matchesPayload
+func matchesSchema() *JSONSchema {
+	stringField := map[string]interface{}{"type": "string"}
+	dateField := map[string]interface{}{
+		"type":        "string",
+		"description": "Dato på formen DD.MM.ÅÅÅÅ eller tom streng",
+	}
+
+	match := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"entity":          stringField,
+			"announcement_id": stringField,
+			"case_type": map[string]interface{}{
+				"type": "string",
+				"enum": []string{"dødsbo", "konkursbo", "tvangsauktion", "andet"},
+			},
+			"name":          stringField,
+			"cpr":           stringField,
+			"cvr":           stringField,
+			"address":       stringField,
+			"matrikel":      stringField,
+			"death_date":    dateField,
+			"petition_date": dateField,
+			"quote":         stringField,
+			"confidence": map[string]interface{}{
+				"type":        "number",
+				"description": "Hvor sikker du er på, at kundgørelsen vedrører entiteten, fra 0 til 1",
+			},
+		},
+		"required": []string{
+			"entity", "announcement_id", "case_type", "name", "cpr", "cvr", "address",
+			"matrikel", "death_date", "petition_date", "quote", "confidence",
+		},
+		"additionalProperties": false,
+	}
+
+	return &JSONSchema{
+		Name: "statstidende_matches",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"matches": map[string]interface{}{
+					"type":  "array",
+					"items": match,
+				},
+			},
+			"required":             []string{"matches"},
+			"additionalProperties": false,
+		},
+	}
+}
+
+// parseMatches parses the model's JSON answer and groups the announcements by the requested entities.
+// Every requested entity is present in the result, with no announcements if nothing was found.
+func parseMatches(answer string, entities watchlist.Watchlist) (ExtractionResult, error) {
+	var payload matchesPayload
+	if err := json.Unmarshal([]byte(answer), &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse structured answer: %w", err)
+	}
+
+	result := emptyResult(entities)
+	for _, match := range payload.Matches {
+		// The model may change casing, whitespace or number formatting, so map back to the entity as it was requested
+		index := -1
+		for i := range result {
+			if sameEntity(result[i], match.Entity) {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			result = append(result, Match{Entity: strings.TrimSpace(match.Entity)})
+			index = len(result) - 1
+		}
+		result[index].Announcements = append(result[index].Announcements, match.announcement())
+	}
+
+	return result, nil
+}
+
+// sameEntity reports whether the entity reported by the model is the one in the match
+func sameEntity(match Match, reported string) bool {
+	if strings.EqualFold(strings.TrimSpace(match.Entity), strings.TrimSpace(reported)) {
+		return true
+	}
+	switch match.EntityKind {
+	case watchlist.KindCPR:
+		cpr, err := ident.ParseCPR(reported)
+		return err == nil && cpr.Digits() == match.Entity
+	case watchlist.KindCVR:
+		cvr, err := ident.ParseCVR(reported)
+		return err == nil && cvr.String() == match.Entity
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"testing"
+	"time"
+
+	"egobot/internal/watchlist"
+)
+
+func TestParseMatches(t *testing.T) {
+	answer := `{
+		"matches": [
+			{
+				"entity": "benny gotfred schmidt",
+				"case_type": "dødsbo",
+				"name": "Benny Gotfred Schmidt",
+				"cpr": "0605410146",
+				"cvr": "",
+				"address": "Lægårdsvej 12A, 8000 Aarhus C",
+				"matrikel": "",
+				"death_date": "14.03.2025",
+				"petition_date": "",
+				"quote": "Afdøde CPR-nr.: 0605410146 Dødsdato: 14.03.2025 Benny Gotfred Schmidt",
+				"confidence": 0.9
+			},
+			{
+				"entity": "0605410146",
+				"case_type": "dødsbo",
+				"name": "Benny Gotfred Schmidt",
+				"cpr": "0605410146",
+				"cvr": "",
+				"address": "",
+				"matrikel": "",
+				"death_date": "",
+				"petition_date": "",
+				"quote": "CPR-nr.: 0605410146",
+				"confidence": 1.4
+			},
+			{
+				"entity": "ACEZONE ApS",
+				"case_type": "konkursbo",
+				"name": "ACEZONE ApS",
+				"cpr": "",
+				"cvr": "39293056",
+				"address": "Nordre Fasanvej 113, 2, 2000 Frederiksberg",
+				"matrikel": "",
+				"death_date": "",
+				"petition_date": "15.07.2025",
+				"quote": "under konkursbehandling på grundlag af en begæring modtaget den 15.07.2025"
+			}
+		]
+	}`
+	entities := watchlist.FromStrings([]string{"Benny Gotfred Schmidt", "0605410146", "Lægårdsvej 12A"})
+
+	result, err := parseMatches(answer, entities)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Requested entities come first in request order, unrequested entities are appended
+	if len(result) != 4 {
+		t.Fatalf("Expected 4 matches, got %d", len(result))
+	}
+	for i, entity := range entities {
+		if result[i].Entity != entity.Value {
+			t.Errorf("Expected match %d to be %s, got %s", i, entity, result[i].Entity)
+		}
+	}
+
+	// Entity names returned by the model are mapped back to the requested spelling
+	benny := result[0]
+	if len(benny.Announcements) != 1 {
+		t.Fatalf("Expected 1 announcement for Benny Gotfred Schmidt, got %d", len(benny.Announcements))
+	}
+	announcement := benny.Announcements[0]
+	if announcement.Kind != KindDoedsbo {
+		t.Errorf("Expected kind %s, got %s", KindDoedsbo, announcement.Kind)
+	}
+	if announcement.CPR != "0605410146" {
+		t.Errorf("Expected CPR 0605410146, got %s", announcement.CPR)
+	}
+	if announcement.DeathDate == nil || !announcement.DeathDate.Equal(time.Date(2025, time.March, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected death date 2025-03-14, got %v", announcement.DeathDate)
+	}
+	if announcement.PetitionDate != nil {
+		t.Errorf("Expected no petition date, got %v", announcement.PetitionDate)
+	}
+
+	// Until the announcements are verified, the confidence is the model's own, limited to 0..1
+	if announcement.ModelConfidence == nil || *announcement.ModelConfidence != 0.9 || announcement.Confidence != 0.9 {
+		t.Errorf("Expected the model's confidence 0.9, got %v and %v", announcement.ModelConfidence, announcement.Confidence)
+	}
+	if c := result[1].Announcements[0].Confidence; c != 1 {
+		t.Errorf("Expected the confidence to be clamped to 1, got %v", c)
+	}
+	if a := result[3].Announcements[0]; a.ModelConfidence != nil || a.Confidence != 0.5 {
+		t.Errorf("Expected a neutral confidence without the model's, got %v and %v", a.ModelConfidence, a.Confidence)
+	}
+
+	// Entities without matches are present but empty
+	if result[2].Found() {
+		t.Errorf("Expected no announcements for address, got %v", result[2].Announcements)
+	}
+
+	if result.CountAnnouncements() != 3 {
+		t.Errorf("Expected 3 announcements in total, got %d", result.CountAnnouncements())
+	}
+
+	bankruptcies := result.Filter(KindKonkursbo)
+	if bankruptcies.CountAnnouncements() != 1 {
+		t.Errorf("Expected 1 bankruptcy, got %d", bankruptcies.CountAnnouncements())
+	}
+	if bankruptcies[3].Announcements[0].CVR != "39293056" {
+		t.Errorf("Expected CVR 39293056, got %s", bankruptcies[3].Announcements[0].CVR)
+	}
+}
+
+func TestParseMatches_InvalidJSON(t *testing.T) {
+	_, err := parseMatches("Her er den relevante information:", watchlist.FromStrings([]string{"test"}))
+	if err == nil {
+		t.Error("Expected error for non-JSON answer")
+	}
+}
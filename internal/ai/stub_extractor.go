@@ -2,129 +2,211 @@ package ai
 
 import (
 	"context"
+	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"os"
+	"path"
+	"sort"
 	"strings"
 	"time"
+
+	"egobot/internal/watchlist"
 )
 
-// StubExtractor provides fake but realistic responses for testing
-type StubExtractor struct{}
+// DefaultStubLatency is how long the stub takes to answer if its scenarios don't say otherwise
+const DefaultStubLatency = 100 * time.Millisecond
 
-// NewStubExtractor creates a new stub extractor
-func NewStubExtractor() *StubExtractor {
-	return &StubExtractor{}
-}
+//go:embed stubs/*.json
+var stubFixtures embed.FS
 
-// ExtractEntitiesFromPDFFile provides stubbed responses for testing
-func (s *StubExtractor) ExtractEntitiesFromPDFFile(ctx context.Context, file interface{}, filename string, entities []string) (ExtractionResult, error) {
-	log.Printf("STUB: Processing PDF file: %s with entities: %v", filename, entities)
+// StubScenario is a canned answer of the stub extractor, loaded from a JSON file. A scenario
+// answers either for the entities containing Entity, or for everything in the PDF at PDFURL.
+type StubScenario struct {
+	Entity  string         `json:"entity,omitempty"`  // Answers for entities containing this, ignoring case
+	PDFURL  string         `json:"pdf_url,omitempty"` // Answers for the PDF at this URL, whatever the entities
+	Latency string         `json:"latency,omitempty"` // Time to answer, e.g. "2s"; DefaultStubLatency if empty
+	Error   string         `json:"error,omitempty"`   // Fails the extraction with this error instead of answering
+	Matches []matchPayload `json:"matches"`           // The answer, as the model would write it
+
+	name    string
+	latency time.Duration
+}
 
-	// Simulate processing time
-	time.Sleep(100 * time.Millisecond)
+// StubExtractor answers from fixture scenarios instead of calling a model, with the same results
+// and raw response as the real extractor would produce from the model's answer
+type StubExtractor struct {
+	scenarios []*StubScenario // Sorted by file name, so the first matching scenario wins
+}
 
-	// Generate realistic fake responses based on entities
-	result := make(ExtractionResult)
+// NewStubExtractor creates a stub extractor with the embedded scenarios
+func NewStubExtractor() *StubExtractor {
+	stub, err := LoadStubExtractor("")
+	if err != nil {
+		panic(err) // The embedded scenarios are covered by tests
+	}
+	return stub
+}
 
-	for _, entity := range entities {
-		entityLower := strings.ToLower(entity)
-
-		switch {
-		case strings.Contains(entityLower, "danske"):
-			result[entity] = "Danske Bank: No significant changes reported in this document. The bank continues normal operations."
-		case strings.Contains(entityLower, "fintech"):
-			result[entity] = "Fintech: Several fintech companies mentioned in regulatory updates. New compliance requirements for digital payment services."
-		case strings.Contains(entityLower, "bankruptcy"):
-			result[entity] = "Bankruptcy: Three companies filed for bankruptcy protection this period. All cases are under court supervision."
-		case strings.Contains(entityLower, "12345678"):
-			result[entity] = "VAT 12345678: Company with this VAT number has updated their board composition. New CEO appointed effective next month."
-		case strings.Contains(entityLower, "john doe"):
-			result[entity] = "John Doe: No specific mentions found for this individual in the current document."
-		default:
-			result[entity] = fmt.Sprintf("%s: Limited information available. Recommend checking previous documents for historical data.", entity)
+// LoadStubExtractor loads the embedded scenarios and then the *.json files in dir, which
+// replace the embedded scenario with the same file name. dir may be empty.
+func LoadStubExtractor(dir string) (*StubExtractor, error) {
+	scenarios := make(map[string]*StubScenario)
+	if err := loadStubScenarios(stubFixtures, "stubs", scenarios); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := loadStubScenarios(os.DirFS(dir), ".", scenarios); err != nil {
+			return nil, fmt.Errorf("failed to load stub scenarios from %s: %w", dir, err)
 		}
 	}
 
-	// Add a summary if no specific entities found
-	if len(result) == 0 {
-		result["summary"] = "Document processed successfully. No specific entities matched the search criteria."
+	stub := &StubExtractor{scenarios: make([]*StubScenario, 0, len(scenarios))}
+	for _, scenario := range scenarios {
+		stub.scenarios = append(stub.scenarios, scenario)
 	}
+	sort.Slice(stub.scenarios, func(i, j int) bool {
+		return stub.scenarios[i].name < stub.scenarios[j].name
+	})
+	return stub, nil
+}
 
-	log.Printf("STUB: Generated results for %d entities", len(result))
-	return result, nil
+// loadStubScenarios parses every scenario in the directory of the file system
+func loadStubScenarios(fsys fs.FS, dir string, scenarios map[string]*StubScenario) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		var scenario StubScenario
+		if err := json.Unmarshal(data, &scenario); err != nil {
+			return fmt.Errorf("invalid stub scenario %s: %w", file, err)
+		}
+		if (scenario.Entity == "") == (scenario.PDFURL == "") {
+			return fmt.Errorf("invalid stub scenario %s: exactly one of entity and pdf_url is required", file)
+		}
+		scenario.latency = DefaultStubLatency
+		if scenario.Latency != "" {
+			if scenario.latency, err = time.ParseDuration(scenario.Latency); err != nil {
+				return fmt.Errorf("invalid stub scenario %s: %w", file, err)
+			}
+		}
+		scenario.name = strings.TrimSuffix(path.Base(file), ".json")
+		scenarios[scenario.name] = &scenario
+	}
+	return nil
 }
 
-// ExtractEntitiesFromPDFURL provides stubbed responses for URL-based PDF analysis
-func (s *StubExtractor) ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities []string) (ExtractionResponse, error) {
-	log.Printf("STUB: Processing PDF URL: %s with entities: %v", pdfURL, entities)
+// ExtractEntitiesFromPDFFile answers from the scenarios of the entities
+func (s *StubExtractor) ExtractEntitiesFromPDFFile(ctx context.Context, file interface{}, filename string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	log.Printf("STUB: Processing PDF file: %s with entities: %v", filename, entities.Values())
+	return s.answer(ctx, s.entityScenarios(entities, nil), entities)
+}
 
-	// Simulate processing time
-	time.Sleep(100 * time.Millisecond)
+// ExtractEntitiesFromPDFURL answers from the scenario of the URL if there is one, and otherwise
+// from the scenarios of the entities
+func (s *StubExtractor) ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	log.Printf("STUB: Processing PDF URL: %s with entities: %v", pdfURL, entities.Values())
+	for _, scenario := range s.scenarios {
+		if scenario.PDFURL == pdfURL {
+			return s.answer(ctx, []*StubScenario{scenario}, entities)
+		}
+	}
+	return s.answer(ctx, s.entityScenarios(entities, nil), entities)
+}
 
-	// Generate realistic fake responses based on entities
-	result := make(ExtractionResult)
+// ExtractEntitiesFromText answers from the scenarios of the entities mentioned in the text.
+// Mentioned entities without a scenario get a generic announcement quoting the mention.
+func (s *StubExtractor) ExtractEntitiesFromText(ctx context.Context, text string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	log.Printf("STUB: Processing text (%d chars) with entities: %v", len(text), entities.Values())
+	return s.answer(ctx, s.entityScenarios(entities, func(entity watchlist.Entity) bool {
+		return strings.Contains(strings.ToLower(text), strings.ToLower(entity.Value))
+	}), entities)
+}
 
+// entityScenarios returns a scenario for each entity that has one. If mentioned is set, only
+// mentioned entities are answered for, with a generic scenario if they have none.
+func (s *StubExtractor) entityScenarios(entities watchlist.Watchlist, mentioned func(watchlist.Entity) bool) []*StubScenario {
+	var scenarios []*StubScenario
 	for _, entity := range entities {
-		entityLower := strings.ToLower(entity)
-
-		switch {
-		case strings.Contains(entityLower, "danske"):
-			result[entity] = "Danske Bank: No significant changes reported in this document. The bank continues normal operations."
-		case strings.Contains(entityLower, "fintech"):
-			result[entity] = "Fintech: Several fintech companies mentioned in regulatory updates. New compliance requirements for digital payment services."
-		case strings.Contains(entityLower, "bankruptcy"):
-			result[entity] = "Bankruptcy: Three companies filed for bankruptcy protection this period. All cases are under court supervision."
-		case strings.Contains(entityLower, "12345678"):
-			result[entity] = "VAT 12345678: Company with this VAT number has updated their board composition. New CEO appointed effective next month."
-		case strings.Contains(entityLower, "john doe"):
-			result[entity] = "John Doe: No specific mentions found for this individual in the current document."
-		default:
-			result[entity] = fmt.Sprintf("%s: Limited information available. Recommend checking previous documents for historical data.", entity)
+		if mentioned != nil && !mentioned(entity) {
+			continue
 		}
+		scenario := s.entityScenario(entity.Value)
+		if scenario == nil && mentioned != nil {
+			scenario = &StubScenario{
+				Matches: []matchPayload{{CaseType: string(KindOther), Quote: entity.Value + ": Found mentions in document. Analysis indicates normal business activities."}},
+				latency: DefaultStubLatency / 2,
+			}
+		}
+		if scenario == nil {
+			continue
+		}
+		// The scenario's matches are for this entity, as the model names them in its answer
+		answered := *scenario
+		answered.Matches = make([]matchPayload, len(scenario.Matches))
+		for i, match := range scenario.Matches {
+			match.Entity = entity.Value
+			answered.Matches[i] = match
+		}
+		scenarios = append(scenarios, &answered)
 	}
+	return scenarios
+}
 
-	// Add a summary if no specific entities found
-	if len(result) == 0 {
-		result["summary"] = "Document processed successfully. No specific entities matched the search criteria."
-	}
-
-	// Create a realistic raw response
-	rawResponse := "Her er den relevante information:\n\n"
-	for entity, info := range result {
-		rawResponse += fmt.Sprintf("### %s\n%s\n\n", entity, info)
+// entityScenario returns the first scenario for the entity, nil if there is none
+func (s *StubExtractor) entityScenario(value string) *StubScenario {
+	for _, scenario := range s.scenarios {
+		if scenario.Entity != "" && strings.Contains(strings.ToLower(value), strings.ToLower(scenario.Entity)) {
+			return scenario
+		}
 	}
-
-	log.Printf("STUB: Generated results for %d entities from URL", len(result))
-	return ExtractionResponse{
-		Results:     result,
-		RawResponse: rawResponse,
-	}, nil
+	return nil
 }
 
-// ExtractEntitiesFromText provides stubbed responses for text analysis
-func (s *StubExtractor) ExtractEntitiesFromText(ctx context.Context, text string, entities []string) (ExtractionResult, error) {
-	log.Printf("STUB: Processing text (%d chars) with entities: %v", len(text), entities)
-
-	// Simulate processing time
-	time.Sleep(50 * time.Millisecond)
-
-	// Generate responses based on text content and entities
-	result := make(ExtractionResult)
-
-	for _, entity := range entities {
-		entityLower := strings.ToLower(entity)
-		textLower := strings.ToLower(text)
+// answer waits for the longest latency of the scenarios, fails if one of them has an error,
+// and otherwise parses their matches as the real extractor parses the model's answer
+func (s *StubExtractor) answer(ctx context.Context, scenarios []*StubScenario, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	latency := DefaultStubLatency
+	if len(scenarios) > 0 {
+		latency = 0
+		for _, scenario := range scenarios {
+			latency = maxDuration(latency, scenario.latency)
+		}
+	}
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return ExtractionResponse{}, ctx.Err()
+	}
 
-		if strings.Contains(textLower, entityLower) {
-			result[entity] = fmt.Sprintf("%s: Found mentions in document. Analysis indicates normal business activities.", entity)
-		} else {
-			result[entity] = fmt.Sprintf("%s: No specific mentions found in this document section.", entity)
+	payload := matchesPayload{Matches: []matchPayload{}}
+	for _, scenario := range scenarios {
+		if scenario.Error != "" {
+			log.Printf("STUB: Failing with the error of scenario %s", scenario.name)
+			return ExtractionResponse{}, errors.New(scenario.Error)
 		}
+		payload.Matches = append(payload.Matches, scenario.Matches...)
 	}
 
-	if len(result) == 0 {
-		result["summary"] = "Text analyzed successfully. No specific entities found in this section."
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return ExtractionResponse{}, err
+	}
+	result, err := parseMatches(string(raw), entities)
+	if err != nil {
+		return ExtractionResponse{}, err
 	}
 
-	return result, nil
+	log.Printf("STUB: Generated %d announcements for %d entities", result.CountAnnouncements(), len(entities))
+	return ExtractionResponse{Results: result, RawResponse: string(raw)}, nil
 }
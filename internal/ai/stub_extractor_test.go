@@ -2,20 +2,27 @@ package ai
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"egobot/internal/watchlist"
 )
 
 func TestStubExtractor_ExtractEntitiesFromPDFFile(t *testing.T) {
 	extractor := NewStubExtractor()
 	ctx := context.Background()
 
-	entities := []string{"Danske Bank", "fintech", "12345678"}
+	entities := watchlist.FromStrings([]string{"Danske Bank", "fintech", "12345678"})
 
 	start := time.Now()
-	result, err := extractor.ExtractEntitiesFromPDFFile(ctx, nil, "test.pdf", entities)
+	response, err := extractor.ExtractEntitiesFromPDFFile(ctx, nil, "test.pdf", entities)
 	duration := time.Since(start)
+	result := response.Results
 
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
@@ -27,14 +34,16 @@ func TestStubExtractor_ExtractEntitiesFromPDFFile(t *testing.T) {
 	}
 
 	// Check specific responses
-	if !strings.Contains(result["Danske Bank"], "Danske Bank") {
-		t.Error("Expected Danske Bank response to contain entity name")
-	}
-	if !strings.Contains(result["fintech"], "fintech") {
-		t.Error("Expected fintech response to contain entity name")
+	for i, entity := range entities {
+		if result[i].Entity != entity.Value {
+			t.Errorf("Expected match %d to reference %s, got %s", i, entity, result[i].Entity)
+		}
+		if !result[i].Found() {
+			t.Errorf("Expected announcements for %s", entity)
+		}
 	}
-	if !strings.Contains(result["12345678"], "12345678") {
-		t.Error("Expected VAT response to contain entity name")
+	if vat, _ := result.Match("12345678"); len(vat.Announcements) == 0 || vat.Announcements[0].CVR != "12345678" {
+		t.Errorf("Expected CVR 12345678 in announcement, got %v", vat.Announcements)
 	}
 
 	// Check that processing took some time (simulated)
@@ -48,9 +57,13 @@ func TestStubExtractor_ExtractEntitiesFromText(t *testing.T) {
 	ctx := context.Background()
 
 	text := "This document contains information about Danske Bank and fintech companies."
-	entities := []string{"Danske Bank", "fintech", "nonexistent"}
+	entities := watchlist.FromStrings([]string{"Danske Bank", "fintech", "nonexistent"})
+
+	text += " Acme Holding ApS is mentioned as well."
+	entities = append(entities, watchlist.Entity{Kind: watchlist.KindCompany, Value: "Acme Holding"})
 
-	result, err := extractor.ExtractEntitiesFromText(ctx, text, entities)
+	response, err := extractor.ExtractEntitiesFromText(ctx, text, entities)
+	result := response.Results
 
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
@@ -62,13 +75,16 @@ func TestStubExtractor_ExtractEntitiesFromText(t *testing.T) {
 	}
 
 	// Check that entities found in text have appropriate responses
-	if !strings.Contains(result["Danske Bank"], "Found mentions") {
-		t.Error("Expected Danske Bank to be marked as found")
+	if danske, _ := result.Match("Danske Bank"); !danske.Found() || danske.Announcements[0].CVR != "61126228" {
+		t.Error("Expected Danske Bank to be answered from its scenario")
+	}
+	if fintech, _ := result.Match("fintech"); !fintech.Found() || fintech.Announcements[0].Name != "Nordic Fintech ApS" {
+		t.Error("Expected fintech to be answered from its scenario")
 	}
-	if !strings.Contains(result["fintech"], "Found mentions") {
-		t.Error("Expected fintech to be marked as found")
+	if acme, _ := result.Match("Acme Holding"); !acme.Found() || !strings.Contains(acme.Announcements[0].Quote, "Found mentions") {
+		t.Error("Expected Acme Holding, which has no scenario, to be marked as found")
 	}
-	if !strings.Contains(result["nonexistent"], "No specific mentions") {
+	if nonexistent, _ := result.Match("nonexistent"); nonexistent.Found() {
 		t.Error("Expected nonexistent entity to be marked as not found")
 	}
 }
@@ -90,7 +106,8 @@ func TestStubExtractor_RealisticResponses(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		result, err := extractor.ExtractEntitiesFromPDFFile(ctx, nil, "test.pdf", []string{tc.entity})
+		response, err := extractor.ExtractEntitiesFromPDFFile(ctx, nil, "test.pdf", watchlist.FromStrings([]string{tc.entity}))
+		result := response.Results
 
 		if err != nil {
 			t.Errorf("Error processing entity %s: %v", tc.entity, err)
@@ -102,9 +119,92 @@ func TestStubExtractor_RealisticResponses(t *testing.T) {
 			continue
 		}
 
-		response := result[tc.entity]
-		if tc.shouldFind && !strings.Contains(response, tc.entity) {
-			t.Errorf("Expected response for %s to contain entity name", tc.entity)
+		match := result[0]
+		if match.Entity != tc.entity {
+			t.Errorf("Expected match for %s, got %s", tc.entity, match.Entity)
+		}
+		if tc.shouldFind != match.Found() {
+			t.Errorf("Expected shouldFind=%v for %s, got %d announcements", tc.shouldFind, tc.entity, len(match.Announcements))
+		}
+	}
+}
+
+func TestStubExtractor_RawResponse(t *testing.T) {
+	entities := watchlist.FromStrings([]string{"John Doe", "Random Company"})
+	response, err := NewStubExtractor().ExtractEntitiesFromPDFURL(context.Background(), "https://example.com/statstidende.pdf", entities)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The raw response is the model's answer, which parses into the same results
+	parsed, err := parseMatches(response.RawResponse, entities)
+	if err != nil {
+		t.Fatalf("Expected the raw response to be a model answer, got %v", err)
+	}
+	if !reflect.DeepEqual(parsed, response.Results) {
+		t.Errorf("Expected the raw response to parse into %+v, got %+v", response.Results, parsed)
+	}
+	john := response.Results[0]
+	if len(john.Announcements) != 1 || john.Announcements[0].Kind != KindDoedsbo || john.Announcements[0].DeathDate == nil {
+		t.Errorf("Expected a dødsbo with a death date for John Doe, got %+v", john.Announcements)
+	}
+}
+
+func TestLoadStubExtractor(t *testing.T) {
+	dir := t.TempDir()
+	writeScenario := func(name, text string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
 		}
 	}
+	// Replaces the embedded scenario of the same name
+	writeScenario("danske.json", `{"entity": "danske", "matches": []}`)
+	writeScenario("outage.json", `{"entity": "outage", "latency": "1ms", "error": "simulated API outage"}`)
+	writeScenario("issue.json", `{
+		"pdf_url": "https://example.com/2025-07-17.pdf",
+		"latency": "30ms",
+		"matches": [{"entity": "Person Nummer2", "announcement_id": "S17072025-2", "case_type": "dødsbo", "name": "Person Nummer2"}]
+	}`)
+
+	stub, err := LoadStubExtractor(dir)
+	if err != nil {
+		t.Fatalf("LoadStubExtractor failed: %v", err)
+	}
+	ctx := context.Background()
+
+	response, err := stub.ExtractEntitiesFromPDFFile(ctx, nil, "test.pdf", watchlist.FromStrings([]string{"Danske Bank", "John Doe"}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.Results[0].Found() || !response.Results[1].Found() {
+		t.Errorf("Expected the overridden and the embedded scenario, got %+v", response.Results)
+	}
+
+	if _, err := stub.ExtractEntitiesFromPDFFile(ctx, nil, "test.pdf", watchlist.FromStrings([]string{"Outage Inc"})); err == nil || err.Error() != "simulated API outage" {
+		t.Errorf("Expected the injected error, got %v", err)
+	}
+
+	start := time.Now()
+	response, err = stub.ExtractEntitiesFromPDFURL(ctx, "https://example.com/2025-07-17.pdf", watchlist.FromStrings([]string{"Person Nummer2", "John Doe"}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if time.Since(start) < 30*time.Millisecond {
+		t.Error("Expected the scenario latency")
+	}
+	if !response.Results[0].Found() || response.Results[1].Found() {
+		t.Errorf("Expected the answer of the PDF scenario only, got %+v", response.Results)
+	}
+
+	// Latency is cut short by the context
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := stub.ExtractEntitiesFromPDFURL(canceled, "https://example.com/2025-07-17.pdf", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the canceled context to stop the stub, got %v", err)
+	}
+
+	writeScenario("invalid.json", `{"matches": []}`)
+	if _, err := LoadStubExtractor(dir); err == nil || !strings.Contains(err.Error(), "invalid.json") {
+		t.Errorf("Expected an error for a scenario without entity or pdf_url, got %v", err)
+	}
 }
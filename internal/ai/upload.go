@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"egobot/internal/gazette"
+	"egobot/internal/prompt"
+	"egobot/internal/watchlist"
+)
+
+// extractUploadedPDF uploads the PDF for the model to read, like a PDF at a URL. The text is
+// still extracted locally, to skip PDFs that mention none of the entities and to verify the
+// answer, but a PDF whose text can't be extracted is uploaded anyway and left unverified.
+// Providers that can't take uploads get the PDF analysed through the local text pipeline.
+func (e *LLMExtractor) extractUploadedPDF(ctx context.Context, source string, data []byte, filename string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	log.Printf("Starting PDF analysis for uploaded file: %s (provider: %s, model: %s)", filename, e.provider.Name(), e.provider.Model())
+
+	uploader, ok := e.provider.(FileUploader)
+	if !ok {
+		return e.extractUploadFallback(ctx, data, filename, entities)
+	}
+
+	var notices []gazette.Notice
+	if doc, err := e.layout.ReadAt(bytes.NewReader(data), int64(len(data))); err != nil {
+		log.Printf("Failed to extract text from %s, the announcements can't be verified: %v", filename, err)
+	} else {
+		notices = gazette.Split(doc.Pages)
+		if len(relevantNotices(notices, entities)) == 0 {
+			log.Printf("None of the %d entities found in %s, skipping analysis", len(entities), filename)
+			return ExtractionResponse{Results: emptyResult(entities)}, nil
+		}
+	}
+
+	fileID, err := e.upload(ctx, uploader, source, filename, data)
+	if errors.Is(err, ErrFileInputUnsupported) {
+		return e.extractUploadFallback(ctx, data, filename, entities)
+	}
+	if err != nil {
+		return ExtractionResponse{}, err
+	}
+
+	response, err := e.extractByPrompt(entities, func(tmpl *prompt.Template, entities watchlist.Watchlist) (ExtractionResponse, error) {
+		instructions, err := renderPrompt(tmpl, "Analyser denne udgave af statstidende", entities)
+		if err != nil {
+			return ExtractionResponse{}, err
+		}
+		return e.requestMatches(ctx, CompletionRequest{Prompt: instructions, FileID: fileID}, entities)
+	})
+	if errors.Is(err, ErrFileInputUnsupported) {
+		e.deleteUpload(ctx, uploader, source, fileID)
+		return e.extractUploadFallback(ctx, data, filename, entities)
+	}
+	if err != nil {
+		// A retry of the extraction reads the PDF that is already uploaded
+		log.Printf("Keeping uploaded file %s of %s for a retry", fileID, filename)
+		return ExtractionResponse{}, err
+	}
+
+	e.deleteUpload(ctx, uploader, source, fileID)
+	if notices != nil {
+		response.Results = verifyResult(response.Results, notices)
+	}
+	return response, nil
+}
+
+// extractUploadFallback analyses the PDF through the local text pipeline
+func (e *LLMExtractor) extractUploadFallback(ctx context.Context, data []byte, filename string, entities watchlist.Watchlist) (ExtractionResponse, error) {
+	log.Printf("%s cannot take uploaded PDFs, analysing the text locally instead", e.provider.Name())
+	return e.extractPDFFile(ctx, bytes.NewReader(data), filename, entities)
+}
+
+// upload returns the file ID of the PDF, reusing the upload of an earlier extraction that failed
+func (e *LLMExtractor) upload(ctx context.Context, uploader FileUploader, source, filename string, data []byte) (string, error) {
+	e.uploadsMu.Lock()
+	fileID, ok := e.uploads[source]
+	e.uploadsMu.Unlock()
+	if ok {
+		log.Printf("Reusing uploaded file %s for %s", fileID, filename)
+		return fileID, nil
+	}
+
+	fileID, err := uploader.UploadFile(ctx, filename, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", filename, err)
+	}
+	e.uploadsMu.Lock()
+	e.uploads[source] = fileID
+	e.uploadsMu.Unlock()
+	return fileID, nil
+}
+
+// deleteUpload deletes the uploaded PDF once its extraction is done. Failures are only logged,
+// as the upload expires by itself.
+func (e *LLMExtractor) deleteUpload(ctx context.Context, uploader FileUploader, source, fileID string) {
+	e.uploadsMu.Lock()
+	if e.uploads[source] == fileID {
+		delete(e.uploads, source)
+	}
+	e.uploadsMu.Unlock()
+
+	// Clean up even if the extraction's context ends right after the answer
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if err := uploader.DeleteFile(ctx, fileID); err != nil {
+		log.Printf("Failed to delete uploaded file %s: %v", fileID, err)
+	}
+}
@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"mime/multipart"
+	"os"
+	"strings"
+	"testing"
+
+	"egobot/internal/ai/aitest"
+	"egobot/internal/watchlist"
+)
+
+// readSamplePDF reads the sample PDF, skipping the test if it is missing
+func readSamplePDF(t *testing.T) []byte {
+	data, err := os.ReadFile("../../statstidende_sample.pdf")
+	if err != nil {
+		t.Skipf("Sample PDF not available: %v", err)
+	}
+	return data
+}
+
+func TestExtractEntitiesFromPDFFile_Upload(t *testing.T) {
+	data := readSamplePDF(t)
+	answer := `{"matches":[{"entity":"ACEZONE ApS","announcement_id":"S17072025-23","case_type":"konkursbo","name":"ACEZONE ApS","cvr":"39293056","confidence":0.9}]}`
+	server := aitest.NewServer(t)
+	server.Enqueue(aitest.FileUploaded("file-abc"), aitest.Completed("gpt-4o-mini", answer), aitest.FileDeleted("file-abc"))
+
+	extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithFileUpload(true)
+	entities := watchlist.FromStrings([]string{"ACEZONE ApS"})
+	response, err := extractor.ExtractEntitiesFromPDFFile(context.Background(), bytes.NewReader(data), "statstidende_sample.pdf", entities)
+	if err != nil {
+		t.Fatalf("ExtractEntitiesFromPDFFile failed: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("Expected an upload, a request and a deletion, got %d requests", len(requests))
+	}
+
+	// The PDF is uploaded as user data
+	upload := requests[0]
+	if upload.Method != "POST" || upload.Path != "/v1/files" {
+		t.Errorf("Expected POST /v1/files, got %s %s", upload.Method, upload.Path)
+	}
+	_, params, err := mime.ParseMediaType(upload.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("Expected a multipart upload, got %v", err)
+	}
+	form, err := multipart.NewReader(bytes.NewReader(upload.Body), params["boundary"]).ReadForm(int64(len(data)) * 2)
+	if err != nil {
+		t.Fatalf("Failed to read the upload: %v", err)
+	}
+	if purpose := form.Value["purpose"]; len(purpose) != 1 || purpose[0] != "user_data" {
+		t.Errorf("Expected purpose user_data, got %v", purpose)
+	}
+	if files := form.File["file"]; len(files) != 1 || files[0].Filename != "statstidende_sample.pdf" || files[0].Size != int64(len(data)) {
+		t.Errorf("Expected the PDF as file, got %+v", files)
+	}
+
+	// The model reads the uploaded file
+	if body := string(requests[1].Body); requests[1].Path != "/v1/responses" || !strings.Contains(body, `"file_id":"file-abc"`) {
+		t.Errorf("Expected a request referencing file-abc, got %s %s", requests[1].Path, body)
+	}
+
+	// The upload is deleted afterwards
+	if requests[2].Method != "DELETE" || requests[2].Path != "/v1/files/file-abc" {
+		t.Errorf("Expected DELETE /v1/files/file-abc, got %s %s", requests[2].Method, requests[2].Path)
+	}
+
+	// The answer is verified against the local text, which cites the page of the announcement
+	match, _ := response.Results.Match("ACEZONE ApS")
+	if len(match.Announcements) != 1 || !match.Announcements[0].Verified {
+		t.Fatalf("Expected a verified announcement, got %+v", match.Announcements)
+	}
+	if a := match.Announcements[0]; a.Page != 44 || a.Line == 0 || len(a.Context) == 0 {
+		t.Errorf("Expected the announcement to be cited on page 44, got page %d line %d", a.Page, a.Line)
+	}
+}
+
+func TestExtractEntitiesFromPDFFile_UploadReusedForRetry(t *testing.T) {
+	data := readSamplePDF(t)
+	server := aitest.NewServer(t)
+	server.Enqueue(aitest.FileUploaded("file-abc"), aitest.ServerError(400))
+
+	extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithFileUpload(true)
+	entities := watchlist.FromStrings([]string{"ACEZONE ApS"})
+	if _, err := extractor.ExtractEntitiesFromPDFFile(context.Background(), bytes.NewReader(data), "statstidende_sample.pdf", entities); err == nil {
+		t.Fatal("Expected the failed request to fail the extraction")
+	}
+
+	// The retry reads the file uploaded by the failed extraction
+	server.Enqueue(aitest.Completed("gpt-4o-mini", `{"matches":[]}`), aitest.FileDeleted("file-abc"))
+	if _, err := extractor.ExtractEntitiesFromPDFFile(context.Background(), bytes.NewReader(data), "statstidende_sample.pdf", entities); err != nil {
+		t.Fatalf("ExtractEntitiesFromPDFFile failed: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("Expected one upload, two requests and a deletion, got %d requests", len(requests))
+	}
+	if body := string(requests[2].Body); !strings.Contains(body, `"file_id":"file-abc"`) {
+		t.Errorf("Expected the retry to reference file-abc, got %s", body)
+	}
+	if requests[3].Method != "DELETE" {
+		t.Errorf("Expected the upload to be deleted after the retry, got %s %s", requests[3].Method, requests[3].Path)
+	}
+}
+
+func TestExtractEntitiesFromPDFFile_UploadSkipped(t *testing.T) {
+	data := readSamplePDF(t)
+	entities := watchlist.FromStrings([]string{"Xyzzy Plugh"})
+
+	// A PDF that mentions none of the entities is not uploaded
+	server := aitest.NewServer(t)
+	extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithFileUpload(true)
+	response, err := extractor.ExtractEntitiesFromPDFFile(context.Background(), bytes.NewReader(data), "statstidende_sample.pdf", entities)
+	if err != nil || response.Results.CountAnnouncements() != 0 || len(server.Requests()) != 0 {
+		t.Errorf("Expected no announcements and no requests, got %+v, %v and %d requests", response, err, len(server.Requests()))
+	}
+}
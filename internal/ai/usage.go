@@ -0,0 +1,94 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Usage counts the tokens spent on an extraction and their estimated cost
+type Usage struct {
+	Requests     int     `json:"requests"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost_usd"` // Estimated from the price table, 0 for models without a price
+}
+
+// Add returns the sum of the two usages
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		Requests:     u.Requests + other.Requests,
+		InputTokens:  u.InputTokens + other.InputTokens,
+		OutputTokens: u.OutputTokens + other.OutputTokens,
+		Cost:         u.Cost + other.Cost,
+	}
+}
+
+// TotalTokens returns the sum of input and output tokens
+func (u Usage) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// Price is the price of a model in USD per million tokens
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Prices are model prices by model name prefix
+type Prices map[string]Price
+
+// DefaultPrices are OpenAI's list prices. Self-hosted models are free and have no entry.
+var DefaultPrices = Prices{
+	"gpt-5":         {Input: 1.25, Output: 10},
+	"gpt-5-mini":    {Input: 0.25, Output: 2},
+	"gpt-5-nano":    {Input: 0.05, Output: 0.40},
+	"gpt-4.1":       {Input: 2, Output: 8},
+	"gpt-4.1-mini":  {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":  {Input: 0.10, Output: 0.40},
+	"gpt-4o":        {Input: 2.50, Output: 10},
+	"gpt-4o-mini":   {Input: 0.15, Output: 0.60},
+	"gpt-4-turbo":   {Input: 10, Output: 30},
+	"gpt-4":         {Input: 30, Output: 60},
+	"gpt-3.5-turbo": {Input: 0.50, Output: 1.50},
+	"o3":            {Input: 2, Output: 8},
+	"o4-mini":       {Input: 1.10, Output: 4.40},
+}
+
+// ParsePrices parses a JSON price table such as {"gpt-4o-mini":{"input":0.15,"output":0.6}}
+// and returns the default prices with the parsed entries added or replaced
+func ParsePrices(s string) (Prices, error) {
+	prices := make(Prices, len(DefaultPrices))
+	for model, price := range DefaultPrices {
+		prices[model] = price
+	}
+	if strings.TrimSpace(s) == "" {
+		return prices, nil
+	}
+
+	var custom Prices
+	if err := json.Unmarshal([]byte(s), &custom); err != nil {
+		return nil, fmt.Errorf("failed to parse prices: %w", err)
+	}
+	for model, price := range custom {
+		if price.Input < 0 || price.Output < 0 {
+			return nil, fmt.Errorf("price of %s must not be negative", model)
+		}
+		prices[strings.ToLower(model)] = price
+	}
+	return prices, nil
+}
+
+// Cost estimates the cost in USD of the tokens, using the price of the longest model name
+// prefix in the table. Models without a price cost nothing.
+func (p Prices) Cost(model string, inputTokens, outputTokens int) float64 {
+	model = strings.ToLower(model)
+	var price Price
+	longest := 0
+	for prefix, candidate := range p {
+		if strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			price, longest = candidate, len(prefix)
+		}
+	}
+	return (float64(inputTokens)*price.Input + float64(outputTokens)*price.Output) / 1e6
+}
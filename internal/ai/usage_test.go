@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"egobot/internal/ai/aitest"
+	"egobot/internal/watchlist"
+)
+
+func TestPricesCost(t *testing.T) {
+	prices, err := ParsePrices(`{"my-azure-deployment": {"input": 1, "output": 2}, "gpt-4o-mini": {"input": 0.3, "output": 1.2}}`)
+	if err != nil {
+		t.Fatalf("ParsePrices failed: %v", err)
+	}
+
+	tests := []struct {
+		model    string
+		expected float64
+	}{
+		{model: "gpt-4o-mini-2024-07-18", expected: 0.3 + 1.2*0.1}, // Replaced, and the dated model uses the longest prefix
+		{model: "gpt-4o", expected: 2.5 + 10*0.1},                  // Default kept
+		{model: "my-azure-deployment", expected: 1 + 2*0.1},
+		{model: "llama3.1", expected: 0}, // Self-hosted
+	}
+	for _, tt := range tests {
+		if got := prices.Cost(tt.model, 1000000, 100000); math.Abs(got-tt.expected) > 1e-9 {
+			t.Errorf("Cost(%s) = %f, expected %f", tt.model, got, tt.expected)
+		}
+	}
+
+	if _, err := ParsePrices(`{"gpt-4o": {"input": -1}}`); err == nil {
+		t.Error("Expected an error for a negative price")
+	}
+	if _, err := ParsePrices(`gpt-4o=1`); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+}
+
+func TestExtractEntitiesFromText_Usage(t *testing.T) {
+	server := aitest.NewServer(t)
+	for i := 0; i < 20; i++ {
+		server.Enqueue(aitest.CompletedWithUsage("gpt-4o-mini-2024-07-18", `{"matches": []}`, 1000, 10))
+	}
+
+	extractor := NewLLMExtractor(newTestResponsesProvider(server)).WithContextWindow(3000)
+	response, err := extractor.ExtractEntitiesFromText(context.Background(), joinNotices(sampleNotices(40)), watchlist.FromStrings([]string{"Person Nummer7"}))
+	if err != nil {
+		t.Fatalf("ExtractEntitiesFromText failed: %v", err)
+	}
+
+	// The usage of every chunk is added up
+	requests := len(server.Requests())
+	if requests < 2 {
+		t.Fatalf("Expected several chunks, got %d requests", requests)
+	}
+	usage := response.Usage
+	if usage.Requests != requests || usage.InputTokens != 1000*requests || usage.OutputTokens != 10*requests {
+		t.Errorf("Expected the usage of %d requests, got %+v", requests, usage)
+	}
+	expected := float64(requests) * (1000*0.15 + 10*0.6) / 1e6
+	if math.Abs(usage.Cost-expected) > 1e-12 {
+		t.Errorf("Expected cost %f, got %f", expected, usage.Cost)
+	}
+}
@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+
+	"egobot/internal/gazette"
+	"egobot/internal/watchlist"
+)
+
+// verifyResult checks every announcement reported by the model against the PDF text, so values
+// the model made up are flagged before they are mailed to clients. Values are looked up in the
+// announcement with the reported number, or anywhere in the text if the number is unknown.
+// The strategy that finds the entity in that text is recorded and weighs in on the confidence,
+// and the page and line of the announcement are cited like those of the local matcher.
+func verifyResult(result ExtractionResult, notices []gazette.Notice) ExtractionResult {
+	byID := make(map[string]string, len(notices))
+	noticeByID := make(map[string]gazette.Notice, len(notices))
+	texts := make([]string, 0, len(notices))
+	for _, notice := range notices {
+		text := strings.Join(strings.Fields(notice.Text()), " ")
+		if notice.ID != "" {
+			byID[notice.ID] = text
+			noticeByID[notice.ID] = notice
+		}
+		texts = append(texts, text)
+	}
+	fullText := strings.Join(texts, " ")
+
+	verified, total := 0, 0
+	for i, match := range result {
+		entity := watchlist.Entity{Kind: match.EntityKind, Value: match.Entity}
+		for j := range match.Announcements {
+			announcement := &result[i].Announcements[j]
+			text, ok := byID[announcement.ID]
+			if !ok {
+				text = fullText
+			}
+			announcement.Unverified = unverifiedFields(*announcement, entity, text)
+			announcement.Verified = len(announcement.Unverified) == 0
+			announcement.Strategy, _ = matchEntity(text, entity)
+			announcement.Confidence = confidence(*announcement, true)
+			if notice, ok := noticeByID[announcement.ID]; ok && announcement.Page == 0 {
+				cite(announcement, notice, entity)
+			}
+			total++
+			if announcement.Verified {
+				verified++
+			}
+		}
+	}
+
+	log.Printf("Verified %d of %d announcements against the PDF text", verified, total)
+	return result
+}
+
+// cite records the page and line where the notice mentions the entity, or where the notice
+// starts if the entity isn't found, with the lines around it. Notices from text without page
+// numbers are not cited.
+func cite(announcement *Announcement, notice gazette.Notice, entity watchlist.Entity) {
+	if notice.Page() == 0 {
+		return
+	}
+	lines := noticeLines(notice)
+	start, end, _, found := matchLines(lines, entity)
+	if !found {
+		start, end = 0, 1
+	}
+	announcement.Page = notice.Lines[start].Page
+	announcement.Line = notice.Lines[start].Number
+	announcement.Context = contextLines(lines, start, end)
+}
+
+// unverifiedFields returns the fields of the announcement whose values don't occur in the text.
+// The watched entity itself must occur as well, as the model may attribute an announcement to
+// the wrong entity. Values are matched with the strategies for their kind, as when filtering.
+func unverifiedFields(announcement Announcement, entity watchlist.Entity, text string) []string {
+	nameKind := watchlist.KindPerson
+	if announcement.Kind == KindKonkursbo {
+		nameKind = watchlist.KindCompany
+	}
+
+	var unverified []string
+	for _, field := range []struct {
+		name   string
+		entity watchlist.Entity
+	}{
+		{"entity", entity},
+		{"name", watchlist.Entity{Kind: nameKind, Value: announcement.Name}},
+		{"cpr", watchlist.Entity{Kind: watchlist.KindCPR, Value: announcement.CPR}},
+		{"cvr", watchlist.Entity{Kind: watchlist.KindCVR, Value: announcement.CVR}},
+		{"address", watchlist.Entity{Kind: watchlist.KindAddress, Value: announcement.Address}},
+		{"matrikel", watchlist.Entity{Kind: watchlist.KindMatrikel, Value: announcement.Matrikel}},
+	} {
+		if strings.TrimSpace(field.entity.Value) == "" {
+			continue
+		}
+		if !matches(text, field.entity) {
+			unverified = append(unverified, field.name)
+		}
+	}
+	return unverified
+}
+
+// verifyURL downloads the PDF the model read from the URL and verifies the result against its text.
+// If the PDF cannot be read, the announcements are left unverified.
+func (e *LLMExtractor) verifyURL(ctx context.Context, pdfURL string, result ExtractionResult) ExtractionResult {
+	if result.CountAnnouncements() == 0 {
+		return result
+	}
+
+	data, err := downloadPDF(ctx, e.client, pdfURL)
+	if err != nil {
+		log.Printf("Could not verify the announcements, failed to download %s: %v", pdfURL, err)
+		return result
+	}
+	doc, err := e.layout.ReadAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		log.Printf("Could not verify the announcements, failed to extract text from %s: %v", pdfURL, err)
+		return result
+	}
+	return verifyResult(result, gazette.Split(doc.Pages))
+}
@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"egobot/internal/gazette"
+	"egobot/internal/watchlist"
+)
+
+func TestVerifyResult(t *testing.T) {
+	notices := gazette.SplitText(strings.Join([]string{
+		"Dødsboer", "Proklama",
+		"S17072025-152", "Afdøde", "CPR-nr.: 080162-0450", "Jette Fries", "Lundsted", "Husmandsvej 1", "4990 Sakskøbing",
+		"S17072025-154", "Afdøde", "CPR-nr.: 120345-1234", "Dorte Bente Jørgensen", "Fabriksvej 8",
+	}, "\n"))
+
+	result := ExtractionResult{
+		{
+			Entity:     "Jette Fries Lundsted",
+			EntityKind: watchlist.KindPerson,
+			Announcements: []Announcement{
+				// Everything occurs in the announcement, the name wraps to the next line
+				{Kind: KindDoedsbo, ID: "S17072025-152", Name: "Jette Fries Lundsted", CPR: "0801620450", Address: "Husmandsvej 1, 4990 Sakskøbing"},
+				// Values from the next announcement attributed to this one
+				{Kind: KindDoedsbo, ID: "S17072025-152", Name: "Jette Fries Lundsted", CPR: "1203451234", Address: "Fabriksvej 8"},
+				// Without a known number the whole text is searched
+				{Kind: KindDoedsbo, Name: "Jette Fries Lundsted", CPR: "080162-0450"},
+			},
+		},
+		{
+			Entity:     "0801620450",
+			EntityKind: watchlist.KindCPR,
+			Announcements: []Announcement{
+				// The watched entity doesn't occur in the announcement the model reported
+				{Kind: KindDoedsbo, ID: "S17072025-154", Name: "Dorte Bente Jørgensen"},
+			},
+		},
+	}
+
+	verified := verifyResult(result, notices)
+
+	expected := [][]string{{}, {"cpr", "address"}, {}, {"entity"}}
+	var got [][]string
+	for _, match := range verified {
+		for _, announcement := range match.Announcements {
+			unverified := announcement.Unverified
+			if unverified == nil {
+				unverified = []string{}
+			}
+			if announcement.Verified != (len(unverified) == 0) {
+				t.Errorf("Expected Verified to reflect the unverified fields, got %+v", announcement)
+			}
+			got = append(got, unverified)
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected unverified fields %v, got %v", expected, got)
+	}
+	if verified.CountUnverified() != 2 {
+		t.Errorf("Expected 2 unverified announcements, got %d", verified.CountUnverified())
+	}
+
+	// The strategy finding the entity and the verification decide the confidence
+	expectedStrategies := []MatchStrategy{StrategyExact, StrategyExact, StrategyExact, ""}
+	expectedPossible := []bool{false, false, false, true}
+	i := 0
+	for _, match := range verified.MarkPossible(DefaultConfidenceThreshold) {
+		for _, announcement := range match.Announcements {
+			if announcement.Strategy != expectedStrategies[i] || announcement.Possible != expectedPossible[i] {
+				t.Errorf("Announcement %d: expected strategy %q and possible %v, got %+v", i, expectedStrategies[i], expectedPossible[i], announcement)
+			}
+			i++
+		}
+	}
+	if a, b := verified[0].Announcements[0].Confidence, verified[0].Announcements[1].Confidence; a <= b {
+		t.Errorf("Expected a verified announcement to be more confident than one with unverified values, got %v and %v", a, b)
+	}
+}
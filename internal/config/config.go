@@ -1,19 +1,78 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
-	"strings"
 	"time"
+
+	"egobot/internal/ai"
+	"egobot/internal/pdf"
+	"egobot/internal/watchlist"
 )
 
 // Config holds all configuration for the application
 type Config struct {
 	// OpenAI settings
-	OpenAIAPIKey string
-	OpenAIStub   bool // If true, use stubbed responses instead of real API calls
+	OpenAIAPIKey  string
+	OpenAIBaseURL string // Base URL of the OpenAI API, e.g. to go through a proxy
+	OpenAIStub    bool   // If true, use stubbed responses instead of real API calls
+
+	// StubFixturesDir holds *.json scenarios for the stubbed responses, which override or extend the embedded ones
+	StubFixturesDir string
+
+	// ExtractorMode selects how PDFs are analysed: "llm" (default) or "matcher" for local matching without a model
+	ExtractorMode string
+
+	// PDFLayout selects the order in which the text of PDFs is read: "plain" (default) in the
+	// order it is drawn, or "columns" to put each column back together from the glyph positions
+	PDFLayout pdf.Layout
+
+	// LLM provider settings
+	LLMProvider string // "openai" (default), "openai-compatible" or "azure"
+	LLMModel    string // Model name, or deployment name for Azure
+	LLMBaseURL  string // Base URL of an OpenAI-compatible server (Ollama, vLLM, LM Studio)
+	LLMAPIKey   string // Optional API key for an OpenAI-compatible server
+
+	// LLMFileUpload uploads PDF attachments for the model to read itself (OpenAI only), instead of
+	// sending the text of the announcements that mention an entity
+	LLMFileUpload bool
+
+	// LLMContextWindow overrides the context window in tokens derived from the model name,
+	// which decides how large chunks of a big issue are. 0 derives it from the model.
+	LLMContextWindow int
+
+	// Client-side rate limits shared by all requests of the process, so concurrent work queues
+	// up instead of running into 429 responses. 0 disables a limit.
+	LLMRequestsPerMinute int
+	LLMTokensPerMinute   int
+
+	// LLMPrices estimate the cost of the tokens spent, by model name prefix. LLM_PRICES adds to
+	// or replaces entries of the default OpenAI prices, e.g. to price an Azure deployment.
+	LLMPrices ai.Prices
+
+	// Extraction cache, so retried runs and publications received twice are not paid for again
+	CacheStore  string        // "memory" (default), "disk" or "none"
+	CacheDir    string        // Directory of the disk cache
+	CacheTTL    time.Duration // How long results are cached
+	CacheBypass bool          // If true, cached results are ignored but fresh ones are still cached
+
+	// Recording of the model's answers, so a run can be reproduced offline against the answers of
+	// that day: "record" writes them to LLMCassetteDir, "replay" serves them instead of calling the model
+	LLMCassette    string
+	LLMCassetteDir string
+
+	// ConfidenceThreshold is the confidence from 0 to 1 below which matches are reported as possible
+	ConfidenceThreshold float64
+
+	// Prompt templates: the embedded templates can be overridden or extended with *.tmpl files in PromptDir
+	PromptDir      string // Directory with prompt templates, empty to use only the embedded ones
+	PromptTemplate string // Template used for entities that don't name one
+
+	AzureOpenAIEndpoint   string
+	AzureOpenAIDeployment string
+	AzureOpenAIAPIVersion string
+	AzureOpenAIAPIKey     string
 
 	// Email settings
 	IMAPServer   string
@@ -30,17 +89,77 @@ type Config struct {
 	SMTPTo       string
 
 	// Processing settings
-	EntitiesToTrack []string
-	ScheduleCron    string
-	MaxRetries      int
-	RetryDelay      time.Duration
+	Watchlist    watchlist.Watchlist // Entities to look for, from ENTITIES_TO_TRACK
+	ScheduleCron string
+	MaxRetries   int
+	RetryDelay   time.Duration
 }
 
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
+	config, err := LoadExtractor()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.IMAPUsername == "" {
+		return nil, fmt.Errorf("IMAP_USERNAME is required")
+	}
+	if config.IMAPPassword == "" {
+		return nil, fmt.Errorf("IMAP_PASSWORD is required")
+	}
+	if config.SMTPFrom == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required")
+	}
+	if config.SMTPTo == "" {
+		return nil, fmt.Errorf("SMTP_TO is required")
+	}
+
+	return config, nil
+}
+
+// LoadExtractor loads the configuration like Load, but doesn't require the email settings,
+// for tools that only run the extractor
+func LoadExtractor() (*Config, error) {
 	config := &Config{
-		OpenAIAPIKey: getEnvOrDefault("OPENAI_API_KEY", ""),
-		OpenAIStub:   getEnvBoolOrDefault("OPENAI_STUB", true), // Default to stubbed for safety
+		OpenAIAPIKey:  getEnvOrDefault("OPENAI_API_KEY", ""),
+		OpenAIBaseURL: getEnvOrDefault("OPENAI_BASE_URL", "https://api.openai.com/v1"),
+		OpenAIStub:    getEnvBoolOrDefault("OPENAI_STUB", true), // Default to stubbed for safety
+
+		StubFixturesDir: getEnvOrDefault("STUB_FIXTURES_DIR", ""),
+
+		ExtractorMode: getEnvOrDefault("EXTRACTOR_MODE", "llm"),
+		PDFLayout:     pdf.Layout(getEnvOrDefault("PDF_LAYOUT", string(pdf.LayoutPlain))),
+
+		LLMProvider: getEnvOrDefault("LLM_PROVIDER", "openai"),
+		LLMModel:    getEnvOrDefault("LLM_MODEL", ""),
+		LLMBaseURL:  getEnvOrDefault("LLM_BASE_URL", ""),
+		LLMAPIKey:   getEnvOrDefault("LLM_API_KEY", ""),
+
+		LLMFileUpload: getEnvBoolOrDefault("LLM_FILE_UPLOAD", false),
+
+		LLMContextWindow: getEnvIntOrDefault("LLM_CONTEXT_WINDOW", 0),
+
+		LLMRequestsPerMinute: getEnvIntOrDefault("LLM_REQUESTS_PER_MINUTE", 500),
+		LLMTokensPerMinute:   getEnvIntOrDefault("LLM_TOKENS_PER_MINUTE", 200000),
+
+		CacheStore:  getEnvOrDefault("CACHE_STORE", "memory"),
+		CacheDir:    getEnvOrDefault("CACHE_DIR", ".cache/egobot"),
+		CacheTTL:    getEnvDurationOrDefault("CACHE_TTL", 7*24*time.Hour),
+		CacheBypass: getEnvBoolOrDefault("CACHE_BYPASS", false),
+
+		LLMCassette:    getEnvOrDefault("LLM_CASSETTE", ""),
+		LLMCassetteDir: getEnvOrDefault("LLM_CASSETTE_DIR", "cassettes"),
+
+		ConfidenceThreshold: getEnvFloatOrDefault("CONFIDENCE_THRESHOLD", ai.DefaultConfidenceThreshold),
+
+		PromptDir:      getEnvOrDefault("PROMPT_DIR", ""),
+		PromptTemplate: getEnvOrDefault("PROMPT_TEMPLATE", "advokat"),
+
+		AzureOpenAIEndpoint:   getEnvOrDefault("AZURE_OPENAI_ENDPOINT", ""),
+		AzureOpenAIDeployment: getEnvOrDefault("AZURE_OPENAI_DEPLOYMENT", ""),
+		AzureOpenAIAPIVersion: getEnvOrDefault("AZURE_OPENAI_API_VERSION", "2024-10-21"),
+		AzureOpenAIAPIKey:     getEnvOrDefault("AZURE_OPENAI_API_KEY", ""),
 
 		IMAPServer:   getEnvOrDefault("IMAP_SERVER", "imap.gmail.com"),
 		IMAPPort:     getEnvIntOrDefault("IMAP_PORT", 993),
@@ -55,27 +174,64 @@ func Load() (*Config, error) {
 		SMTPFrom:     getEnvOrDefault("SMTP_FROM", ""),
 		SMTPTo:       getEnvOrDefault("SMTP_TO", ""),
 
-		EntitiesToTrack: getEnvSliceOrDefault("ENTITIES_TO_TRACK", []string{"pikkemand"}),
-		ScheduleCron:    getEnvOrDefault("SCHEDULE_CRON", "0 6 * * * *"), // Daily at 6 AM
-		MaxRetries:      getEnvIntOrDefault("MAX_RETRIES", 3),
-		RetryDelay:      getEnvDurationOrDefault("RETRY_DELAY", 5*time.Minute),
+		ScheduleCron: getEnvOrDefault("SCHEDULE_CRON", "0 6 * * * *"), // Daily at 6 AM
+		MaxRetries:   getEnvIntOrDefault("MAX_RETRIES", 3),
+		RetryDelay:   getEnvDurationOrDefault("RETRY_DELAY", 5*time.Minute),
 	}
 
+	// Strings, typed entities and subjects are accepted, see watchlist.Parse
+	entities, err := watchlist.Parse(getEnvOrDefault("ENTITIES_TO_TRACK", `["pikkemand"]`))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ENTITIES_TO_TRACK: %w", err)
+	}
+	config.Watchlist = entities
+
+	prices, err := ai.ParsePrices(getEnvOrDefault("LLM_PRICES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LLM_PRICES: %w", err)
+	}
+	config.LLMPrices = prices
+
 	// Validate required fields
-	if config.OpenAIAPIKey == "" && !config.OpenAIStub {
-		return nil, fmt.Errorf("OPENAI_API_KEY is required when not using stubbed mode")
+	if config.ExtractorMode != "llm" && config.ExtractorMode != "matcher" {
+		return nil, fmt.Errorf("EXTRACTOR_MODE must be llm or matcher, got %s", config.ExtractorMode)
 	}
-	if config.IMAPUsername == "" {
-		return nil, fmt.Errorf("IMAP_USERNAME is required")
+	if config.PDFLayout != pdf.LayoutPlain && config.PDFLayout != pdf.LayoutColumns {
+		return nil, fmt.Errorf("PDF_LAYOUT must be plain or columns, got %s", config.PDFLayout)
 	}
-	if config.IMAPPassword == "" {
-		return nil, fmt.Errorf("IMAP_PASSWORD is required")
+	if config.LLMRequestsPerMinute < 0 || config.LLMTokensPerMinute < 0 {
+		return nil, fmt.Errorf("LLM_REQUESTS_PER_MINUTE and LLM_TOKENS_PER_MINUTE must not be negative")
 	}
-	if config.SMTPFrom == "" {
-		return nil, fmt.Errorf("SMTP_FROM is required")
+	if config.CacheStore != "memory" && config.CacheStore != "disk" && config.CacheStore != "none" {
+		return nil, fmt.Errorf("CACHE_STORE must be memory, disk or none, got %s", config.CacheStore)
 	}
-	if config.SMTPTo == "" {
-		return nil, fmt.Errorf("SMTP_TO is required")
+	if config.LLMCassette != "" && config.LLMCassette != ai.CassetteRecord && config.LLMCassette != ai.CassetteReplay {
+		return nil, fmt.Errorf("LLM_CASSETTE must be record or replay, got %s", config.LLMCassette)
+	}
+	if config.ConfidenceThreshold < 0 || config.ConfidenceThreshold > 1 {
+		return nil, fmt.Errorf("CONFIDENCE_THRESHOLD must be between 0 and 1, got %g", config.ConfidenceThreshold)
+	}
+	if config.LLMContextWindow < 0 {
+		return nil, fmt.Errorf("LLM_CONTEXT_WINDOW must not be negative, got %d", config.LLMContextWindow)
+	}
+	// The local matcher doesn't call a model, so no provider settings are needed
+	if !config.OpenAIStub && config.ExtractorMode == "llm" {
+		switch config.LLMProvider {
+		case "openai":
+			if config.OpenAIAPIKey == "" {
+				return nil, fmt.Errorf("OPENAI_API_KEY is required when not using stubbed mode")
+			}
+		case "openai-compatible":
+			if config.LLMBaseURL == "" || config.LLMModel == "" {
+				return nil, fmt.Errorf("LLM_BASE_URL and LLM_MODEL are required for the openai-compatible provider")
+			}
+		case "azure":
+			if config.AzureOpenAIEndpoint == "" || config.AzureOpenAIDeployment == "" || config.AzureOpenAIAPIKey == "" {
+				return nil, fmt.Errorf("AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_DEPLOYMENT and AZURE_OPENAI_API_KEY are required for the azure provider")
+			}
+		default:
+			return nil, fmt.Errorf("LLM_PROVIDER must be one of openai, openai-compatible or azure, got %s", config.LLMProvider)
+		}
 	}
 
 	return config, nil
@@ -107,30 +263,20 @@ func getEnvBoolOrDefault(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
-func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
 	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
 		}
 	}
 	return defaultValue
 }
 
-func getEnvSliceOrDefault(key string, defaultValue []string) []string {
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
-		// Try to parse as JSON array first
-		var jsonArray []string
-		if err := json.Unmarshal([]byte(value), &jsonArray); err == nil {
-			return jsonArray
-		}
-
-		// Fallback to comma-separated values
-		if strings.Contains(value, ",") {
-			return strings.Split(value, ",")
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
 		}
-
-		// If it's a single value, return as array with one item
-		return []string{value}
 	}
 	return defaultValue
 }
@@ -4,6 +4,10 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"egobot/internal/ai"
+	"egobot/internal/pdf"
+	"egobot/internal/watchlist"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -97,3 +101,134 @@ func TestEnvironmentVariableHelpers(t *testing.T) {
 		t.Errorf("Expected 10s, got %v", result)
 	}
 }
+
+func TestLoadConfigWatchlist(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("IMAP_USERNAME", "test@example.com")
+	os.Setenv("IMAP_PASSWORD", "password123")
+	os.Setenv("SMTP_FROM", "from@example.com")
+	os.Setenv("SMTP_TO", "to@example.com")
+	os.Setenv("ENTITIES_TO_TRACK", `["Benny Gotfred Schmidt",{"subject":"Client A","entities":[{"kind":"cvr","value":"39293056"}]}]`)
+
+	config, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(config.Watchlist) != 2 {
+		t.Fatalf("Expected 2 watchlist entities, got %d", len(config.Watchlist))
+	}
+	if config.Watchlist[1].Kind != watchlist.KindCVR || config.Watchlist[1].Subject != "Client A" {
+		t.Errorf("Expected a CVR entity for Client A, got %+v", config.Watchlist[1])
+	}
+
+	// Invalid entities are reported instead of silently never matching
+	os.Setenv("ENTITIES_TO_TRACK", `[{"kind":"cvr","value":"12345678"}]`)
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for an invalid CVR number")
+	}
+}
+
+func TestLoadConfigPrices(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("IMAP_USERNAME", "test@example.com")
+	os.Setenv("IMAP_PASSWORD", "password123")
+	os.Setenv("SMTP_FROM", "from@example.com")
+	os.Setenv("SMTP_TO", "to@example.com")
+	os.Setenv("LLM_PRICES", `{"my-deployment": {"input": 0.15, "output": 0.6}}`)
+
+	config, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if _, ok := config.LLMPrices["my-deployment"]; !ok {
+		t.Error("Expected the configured price to be added")
+	}
+	if _, ok := config.LLMPrices["gpt-4o-mini"]; !ok {
+		t.Error("Expected the default prices to be kept")
+	}
+
+	os.Setenv("LLM_PRICES", `{"my-deployment": 0.15}`)
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for an invalid price table")
+	}
+}
+
+func TestLoadConfigCassette(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("IMAP_USERNAME", "test@example.com")
+	os.Setenv("IMAP_PASSWORD", "password123")
+	os.Setenv("SMTP_FROM", "from@example.com")
+	os.Setenv("SMTP_TO", "to@example.com")
+	os.Setenv("LLM_CASSETTE", "replay")
+	os.Setenv("LLM_CASSETTE_DIR", "cassettes/2025-07-16")
+
+	config, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.LLMCassette != "replay" || config.LLMCassetteDir != "cassettes/2025-07-16" {
+		t.Errorf("Expected replay from cassettes/2025-07-16, got %s from %s", config.LLMCassette, config.LLMCassetteDir)
+	}
+
+	os.Setenv("LLM_CASSETTE", "rewind")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for an unknown cassette mode")
+	}
+}
+
+func TestLoadConfigConfidenceThreshold(t *testing.T) {
+	os.Clearenv()
+
+	config, err := LoadExtractor()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.ConfidenceThreshold != ai.DefaultConfidenceThreshold {
+		t.Errorf("Expected the default threshold %v, got %v", ai.DefaultConfidenceThreshold, config.ConfidenceThreshold)
+	}
+
+	os.Setenv("CONFIDENCE_THRESHOLD", "0.75")
+	if config, err = LoadExtractor(); err != nil || config.ConfidenceThreshold != 0.75 {
+		t.Errorf("Expected threshold 0.75, got %v, %v", config, err)
+	}
+
+	os.Setenv("CONFIDENCE_THRESHOLD", "1.5")
+	if _, err := LoadExtractor(); err == nil {
+		t.Error("Expected error for a threshold above 1")
+	}
+}
+
+func TestLoadConfigPDFLayout(t *testing.T) {
+	os.Clearenv()
+
+	config, err := LoadExtractor()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.PDFLayout != pdf.LayoutPlain {
+		t.Errorf("Expected the plain layout by default, got %q", config.PDFLayout)
+	}
+
+	os.Setenv("PDF_LAYOUT", "columns")
+	if config, err = LoadExtractor(); err != nil || config.PDFLayout != pdf.LayoutColumns {
+		t.Errorf("Expected the columns layout, got %v, %v", config, err)
+	}
+
+	os.Setenv("PDF_LAYOUT", "rows")
+	if _, err := LoadExtractor(); err == nil {
+		t.Error("Expected error for an unknown layout")
+	}
+}
+
+func TestLoadExtractor(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("EXTRACTOR_MODE", "matcher")
+
+	// The email settings are only required by Load
+	if _, err := LoadExtractor(); err != nil {
+		t.Fatalf("Expected no error without email settings, got %v", err)
+	}
+	if _, err := Load(); err == nil {
+		t.Error("Expected Load to require the email settings")
+	}
+}
@@ -2,6 +2,8 @@ package email
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -13,6 +15,9 @@ import (
 	"strings"
 	"time"
 
+	"egobot/internal/httpretry"
+	"egobot/internal/pdf"
+
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-imap/client"
 )
@@ -117,7 +122,7 @@ func (f *EmailFetcher) FetchPDFEmails() ([]EmailMessage, error) {
 			log.Printf("Error processing message: %v", err)
 			continue
 		}
-		if len(emailMsg.PDFURLs) > 0 {
+		if len(emailMsg.PDFURLs) > 0 || len(emailMsg.Attachments) > 0 {
 			emailMessages = append(emailMessages, emailMsg)
 		}
 	}
@@ -126,7 +131,7 @@ func (f *EmailFetcher) FetchPDFEmails() ([]EmailMessage, error) {
 		return nil, fmt.Errorf("failed to fetch messages: %w", err)
 	}
 
-	log.Printf("Successfully processed %d emails with PDF URLs", len(emailMessages))
+	log.Printf("Successfully processed %d emails with PDF URLs or attachments", len(emailMessages))
 	return emailMessages, nil
 }
 
@@ -387,17 +392,19 @@ func (f *EmailFetcher) downloadPDF(url string) ([]byte, error) {
 		Timeout: 30 * time.Second,
 	}
 
-	// Make the request
-	resp, err := client.Get(url)
+	// Transient failures of the download are retried, and a PDF too large to be read is not
+	// downloaded
+	policy := httpretry.Default
+	policy.MaxBodySize = pdf.MaxSize
+	resp, pdfData, err := policy.Do(context.Background(), client, "PDF download", func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", url, nil)
+	})
+	if errors.Is(err, httpretry.ErrBodyTooLarge) {
+		return nil, fmt.Errorf("failed to download PDF: %w: %w", pdf.ErrTooLarge, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to download PDF: %w", err)
 	}
-	defer resp.Body.Close()
-
-	// Check if the request was successful
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to download PDF: HTTP %d", resp.StatusCode)
-	}
 
 	// Check if the response is actually a PDF
 	contentType := resp.Header.Get("Content-Type")
@@ -405,12 +412,6 @@ func (f *EmailFetcher) downloadPDF(url string) ([]byte, error) {
 		log.Printf("Warning: Response is not a PDF (Content-Type: %s)", contentType)
 	}
 
-	// Read the PDF data
-	pdfData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read PDF data: %w", err)
-	}
-
 	log.Printf("Successfully downloaded PDF (%d bytes)", len(pdfData))
 	return pdfData, nil
 }
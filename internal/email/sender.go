@@ -10,8 +10,6 @@ import (
 	"time"
 
 	"egobot/internal/ai"
-
-	"github.com/gomarkdown/markdown"
 )
 
 // EmailSender handles SMTP email sending
@@ -36,8 +34,9 @@ func NewEmailSender(config *SenderConfig) *EmailSender {
 	}
 }
 
-// SendAnalysisResults sends an email with PDF analysis results
-func (s *EmailSender) SendAnalysisResults(results []AnalysisResult) error {
+// SendAnalysisResults sends an email with PDF analysis results and the tokens spent on them,
+// next to those spent so far today including this run
+func (s *EmailSender) SendAnalysisResults(results []AnalysisResult, today ai.Usage) error {
 	if len(results) == 0 {
 		log.Printf("No analysis results to send")
 		return nil
@@ -46,7 +45,7 @@ func (s *EmailSender) SendAnalysisResults(results []AnalysisResult) error {
 	subject := fmt.Sprintf("PDF Analysis Results - %s", time.Now().Format("2006-01-02"))
 
 	// Generate HTML content
-	htmlContent, err := s.generateHTMLContent(results)
+	htmlContent, err := s.generateHTMLContent(results, today)
 	if err != nil {
 		return fmt.Errorf("failed to generate HTML content: %w", err)
 	}
@@ -61,27 +60,14 @@ type AnalysisResult struct {
 	EmailSubject string
 	EmailFrom    string
 	EmailDate    time.Time
-	Entities     ai.ExtractionResult
-	RawResponse  string // Raw OpenAI response text
+	Matches      ai.ExtractionResult
+	RawResponse  string   // Raw OpenAI response text, kept for debugging only
+	Usage        ai.Usage // Tokens spent on the analysis
 	Error        string
 }
 
-// cleanEntityResult removes the entity name from the beginning of the result if it appears there
-func (s *EmailSender) cleanEntityResult(entityName, result string) string {
-	// If the result starts with the entity name, remove it
-	if strings.HasPrefix(strings.TrimSpace(result), entityName) {
-		cleaned := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(result), entityName))
-		// Remove any leading punctuation or whitespace
-		cleaned = strings.TrimSpace(strings.TrimPrefix(cleaned, ":"))
-		cleaned = strings.TrimSpace(strings.TrimPrefix(cleaned, "-"))
-		cleaned = strings.TrimSpace(strings.TrimPrefix(cleaned, " "))
-		return cleaned
-	}
-	return result
-}
-
 // generateHTMLContent generates HTML email content
-func (s *EmailSender) generateHTMLContent(results []AnalysisResult) (string, error) {
+func (s *EmailSender) generateHTMLContent(results []AnalysisResult, today ai.Usage) (string, error) {
 	const htmlTemplate = `
 <!DOCTYPE html>
 <html>
@@ -94,15 +80,16 @@ func (s *EmailSender) generateHTMLContent(results []AnalysisResult) (string, err
         .result { margin: 20px 0; padding: 15px; border: 1px solid #ddd; border-radius: 5px; }
         .entity { margin: 15px 0; padding: 15px; background-color: #f8f9fa; border-left: 4px solid #007bff; border-radius: 3px; }
         .entity-name { font-weight: bold; color: #007bff; font-size: 16px; margin-bottom: 8px; }
+        .entity-kind { font-weight: normal; color: #666; font-size: 13px; }
+        .prompt { color: #666; font-size: 12px; margin-bottom: 8px; }
         .entity-info { color: #333; line-height: 1.5; }
-        .entity-info h1, .entity-info h2, .entity-info h3, .entity-info h4, .entity-info h5, .entity-info h6 { 
-            color: #007bff; margin-top: 15px; margin-bottom: 10px; 
-        }
-        .entity-info h3 { font-size: 18px; }
-        .entity-info ul, .entity-info ol { margin: 10px 0; padding-left: 20px; }
+        .entity-info ul { margin: 10px 0; padding-left: 20px; }
         .entity-info li { margin: 5px 0; }
-        .entity-info strong { font-weight: bold; color: #333; }
-        .entity-info em { font-style: italic; }
+        .case-type { font-weight: bold; color: #333; text-transform: capitalize; }
+        .quote { margin: 10px 0; padding-left: 10px; border-left: 2px solid #ccc; color: #555; font-style: italic; }
+        .unverified { color: #b26a00; }
+        .possible { color: #666; font-weight: normal; text-transform: none; }
+        .warning { color: #b26a00; background-color: #fff4e5; padding: 10px; border-radius: 3px; }
         .error { color: #d32f2f; background-color: #ffebee; padding: 10px; border-radius: 3px; }
         .summary { background-color: #e8f5e8; padding: 10px; border-radius: 3px; margin-top: 10px; }
     </style>
@@ -124,18 +111,41 @@ func (s *EmailSender) generateHTMLContent(results []AnalysisResult) (string, err
             <strong>Error:</strong> {{.Error}}
         </div>
         {{else}}
-            {{if .RawResponse}}
-            <div class="entity">
-                <div class="entity-name">Analysis Results</div>
-                <div class="entity-info">{{markdownToHTML .RawResponse}}</div>
+            {{with .Matches.CountUnverified}}
+            <div class="warning">
+                <strong>Check by hand:</strong> {{.}} announcement(s) could not be verified against the PDF text.
+            </div>
+            {{end}}
+            {{with .Matches.CountPossible}}
+            <div class="warning">
+                <strong>Possible matches:</strong> {{.}} announcement(s) are below the confidence threshold and may concern someone else.
             </div>
-            {{else}}
-                {{range $entity, $info := .Entities}}
-                <div class="entity">
-                    <div class="entity-name">{{$entity}}</div>
-                    <div class="entity-info">{{cleanEntityResult $entity $info}}</div>
+            {{end}}
+            {{range .Matches}}
+            <div class="entity">
+                <div class="entity-name">{{.Entity}}{{if .EntityKind}} <span class="entity-kind">({{.EntityKind}})</span>{{end}}{{if .Subject}} &mdash; {{.Subject}}{{end}}</div>
+                {{if .Prompt}}<div class="prompt">Prompt: {{.Prompt}}</div>{{end}}
+                {{range .Announcements}}
+                <div class="entity-info">
+                    <div class="case-type">{{.Kind}}{{if .Possible}} <span class="possible">(possible match)</span>{{end}}</div>
+                    <ul>
+                        {{if .Name}}<li><strong>Name:</strong> {{.Name}}</li>{{end}}
+                        {{if .CPR}}<li><strong>CPR:</strong> {{.CPR}}</li>{{end}}
+                        {{if .DeathDate}}<li><strong>Death date:</strong> {{.DeathDate.Format "02.01.2006"}}</li>{{end}}
+                        {{if .CVR}}<li><strong>CVR:</strong> {{.CVR}}</li>{{end}}
+                        {{if .PetitionDate}}<li><strong>Petition received:</strong> {{.PetitionDate.Format "02.01.2006"}}</li>{{end}}
+                        {{if .Matrikel}}<li><strong>Matrikel:</strong> {{.Matrikel}}</li>{{end}}
+                        {{if .Address}}<li><strong>Address:</strong> {{.Address}}</li>{{end}}
+                        {{if .Page}}<li><strong>Found on:</strong> page {{.Page}}, line {{.Line}}{{if .Strategy}} ({{.Strategy}} match){{end}}</li>{{end}}
+                        <li><strong>Confidence:</strong> {{percent .Confidence}}</li>
+                        {{if not .Verified}}<li class="unverified"><strong>Unverified:</strong> {{if .Unverified}}{{range $i, $field := .Unverified}}{{if $i}}, {{end}}{{$field}}{{end}} not found in the PDF{{else}}could not be checked against the PDF{{end}}</li>{{end}}
+                    </ul>
+                    {{if .Quote}}<div class="quote">{{.Quote}}</div>{{end}}
                 </div>
+                {{else}}
+                <div class="entity-info">No information found.</div>
                 {{end}}
+            </div>
             {{end}}
         {{end}}
     </div>
@@ -146,13 +156,14 @@ func (s *EmailSender) generateHTMLContent(results []AnalysisResult) (string, err
         <p>Total PDFs processed: {{.Count}}</p>
         <p>Successful analyses: {{.SuccessCount}}</p>
         <p>Failed analyses: {{.ErrorCount}}</p>
+        {{with .Usage}}{{if .Requests}}<p>Tokens used: {{.InputTokens}} input and {{.OutputTokens}} output in {{.Requests}} request{{if ne .Requests 1}}s{{end}} (estimated cost ${{printf "%.4f" .Cost}})</p>{{end}}{{end}}
+        {{with .Today}}{{if .Requests}}<p>Tokens used today: {{.InputTokens}} input and {{.OutputTokens}} output in {{.Requests}} request{{if ne .Requests 1}}s{{end}} (estimated cost ${{printf "%.4f" .Cost}})</p>{{end}}{{end}}
     </div>
 </body>
 </html>`
 
 	tmpl, err := template.New("email").Funcs(template.FuncMap{
-		"cleanEntityResult": s.cleanEntityResult,
-		"markdownToHTML":    s.convertMarkdownToHTML,
+		"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
 	}).Parse(htmlTemplate)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template: %w", err)
@@ -161,7 +172,9 @@ func (s *EmailSender) generateHTMLContent(results []AnalysisResult) (string, err
 	// Calculate summary statistics
 	successCount := 0
 	errorCount := 0
+	var usage ai.Usage
 	for _, result := range results {
+		usage = usage.Add(result.Usage)
 		if result.Error != "" {
 			errorCount++
 		} else {
@@ -175,12 +188,16 @@ func (s *EmailSender) generateHTMLContent(results []AnalysisResult) (string, err
 		Results      []AnalysisResult
 		SuccessCount int
 		ErrorCount   int
+		Usage        ai.Usage
+		Today        ai.Usage
 	}{
 		Timestamp:    time.Now().Format("2006-01-02 15:04:05"),
 		Count:        len(results),
 		Results:      results,
 		SuccessCount: successCount,
 		ErrorCount:   errorCount,
+		Usage:        usage,
+		Today:        today,
 	}
 
 	var buf bytes.Buffer
@@ -261,14 +278,3 @@ func (s *EmailSender) SendErrorNotification(errorMsg string) error {
 
 	return s.sendEmail(subject, htmlContent)
 }
-
-// convertMarkdownToHTML converts markdown text to HTML
-func (s *EmailSender) convertMarkdownToHTML(markdownText string) template.HTML {
-	if markdownText == "" {
-		return ""
-	}
-
-	// Convert markdown to HTML
-	htmlBytes := markdown.ToHTML([]byte(markdownText), nil, nil)
-	return template.HTML(string(htmlBytes))
-}
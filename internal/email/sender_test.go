@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"egobot/internal/ai"
+	"egobot/internal/watchlist"
 )
 
 func TestNewEmailSender(t *testing.T) {
@@ -28,71 +29,37 @@ func TestNewEmailSender(t *testing.T) {
 	}
 }
 
-func TestEmailSender_CleanEntityResult(t *testing.T) {
-	sender := NewEmailSender(&SenderConfig{})
-
-	tests := []struct {
-		entityName string
-		result     string
-		expected   string
-	}{
-		{
-			entityName: "4720 Præstø",
-			result:     "4720 Præstø Dødsdato: N/A Skifteret: Retten i Sønderborg",
-			expected:   "Dødsdato: N/A Skifteret: Retten i Sønderborg",
-		},
-		{
-			entityName: "CPR-nr.: 2704430690",
-			result:     "CPR-nr.: 2704430690 Adresse: 4720 Præstø Dødsdato: N/A",
-			expected:   "Adresse: 4720 Præstø Dødsdato: N/A",
-		},
-		{
-			entityName: "Stephen Richard Grieves",
-			result:     "Stephen Richard Grieves CPR-nr.: 2704430690 Adresse: 4720 Præstø",
-			expected:   "CPR-nr.: 2704430690 Adresse: 4720 Præstø",
-		},
-		{
-			entityName: "Højbjerg",
-			result:     "Højbjerg Dødsdato: 25.06.2025 Skifteret: Retten i Sønderborg",
-			expected:   "Dødsdato: 25.06.2025 Skifteret: Retten i Sønderborg",
-		},
-		{
-			entityName: "Test Entity",
-			result:     "Some other text that doesn't start with the entity",
-			expected:   "Some other text that doesn't start with the entity",
-		},
-		{
-			entityName: "Test Entity",
-			result:     "Test Entity: Some information",
-			expected:   "Some information",
-		},
-		{
-			entityName: "Test Entity",
-			result:     "Test Entity - Some information",
-			expected:   "Some information",
-		},
-	}
-
-	for i, test := range tests {
-		cleaned := sender.cleanEntityResult(test.entityName, test.result)
-		if cleaned != test.expected {
-			t.Errorf("Test %d: Expected '%s', got '%s'", i+1, test.expected, cleaned)
-		}
-	}
-}
-
 func TestEmailSender_GenerateHTMLContent(t *testing.T) {
 	sender := NewEmailSender(&SenderConfig{})
+	petitionDate := time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC)
 
 	results := []AnalysisResult{
 		{
 			Filename:     "test1.pdf",
 			EmailSubject: "Test Email 1",
 			EmailFrom:    "sender1@example.com",
+			Usage:        ai.Usage{Requests: 2, InputTokens: 12000, OutputTokens: 800, Cost: 0.00228},
 			EmailDate:    time.Now(),
-			Entities: ai.ExtractionResult{
-				"Danske Bank": "No significant changes reported.",
-				"fintech":     "Several companies mentioned.",
+			Matches: []ai.Match{
+				{
+					Entity:     "Danske Bank",
+					EntityKind: watchlist.KindCompany,
+					Subject:    "Client A",
+					Prompt:     "advokat@1",
+					Announcements: []ai.Announcement{
+						{
+							Kind:         ai.KindKonkursbo,
+							Name:         "Danske Bank A/S",
+							CVR:          "61126228",
+							PetitionDate: &petitionDate,
+							Quote:        "Ved dekret af 17.07.2025 har Sø- og Handelsrettens skifteret taget",
+							Unverified:   []string{"name", "cvr"},
+							Confidence:   0.55,
+							Possible:     true,
+						},
+					},
+				},
+				{Entity: "fintech"},
 			},
 		},
 		{
@@ -104,7 +71,7 @@ func TestEmailSender_GenerateHTMLContent(t *testing.T) {
 		},
 	}
 
-	htmlContent, err := sender.generateHTMLContent(results)
+	htmlContent, err := sender.generateHTMLContent(results, ai.Usage{Requests: 5, InputTokens: 30000, OutputTokens: 2000, Cost: 0.0057})
 	if err != nil {
 		t.Fatalf("Failed to generate HTML content: %v", err)
 	}
@@ -114,6 +81,14 @@ func TestEmailSender_GenerateHTMLContent(t *testing.T) {
 		t.Error("Expected HTML to contain 'PDF Analysis Results'")
 	}
 
+	if !strings.Contains(htmlContent, "Client A") {
+		t.Error("Expected HTML to contain the watchlist subject")
+	}
+
+	if !strings.Contains(htmlContent, "Prompt: advokat@1") {
+		t.Error("Expected HTML to contain the prompt version")
+	}
+
 	if !strings.Contains(htmlContent, "test1.pdf") {
 		t.Error("Expected HTML to contain first filename")
 	}
@@ -126,11 +101,39 @@ func TestEmailSender_GenerateHTMLContent(t *testing.T) {
 		t.Error("Expected HTML to contain entity name")
 	}
 
+	if !strings.Contains(htmlContent, "61126228") {
+		t.Error("Expected HTML to contain the CVR of the match")
+	}
+
+	if !strings.Contains(htmlContent, "15.07.2025") {
+		t.Error("Expected HTML to contain the petition date of the match")
+	}
+
+	if !strings.Contains(htmlContent, "name, cvr not found in the PDF") || !strings.Contains(htmlContent, "1 announcement(s) could not be verified") {
+		t.Error("Expected HTML to flag the unverified announcement")
+	}
+
+	if !strings.Contains(htmlContent, "(possible match)") || !strings.Contains(htmlContent, "Confidence:</strong> 55%") || !strings.Contains(htmlContent, "1 announcement(s) are below the confidence threshold") {
+		t.Error("Expected HTML to show the announcement as a possible match with its confidence")
+	}
+
+	if !strings.Contains(htmlContent, "No information found.") {
+		t.Error("Expected HTML to mark entities without matches")
+	}
+
 	if !strings.Contains(htmlContent, "Failed to process PDF") {
 		t.Error("Expected HTML to contain error message")
 	}
 
 	// Check for summary statistics
+	if !strings.Contains(htmlContent, "Tokens used: 12000 input and 800 output in 2 requests (estimated cost $0.0023)") {
+		t.Error("Expected HTML to contain the tokens used")
+	}
+
+	if !strings.Contains(htmlContent, "Tokens used today: 30000 input and 2000 output in 5 requests (estimated cost $0.0057)") {
+		t.Error("Expected HTML to contain the tokens used today")
+	}
+
 	if !strings.Contains(htmlContent, "Total PDFs processed: 2") {
 		t.Error("Expected HTML to contain total count")
 	}
@@ -147,7 +150,7 @@ func TestEmailSender_GenerateHTMLContent(t *testing.T) {
 func TestEmailSender_GenerateHTMLContent_EmptyResults(t *testing.T) {
 	sender := NewEmailSender(&SenderConfig{})
 
-	htmlContent, err := sender.generateHTMLContent([]AnalysisResult{})
+	htmlContent, err := sender.generateHTMLContent([]AnalysisResult{}, ai.Usage{})
 	if err != nil {
 		t.Fatalf("Failed to generate HTML content: %v", err)
 	}
@@ -165,7 +168,7 @@ func TestEmailSender_SendAnalysisResults_EmptyResults(t *testing.T) {
 	sender := NewEmailSender(&SenderConfig{})
 
 	// This should not error even with empty results
-	err := sender.SendAnalysisResults([]AnalysisResult{})
+	err := sender.SendAnalysisResults([]AnalysisResult{}, ai.Usage{})
 	if err != nil {
 		t.Errorf("Expected no error with empty results, got %v", err)
 	}
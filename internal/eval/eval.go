@@ -0,0 +1,334 @@
+// Package eval scores an extractor against a labelled dataset of Statstidende issues, so the
+// effect of prompt and model changes can be measured before they are deployed.
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"egobot/internal/ai"
+	"egobot/internal/processor"
+	"egobot/internal/watchlist"
+)
+
+// Case is a labelled PDF: the watchlist to look for and the announcements that should be found.
+// Cases are stored as one JSON file each in the dataset directory.
+type Case struct {
+	Name      string              `json:"-"`                 // File name without .json
+	PDF       string              `json:"pdf,omitempty"`     // Path of the PDF, relative to the case file
+	PDFURL    string              `json:"pdf_url,omitempty"` // URL of the PDF, if it is not a local file
+	Watchlist watchlist.Watchlist `json:"-"`                 // Parsed from the watchlist field
+	Expected  []Expected          `json:"expected"`
+}
+
+// Expected is an announcement that should be found for an entity of the watchlist
+type Expected struct {
+	Entity         string `json:"entity"`
+	AnnouncementID string `json:"announcement_id"`
+}
+
+// Result is the outcome for one announcement
+type Result string
+
+const (
+	TruePositive  Result = "tp" // Expected and found
+	FalsePositive Result = "fp" // Found but not expected
+	FalseNegative Result = "fn" // Expected but not found
+)
+
+// Outcome is a scored announcement of a case
+type Outcome struct {
+	Result         Result         `json:"result"`
+	Kind           watchlist.Kind `json:"kind"`
+	Entity         string         `json:"entity"`
+	AnnouncementID string         `json:"announcement_id"`
+}
+
+// CaseReport lists the outcomes of a case, sorted so reports of two runs can be diffed
+type CaseReport struct {
+	Name     string    `json:"name"`
+	Error    string    `json:"error,omitempty"` // The extraction failed, so every expected announcement is missed
+	Outcomes []Outcome `json:"outcomes"`
+}
+
+// Counts are the true positives, false positives and false negatives of a set of outcomes
+type Counts struct {
+	TruePositives  int `json:"tp"`
+	FalsePositives int `json:"fp"`
+	FalseNegatives int `json:"fn"`
+}
+
+// Precision is the share of the found announcements that were expected, 1 if none were found
+func (c Counts) Precision() float64 {
+	if c.TruePositives+c.FalsePositives == 0 {
+		return 1
+	}
+	return float64(c.TruePositives) / float64(c.TruePositives+c.FalsePositives)
+}
+
+// Recall is the share of the expected announcements that were found, 1 if none were expected
+func (c Counts) Recall() float64 {
+	if c.TruePositives+c.FalseNegatives == 0 {
+		return 1
+	}
+	return float64(c.TruePositives) / float64(c.TruePositives+c.FalseNegatives)
+}
+
+// add counts the outcome
+func (c *Counts) add(result Result) {
+	switch result {
+	case TruePositive:
+		c.TruePositives++
+	case FalsePositive:
+		c.FalsePositives++
+	case FalseNegative:
+		c.FalseNegatives++
+	}
+}
+
+// KindScore is the score of the entities of one kind
+type KindScore struct {
+	Kind      watchlist.Kind `json:"kind"`
+	Counts    Counts         `json:"counts"`
+	Precision float64        `json:"precision"`
+	Recall    float64        `json:"recall"`
+}
+
+// Report is the result of running an extractor over a dataset
+type Report struct {
+	Cases []CaseReport `json:"cases"`
+	Kinds []KindScore  `json:"kinds"` // Sorted by kind
+	Total KindScore    `json:"total"`
+}
+
+// Failed reports whether the extraction of any case failed
+func (r Report) Failed() bool {
+	for _, c := range r.Cases {
+		if c.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadDataset loads the *.json cases in the directory, sorted by name
+func LoadDataset(dir string) ([]Case, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no cases found in %s", dir)
+	}
+	sort.Strings(files)
+
+	cases := make([]Case, 0, len(files))
+	for _, file := range files {
+		c, err := loadCase(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid case %s: %w", file, err)
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+// loadCase reads a case file. The watchlist is in the format of ENTITIES_TO_TRACK.
+func loadCase(file string) (Case, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Case{}, err
+	}
+	var c Case
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Case{}, err
+	}
+	var raw struct {
+		Watchlist json.RawMessage `json:"watchlist"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Case{}, err
+	}
+	if c.Watchlist, err = watchlist.Parse(string(raw.Watchlist)); err != nil {
+		return Case{}, fmt.Errorf("invalid watchlist: %w", err)
+	}
+
+	c.Name = strings.TrimSuffix(filepath.Base(file), ".json")
+	if (c.PDF == "") == (c.PDFURL == "") {
+		return Case{}, fmt.Errorf("exactly one of pdf and pdf_url is required")
+	}
+	if c.PDF != "" && !filepath.IsAbs(c.PDF) {
+		c.PDF = filepath.Join(filepath.Dir(file), c.PDF)
+	}
+	for _, expected := range c.Expected {
+		if _, ok := c.entity(expected.Entity); !ok {
+			return Case{}, fmt.Errorf("expected entity %q is not on the watchlist", expected.Entity)
+		}
+		if expected.AnnouncementID == "" {
+			return Case{}, fmt.Errorf("expected announcement of %q has no announcement_id", expected.Entity)
+		}
+	}
+	return c, nil
+}
+
+// entity returns the watchlist entity with the value, ignoring case
+func (c Case) entity(value string) (watchlist.Entity, bool) {
+	for _, entity := range c.Watchlist {
+		if strings.EqualFold(strings.TrimSpace(entity.Value), strings.TrimSpace(value)) {
+			return entity, true
+		}
+	}
+	return watchlist.Entity{}, false
+}
+
+// Run runs the extractor over the cases and scores the results
+func Run(ctx context.Context, extractor processor.Extractor, cases []Case) Report {
+	reports := make([]CaseReport, 0, len(cases))
+	for _, c := range cases {
+		log.Printf("Evaluating case %s", c.Name)
+		response, err := extract(ctx, extractor, c)
+		if err != nil {
+			log.Printf("Case %s failed: %v", c.Name, err)
+		}
+		reports = append(reports, Score(c, response.Results, err))
+	}
+	return NewReport(reports)
+}
+
+// extract runs the extractor on the PDF of the case
+func extract(ctx context.Context, extractor processor.Extractor, c Case) (ai.ExtractionResponse, error) {
+	if c.PDFURL != "" {
+		return extractor.ExtractEntitiesFromPDFURL(ctx, c.PDFURL, c.Watchlist)
+	}
+	file, err := os.Open(c.PDF)
+	if err != nil {
+		return ai.ExtractionResponse{}, fmt.Errorf("failed to open PDF: %w", err)
+	}
+	defer file.Close()
+	return extractor.ExtractEntitiesFromPDFFile(ctx, file, filepath.Base(c.PDF), c.Watchlist)
+}
+
+// Score compares the results of a case with the expected announcements. Announcements are
+// identified by entity and announcement ID, so one without an ID is a false positive.
+func Score(c Case, results ai.ExtractionResult, err error) CaseReport {
+	report := CaseReport{Name: c.Name, Outcomes: []Outcome{}}
+	if err != nil {
+		report.Error = err.Error()
+		results = nil
+	}
+
+	type key struct{ entity, id string }
+	expected := make(map[key]bool)
+	for _, e := range c.Expected {
+		entity, _ := c.entity(e.Entity)
+		expected[key{entity.Value, strings.TrimSpace(e.AnnouncementID)}] = true
+	}
+
+	found := make(map[key]bool)
+	for _, match := range results {
+		entity, ok := c.entity(match.Entity)
+		if !ok {
+			// The model reported an entity that isn't on the watchlist
+			entity = watchlist.Entity{Kind: match.EntityKind, Value: match.Entity}
+		}
+		for _, announcement := range match.Announcements {
+			k := key{entity.Value, strings.TrimSpace(announcement.ID)}
+			if found[k] {
+				continue // Reported twice, e.g. by two chunks
+			}
+			found[k] = true
+			result := FalsePositive
+			if expected[k] {
+				result = TruePositive
+			}
+			report.Outcomes = append(report.Outcomes, Outcome{Result: result, Kind: entity.Kind, Entity: entity.Value, AnnouncementID: k.id})
+		}
+	}
+	for k := range expected {
+		if !found[k] {
+			entity, _ := c.entity(k.entity)
+			report.Outcomes = append(report.Outcomes, Outcome{Result: FalseNegative, Kind: entity.Kind, Entity: k.entity, AnnouncementID: k.id})
+		}
+	}
+
+	sort.Slice(report.Outcomes, func(i, j int) bool {
+		a, b := report.Outcomes[i], report.Outcomes[j]
+		if a.Entity != b.Entity {
+			return a.Entity < b.Entity
+		}
+		if a.AnnouncementID != b.AnnouncementID {
+			return a.AnnouncementID < b.AnnouncementID
+		}
+		return a.Result < b.Result
+	})
+	return report
+}
+
+// NewReport sums the outcomes of the cases per entity kind
+func NewReport(cases []CaseReport) Report {
+	counts := make(map[watchlist.Kind]*Counts)
+	var total Counts
+	for _, c := range cases {
+		for _, outcome := range c.Outcomes {
+			if counts[outcome.Kind] == nil {
+				counts[outcome.Kind] = &Counts{}
+			}
+			counts[outcome.Kind].add(outcome.Result)
+			total.add(outcome.Result)
+		}
+	}
+
+	report := Report{Cases: cases, Kinds: []KindScore{}, Total: score("total", total)}
+	for kind, c := range counts {
+		report.Kinds = append(report.Kinds, score(kind, *c))
+	}
+	sort.Slice(report.Kinds, func(i, j int) bool {
+		return report.Kinds[i].Kind < report.Kinds[j].Kind
+	})
+	return report
+}
+
+func score(kind watchlist.Kind, counts Counts) KindScore {
+	return KindScore{Kind: kind, Counts: counts, Precision: counts.Precision(), Recall: counts.Recall()}
+}
+
+// WriteText writes the report as plain text with one line per outcome, so two reports can be
+// compared with diff
+func (r Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, c := range r.Cases {
+		fmt.Fprintf(&b, "case %s\n", c.Name)
+		if c.Error != "" {
+			fmt.Fprintf(&b, "  error %s\n", c.Error)
+		}
+		for _, o := range c.Outcomes {
+			id := o.AnnouncementID
+			if id == "" {
+				id = "(no id)"
+			}
+			fmt.Fprintf(&b, "  %s %s %q %s\n", o.Result, o.Kind, o.Entity, id)
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "%-10s %4s %4s %4s %9s %7s\n", "kind", "tp", "fp", "fn", "precision", "recall")
+	for _, s := range append(r.Kinds, r.Total) {
+		fmt.Fprintf(&b, "%-10s %4d %4d %4d %9.3f %7.3f\n", s.Kind, s.Counts.TruePositives, s.Counts.FalsePositives, s.Counts.FalseNegatives, s.Precision, s.Recall)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as indented JSON
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
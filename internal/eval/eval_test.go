@@ -0,0 +1,151 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"egobot/internal/ai"
+	"egobot/internal/config"
+	"egobot/internal/processor"
+	"egobot/internal/watchlist"
+)
+
+func testCase() Case {
+	return Case{
+		Name: "sample",
+		Watchlist: watchlist.Watchlist{
+			{Kind: watchlist.KindCompany, Value: "ACEZONE ApS"},
+			{Kind: watchlist.KindCPR, Value: "0801620450"},
+			{Kind: watchlist.KindPerson, Value: "Dorte Bente Jørgensen"},
+		},
+		Expected: []Expected{
+			{Entity: "acezone aps", AnnouncementID: "S17072025-23"},
+			{Entity: "0801620450", AnnouncementID: "S17072025-152"},
+			{Entity: "Dorte Bente Jørgensen", AnnouncementID: "S17072025-154"},
+		},
+	}
+}
+
+func TestScore(t *testing.T) {
+	results := ai.ExtractionResult{
+		{Entity: "ACEZONE ApS", EntityKind: watchlist.KindCompany, Announcements: []ai.Announcement{{ID: "S17072025-23"}, {ID: "S17072025-23"}}},
+		{Entity: "0801620450", EntityKind: watchlist.KindCPR, Announcements: []ai.Announcement{{ID: "S17072025-999"}, {Name: "Jette Fries Lundsted"}}},
+		{Entity: "Dorte Bente Jørgensen", EntityKind: watchlist.KindPerson, Announcements: []ai.Announcement{}},
+	}
+
+	report := Score(testCase(), results, nil)
+	want := []Outcome{
+		{Result: FalsePositive, Kind: watchlist.KindCPR, Entity: "0801620450", AnnouncementID: ""},
+		{Result: FalseNegative, Kind: watchlist.KindCPR, Entity: "0801620450", AnnouncementID: "S17072025-152"},
+		{Result: FalsePositive, Kind: watchlist.KindCPR, Entity: "0801620450", AnnouncementID: "S17072025-999"},
+		{Result: TruePositive, Kind: watchlist.KindCompany, Entity: "ACEZONE ApS", AnnouncementID: "S17072025-23"},
+		{Result: FalseNegative, Kind: watchlist.KindPerson, Entity: "Dorte Bente Jørgensen", AnnouncementID: "S17072025-154"},
+	}
+	if len(report.Outcomes) != len(want) {
+		t.Fatalf("Expected %d outcomes, got %+v", len(want), report.Outcomes)
+	}
+	for i := range want {
+		if report.Outcomes[i] != want[i] {
+			t.Errorf("Outcome %d: expected %+v, got %+v", i, want[i], report.Outcomes[i])
+		}
+	}
+
+	full := NewReport([]CaseReport{report})
+	if full.Total.Counts != (Counts{TruePositives: 1, FalsePositives: 2, FalseNegatives: 2}) {
+		t.Errorf("Unexpected total %+v", full.Total.Counts)
+	}
+	if full.Total.Precision != 1.0/3 || full.Total.Recall != 1.0/3 {
+		t.Errorf("Expected precision and recall of 1/3, got %v and %v", full.Total.Precision, full.Total.Recall)
+	}
+	if len(full.Kinds) != 3 || full.Kinds[0].Kind != watchlist.KindCompany || full.Kinds[1].Kind != watchlist.KindCPR {
+		t.Errorf("Expected the kinds in order, got %+v", full.Kinds)
+	}
+}
+
+func TestScore_Error(t *testing.T) {
+	report := Score(testCase(), nil, errors.New("rate limit exceeded"))
+	if report.Error != "rate limit exceeded" || len(report.Outcomes) != 3 {
+		t.Fatalf("Expected every announcement to be missed, got %+v", report)
+	}
+	if full := NewReport([]CaseReport{report}); !full.Failed() || full.Total.Recall != 0 || full.Total.Precision != 1 {
+		t.Errorf("Expected a failed report with recall 0, got %+v", full.Total)
+	}
+}
+
+func TestReportWriteText(t *testing.T) {
+	results := ai.ExtractionResult{
+		{Entity: "ACEZONE ApS", EntityKind: watchlist.KindCompany, Announcements: []ai.Announcement{{ID: "S17072025-23"}}},
+	}
+	report := NewReport([]CaseReport{Score(testCase(), results, nil)})
+
+	var b strings.Builder
+	if err := report.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `case sample
+  fn cpr "0801620450" S17072025-152
+  tp company "ACEZONE ApS" S17072025-23
+  fn person "Dorte Bente Jørgensen" S17072025-154
+
+kind         tp   fp   fn precision  recall
+company       1    0    0     1.000   1.000
+cpr           0    0    1     1.000   0.000
+person        0    0    1     1.000   0.000
+total         1    0    2     1.000   0.333
+`
+	if b.String() != want {
+		t.Errorf("Unexpected report:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestLoadDataset_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{name: "no pdf", text: `{"watchlist": ["ACEZONE ApS"], "expected": []}`, wantErr: "pdf and pdf_url"},
+		{name: "unknown entity", text: `{"pdf": "a.pdf", "watchlist": ["ACEZONE ApS"], "expected": [{"entity": "Nordic Fintech ApS", "announcement_id": "S1"}]}`, wantErr: "not on the watchlist"},
+		{name: "no announcement id", text: `{"pdf": "a.pdf", "watchlist": ["ACEZONE ApS"], "expected": [{"entity": "ACEZONE ApS"}]}`, wantErr: "no announcement_id"},
+		{name: "invalid watchlist", text: `{"pdf": "a.pdf", "watchlist": ["cpr:123"], "expected": []}`, wantErr: "invalid watchlist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "case.json"), []byte(tt.text), 0o644)
+			if _, err := LoadDataset(dir); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := LoadDataset(t.TempDir()); err == nil {
+		t.Error("Expected an error for an empty dataset")
+	}
+}
+
+func TestRun_Matcher(t *testing.T) {
+	if _, err := os.Stat("../../statstidende_sample.pdf"); err != nil {
+		t.Skipf("Sample PDF not available: %v", err)
+	}
+	cases, err := LoadDataset("../../testdata/eval")
+	if err != nil {
+		t.Fatalf("LoadDataset failed: %v", err)
+	}
+	extractor, err := processor.NewExtractor(&config.Config{ExtractorMode: "matcher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := Run(context.Background(), extractor, cases)
+	if report.Failed() || report.Total.Precision != 1 || report.Total.Recall != 1 {
+		var b strings.Builder
+		report.WriteText(&b)
+		t.Errorf("Expected the local matcher to find every labelled announcement, got:\n%s", b.String())
+	}
+}
@@ -0,0 +1,166 @@
+// Package gazette splits the text of a Statstidende issue into its individual
+// announcements (kundgørelser), so each can be matched and analysed on its own.
+package gazette
+
+import (
+	"regexp"
+	"strings"
+
+	"egobot/internal/pdf"
+)
+
+// Sections lists the section headings of Statstidende in the order they appear
+var Sections = []string{
+	"Dødsboer",
+	"Gældssanering",
+	"Konkursboer",
+	"Stævninger og indkaldelser",
+	"Tvangsauktioner",
+	"Øvrige retslige kundgørelser",
+}
+
+var (
+	// idPattern matches the announcement number that starts every notice, e.g. S17072025-152
+	idPattern = regexp.MustCompile(`^[A-Z]\d{8}-\d+$`)
+
+	// Page footers look like "Nr. 138." / " " / "19.07.2025" / "Dødsboer" / "5"
+	footerIssuePattern = regexp.MustCompile(`^Nr\. \d+\.$`)
+	footerDatePattern  = regexp.MustCompile(`^\d{2}\.\d{2}\.\d{4}$`)
+	footerPagePattern  = regexp.MustCompile(`^\d+$`)
+)
+
+// footerLines is the number of lines in a page footer
+const footerLines = 5
+
+// Line is a single line of text and where it was found
+type Line struct {
+	Page   int // PDF page number
+	Number int // 1-based line number on the page
+	Text   string
+}
+
+// Notice is a single announcement in Statstidende
+type Notice struct {
+	Section string // Section heading, e.g. "Konkursboer"
+	Heading string // Heading within the section, e.g. "Dekret" or "Proklama"
+	ID      string // Announcement number, e.g. "S17072025-23"
+	Lines   []Line // The announcement text, starting with the ID line, without page footers
+}
+
+// Page returns the page the notice starts on
+func (n Notice) Page() int {
+	if len(n.Lines) == 0 {
+		return 0
+	}
+	return n.Lines[0].Page
+}
+
+// Text returns the notice text with one line per line
+func (n Notice) Text() string {
+	lines := make([]string, 0, len(n.Lines))
+	for _, line := range n.Lines {
+		lines = append(lines, strings.TrimSpace(line.Text))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Split splits the pages of a gazette into notices. Every notice starts at its announcement
+// number and runs until the next one or the next section heading; page footers are removed.
+// The front matter before the first notice is dropped. Text without any announcement numbers,
+// i.e. not a Statstidende issue, is returned as a single notice so callers can still use it.
+func Split(pages []pdf.Page) []Notice {
+	lines := stripFooters(pageLines(pages))
+
+	var notices []Notice
+	var current *Notice
+	section, heading := "", ""
+
+	for i := 0; i < len(lines); i++ {
+		text := strings.TrimSpace(lines[i].Text)
+
+		// A section heading, optionally repeated, followed by a heading and a notice starts a new group
+		if isSection(text) {
+			j := i
+			for j+1 < len(lines) && isSection(strings.TrimSpace(lines[j+1].Text)) {
+				j++
+			}
+			if j+2 < len(lines) && isID(lines[j+2].Text) {
+				section = strings.TrimSpace(lines[j].Text)
+				heading = strings.TrimSpace(lines[j+1].Text)
+				current = nil
+				i = j + 1
+				continue
+			}
+		}
+
+		if isID(text) {
+			notices = append(notices, Notice{Section: section, Heading: heading, ID: text})
+			current = &notices[len(notices)-1]
+		}
+
+		if current != nil {
+			current.Lines = append(current.Lines, lines[i])
+		}
+	}
+
+	if len(notices) == 0 && len(lines) > 0 {
+		return []Notice{{Lines: lines}}
+	}
+	return notices
+}
+
+// SplitText splits extracted text into notices. Page numbers are unknown and left at zero.
+func SplitText(text string) []Notice {
+	return Split([]pdf.Page{{Text: text}})
+}
+
+// pageLines splits every page into numbered lines
+func pageLines(pages []pdf.Page) []Line {
+	var lines []Line
+	for _, page := range pages {
+		for i, text := range page.Lines() {
+			lines = append(lines, Line{Page: page.Number, Number: i + 1, Text: text})
+		}
+	}
+	return lines
+}
+
+// stripFooters removes the page footers that interrupt the announcement text
+func stripFooters(lines []Line) []Line {
+	stripped := make([]Line, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		if isFooter(lines[i:]) {
+			i += footerLines - 1
+			continue
+		}
+		stripped = append(stripped, lines[i])
+	}
+	return stripped
+}
+
+// isFooter reports whether the lines start with a page footer
+func isFooter(lines []Line) bool {
+	if len(lines) < footerLines {
+		return false
+	}
+	return footerIssuePattern.MatchString(strings.TrimSpace(lines[0].Text)) &&
+		strings.TrimSpace(lines[1].Text) == "" &&
+		footerDatePattern.MatchString(strings.TrimSpace(lines[2].Text)) &&
+		isSection(strings.TrimSpace(lines[3].Text)) &&
+		footerPagePattern.MatchString(strings.TrimSpace(lines[4].Text))
+}
+
+// isSection reports whether the line is one of the section headings
+func isSection(text string) bool {
+	for _, section := range Sections {
+		if text == section {
+			return true
+		}
+	}
+	return false
+}
+
+// isID reports whether the line is an announcement number
+func isID(text string) bool {
+	return idPattern.MatchString(strings.TrimSpace(text))
+}
@@ -0,0 +1,116 @@
+package gazette
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"egobot/internal/pdf"
+)
+
+func TestSplit_SamplePDF(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		read func(io.Reader) (*pdf.Document, error)
+	}{
+		{"plain", pdf.Read},
+		{"columns", pdf.ReadColumns},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			testSplitSamplePDF(t, test.read)
+		})
+	}
+}
+
+func testSplitSamplePDF(t *testing.T, read func(io.Reader) (*pdf.Document, error)) {
+	file, err := os.Open("../../statstidende_sample.pdf")
+	if err != nil {
+		t.Fatalf("Failed to open sample PDF: %v", err)
+	}
+	defer file.Close()
+
+	doc, err := read(file)
+	if err != nil {
+		t.Fatalf("Failed to read the PDF: %v", err)
+	}
+
+	notices := Split(doc.Pages)
+	if len(notices) != 177 {
+		t.Errorf("Expected 177 notices, got %d", len(notices))
+	}
+
+	sections := map[string]int{}
+	for _, notice := range notices {
+		sections[notice.Section]++
+		if strings.Contains(notice.Text(), "Nr. 138.") {
+			t.Errorf("Expected page footers to be removed from %s", notice.ID)
+		}
+	}
+	for _, section := range Sections {
+		if sections[section] == 0 {
+			t.Errorf("Expected notices in section %s", section)
+		}
+	}
+
+	notice := find(notices, "S17072025-23")
+	if notice == nil {
+		t.Fatal("Expected notice S17072025-23")
+	}
+	if notice.Section != "Konkursboer" || notice.Heading != "Dekret" {
+		t.Errorf("Expected Konkursboer / Dekret, got %s / %s", notice.Section, notice.Heading)
+	}
+	if !strings.Contains(notice.Text(), "ACEZONE ApS") || strings.Contains(notice.Text(), "Smartbooks ApS") {
+		t.Errorf("Expected only the ACEZONE announcement, got:\n%s", notice.Text())
+	}
+	if notice.Page() != 44 {
+		t.Errorf("Expected notice on page 44, got %d", notice.Page())
+	}
+}
+
+func TestSplit(t *testing.T) {
+	pages := []pdf.Page{
+		{Number: 2, Text: "Indhold\nDødsboer\n 2\nDødsboer\nDødsboer\nProklama\nS17072025-1\nAfdøde\nCPR-nr.: 1002520555\nOle\nNr. 138.\n \n19.07.2025\nDødsboer\n2\n"},
+		{Number: 3, Text: "Keinicke\nSkifteret\nS17072025-2\nAfdøde\nJette Fries Lundsted\nKonkursboer\nDekret\nS17072025-23\nACEZONE ApS\nCVR-nr.: 39293056"},
+	}
+
+	notices := Split(pages)
+	if len(notices) != 3 {
+		t.Fatalf("Expected 3 notices, got %d", len(notices))
+	}
+
+	first := notices[0]
+	if first.ID != "S17072025-1" || first.Section != "Dødsboer" || first.Heading != "Proklama" {
+		t.Errorf("Unexpected first notice: %s %s / %s", first.ID, first.Section, first.Heading)
+	}
+	if want := "S17072025-1\nAfdøde\nCPR-nr.: 1002520555\nOle\nKeinicke\nSkifteret"; first.Text() != want {
+		t.Errorf("Expected text %q, got %q", want, first.Text())
+	}
+	if last := first.Lines[len(first.Lines)-1]; last.Page != 3 || last.Number != 2 {
+		t.Errorf("Expected last line on page 3 line 2, got page %d line %d", last.Page, last.Number)
+	}
+
+	if notices[1].Section != "Dødsboer" || notices[1].Heading != "Proklama" {
+		t.Errorf("Expected second notice in the same group, got %s / %s", notices[1].Section, notices[1].Heading)
+	}
+	if notices[2].Section != "Konkursboer" || notices[2].Heading != "Dekret" {
+		t.Errorf("Expected third notice in Konkursboer / Dekret, got %s / %s", notices[2].Section, notices[2].Heading)
+	}
+}
+
+func TestSplit_NotAGazette(t *testing.T) {
+	notices := SplitText("Just some text\nwithout announcements")
+	if len(notices) != 1 || notices[0].Text() != "Just some text\nwithout announcements" {
+		t.Errorf("Expected the whole text as one notice, got %+v", notices)
+	}
+}
+
+// find returns the notice with the given ID
+func find(notices []Notice, id string) *Notice {
+	for i := range notices {
+		if notices[i].ID == id {
+			return &notices[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+// Package httpretry sends HTTP requests with retries. Transient failures (network errors,
+// 408, 429 and 5xx gateway errors) are retried with jittered exponential backoff, waiting
+// longer when the server says how long to wait, and the waiting stops when the context is done.
+package httpretry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Policy decides how often a request is attempted and how long to wait in between
+type Policy struct {
+	MaxAttempts int           // Attempts including the first one
+	BaseDelay   time.Duration // Delay before the first retry, doubled for every further retry
+	MaxDelay    time.Duration // Longest delay, also for delays asked for by the server
+	MaxBodySize int64         // Largest response body in bytes that is read, or 0 for no limit
+	// NotIdempotent retries only failures after which the request can't have taken effect: a
+	// 429 and errors before a connection was made. Other failures may have reached the server.
+	NotIdempotent bool
+}
+
+// Default is the policy for outbound HTTP in the project
+var Default = Policy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: time.Minute}
+
+// ErrBodyTooLarge is returned for a response body larger than the policy's MaxBodySize. It
+// is not retried.
+var ErrBodyTooLarge = errors.New("response body is too large")
+
+// StatusError is returned for a response that was not successful (2xx)
+type StatusError struct {
+	StatusCode int
+	Body       string
+	Attempts   int
+}
+
+func (e *StatusError) Error() string {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return fmt.Sprintf("rate limit exceeded after %d attempts: HTTP %d - %s", e.Attempts, e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("HTTP %d - %s", e.StatusCode, e.Body)
+}
+
+// Do sends the request built by newRequest until it succeeds, fails in a way that is not
+// worth retrying, or the attempts run out. newRequest is called for every attempt, as a
+// request body can only be sent once. The name identifies the service in logs.
+//
+// It returns the response with its body read and closed. Unsuccessful responses are
+// returned as a *StatusError, and bodies larger than MaxBodySize as ErrBodyTooLarge.
+func (p Policy) Do(ctx context.Context, client *http.Client, name string, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, []byte, error) {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		var connected atomic.Bool
+		if p.NotIdempotent {
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+				GotConn: func(httptrace.GotConnInfo) { connected.Store(true) },
+			}))
+		}
+
+		resp, body, err := send(client, req, p.MaxBodySize)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, body, nil
+		}
+		if err == nil {
+			err = &StatusError{StatusCode: resp.StatusCode, Body: string(body), Attempts: attempt}
+		}
+
+		// A done context fails every further attempt, so report why the request stopped
+		if ctx.Err() != nil {
+			return resp, body, fmt.Errorf("%w (after %d attempts: %v)", ctx.Err(), attempt, err)
+		}
+		retry := retryable(resp, err)
+		if p.NotIdempotent {
+			retry = retry && (resp == nil && !connected.Load() || resp != nil && resp.StatusCode == http.StatusTooManyRequests)
+		}
+		if !retry || attempt >= attempts {
+			return resp, body, err
+		}
+
+		delay := p.delay(attempt, resp)
+		log.Printf("%s request failed (attempt %d/%d): %v, retrying in %v", name, attempt, attempts, err, delay.Round(time.Millisecond))
+		if err := sleep(ctx, delay); err != nil {
+			return resp, body, fmt.Errorf("%w (after %d attempts)", err, attempt)
+		}
+	}
+}
+
+// send sends the request and reads the response body, rejecting a body larger than maxSize
+// bytes by its Content-Length before reading and by the bytes read otherwise
+func send(client *http.Client, req *http.Request, maxSize int64) (*http.Response, []byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	var reader io.Reader = resp.Body
+	if maxSize > 0 {
+		if resp.ContentLength > maxSize {
+			return resp, nil, fmt.Errorf("%w: %d bytes, more than %d", ErrBodyTooLarge, resp.ContentLength, maxSize)
+		}
+		reader = io.LimitReader(resp.Body, maxSize+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if maxSize > 0 && int64(len(body)) > maxSize {
+		return resp, nil, fmt.Errorf("%w: more than %d bytes", ErrBodyTooLarge, maxSize)
+	}
+	return resp, body, nil
+}
+
+// retryable reports whether the failure is likely to be transient. Errors without a response
+// are network errors, except a canceled request. An expired deadline is retried, as it is the
+// client's Timeout when the caller's context is not done; Do stops when that is done.
+func retryable(resp *http.Response, err error) bool {
+	if errors.Is(err, ErrBodyTooLarge) {
+		return false
+	}
+	if resp == nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// delay returns how long to wait before the next attempt: exponential backoff with jitter,
+// or longer if the server asked for it, but never more than MaxDelay
+func (p Policy) delay(attempt int, resp *http.Response) time.Duration {
+	backoff := p.BaseDelay
+	for i := 1; i < attempt && backoff < p.MaxDelay; i++ {
+		backoff *= 2
+	}
+	if p.MaxDelay > 0 && backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+
+	// Equal jitter: half the backoff plus a random share of the other half, so clients
+	// that failed together don't retry together
+	delay := backoff/2 + rand.N(backoff/2+1)
+
+	if resp != nil {
+		if hint := serverDelay(resp.Header, time.Now()); hint > delay {
+			delay = hint
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// serverDelay returns how long the server asked to wait, from the Retry-After header (seconds
+// or an HTTP date) or OpenAI's x-ratelimit-reset-requests and x-ratelimit-reset-tokens
+// headers (durations such as "1s" or "6m0s"). It returns 0 if there is no hint.
+func serverDelay(header http.Header, now time.Time) time.Duration {
+	var delay time.Duration
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			delay = time.Duration(seconds) * time.Second
+		} else if at, err := http.ParseTime(value); err == nil {
+			delay = at.Sub(now)
+		}
+	}
+	for _, key := range []string{"x-ratelimit-reset-requests", "x-ratelimit-reset-tokens"} {
+		if reset, err := time.ParseDuration(header.Get(key)); err == nil && reset > delay {
+			delay = reset
+		}
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// sleep waits for the delay or until the context is done
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,319 @@
+package httpretry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testPolicy retries without slowing the tests down
+var testPolicy = Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+// serve answers the requests with the statuses in order, and counts them
+func serve(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(count.Add(1)) - 1
+		if i >= len(statuses) {
+			t.Errorf("Unexpected request %d", i+1)
+			i = len(statuses) - 1
+		}
+		w.WriteHeader(statuses[i])
+		w.Write([]byte(http.StatusText(statuses[i])))
+	}))
+	t.Cleanup(server.Close)
+	return server, &count
+}
+
+func get(url string) func(ctx context.Context) (*http.Request, error) {
+	return func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", url, nil)
+	}
+}
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantErr      string
+		wantRequests int32
+	}{
+		{name: "success", statuses: []int{200}, wantRequests: 1},
+		{name: "retries transient errors", statuses: []int{503, 429, 200}, wantRequests: 3},
+		{name: "gives up after max attempts", statuses: []int{502, 502, 502}, wantErr: "HTTP 502 - Bad Gateway", wantRequests: 3},
+		{name: "rate limit exhausts attempts", statuses: []int{429, 429, 429}, wantErr: "rate limit exceeded after 3 attempts", wantRequests: 3},
+		{name: "client error is not retried", statuses: []int{400}, wantErr: "HTTP 400", wantRequests: 1},
+		{name: "not found is not retried", statuses: []int{404}, wantErr: "HTTP 404", wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, count := serve(t, tt.statuses...)
+			resp, body, err := testPolicy.Do(context.Background(), server.Client(), "test", get(server.URL))
+
+			if tt.wantErr == "" {
+				if err != nil || resp.StatusCode != 200 || string(body) != "OK" {
+					t.Errorf("Expected OK, got %v, %s", err, body)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			var statusErr *StatusError
+			if tt.wantErr != "" && !errors.As(err, &statusErr) {
+				t.Errorf("Expected a StatusError, got %T", err)
+			}
+			if got := count.Load(); got != tt.wantRequests {
+				t.Errorf("Expected %d requests, got %d", tt.wantRequests, got)
+			}
+		})
+	}
+}
+
+func TestDo_NetworkError(t *testing.T) {
+	server, _ := serve(t, 200)
+	url := server.URL
+	server.Close()
+
+	_, _, err := testPolicy.Do(context.Background(), http.DefaultClient, "test", get(url))
+	if err == nil {
+		t.Fatal("Expected the closed server to fail the request")
+	}
+}
+
+func TestDo_ClientTimeout(t *testing.T) {
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count.Add(1) == 1 {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	// A response slower than the client's Timeout is retried
+	client := server.Client()
+	client.Timeout = 50 * time.Millisecond
+	_, body, err := testPolicy.Do(context.Background(), client, "test", get(server.URL))
+	if err != nil || string(body) != "OK" {
+		t.Fatalf("Expected the second attempt to succeed, got %v", err)
+	}
+	if got := count.Load(); got != 2 {
+		t.Errorf("Expected 2 requests, got %d", got)
+	}
+
+	// The caller's own deadline is not
+	count.Store(0)
+	client.Timeout = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := testPolicy.Do(ctx, client, "test", get(server.URL)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the caller's deadline to stop the request, got %v", err)
+	}
+	if got := count.Load(); got != 1 {
+		t.Errorf("Expected 1 request, got %d", got)
+	}
+}
+
+func TestDo_NewRequestPerAttempt(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	_, _, err := testPolicy.Do(context.Background(), server.Client(), "test", func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "POST", server.URL, strings.NewReader("payload"))
+	})
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if len(bodies) != 2 || bodies[1] != "payload" {
+		t.Errorf("Expected the retry to send the body again, got %q", bodies)
+	}
+}
+
+func TestDo_MaxBodySize(t *testing.T) {
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		body := strings.Repeat("x", 100)
+		if r.URL.Query().Has("chunked") {
+			// Without a Content-Length, the limit is only found while reading
+			w.Write([]byte(body[:50]))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(body[50:]))
+			return
+		}
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	policy := testPolicy
+	policy.MaxBodySize = 100
+	for _, url := range []string{server.URL, server.URL + "?chunked"} {
+		if _, body, err := policy.Do(context.Background(), server.Client(), "test", get(url)); err != nil || len(body) != 100 {
+			t.Errorf("Expected a body of the largest size to be read from %s, got %d bytes, %v", url, len(body), err)
+		}
+	}
+
+	policy.MaxBodySize = 99
+	for _, url := range []string{server.URL, server.URL + "?chunked"} {
+		count.Store(0)
+		if _, _, err := policy.Do(context.Background(), server.Client(), "test", get(url)); !errors.Is(err, ErrBodyTooLarge) {
+			t.Errorf("Expected ErrBodyTooLarge from %s, got %v", url, err)
+		}
+		if got := count.Load(); got != 1 {
+			t.Errorf("Expected a body too large not to be retried, got %d requests", got)
+		}
+	}
+}
+
+func TestDo_NotIdempotent(t *testing.T) {
+	policy := testPolicy
+	policy.NotIdempotent = true
+
+	// Failures that may have reached the server are not retried
+	for _, status := range []int{500, 503} {
+		server, count := serve(t, status)
+		if _, _, err := policy.Do(context.Background(), server.Client(), "test", get(server.URL)); err == nil {
+			t.Errorf("Expected HTTP %d to fail", status)
+		}
+		if got := count.Load(); got != 1 {
+			t.Errorf("Expected HTTP %d not to be retried, got %d requests", status, got)
+		}
+	}
+
+	server, count := serve(t, 429, 200)
+	if _, _, err := policy.Do(context.Background(), server.Client(), "test", get(server.URL)); err != nil {
+		t.Errorf("Expected a rate limited request to be retried, got %v", err)
+	}
+	if got := count.Load(); got != 2 {
+		t.Errorf("Expected 2 requests, got %d", got)
+	}
+
+	// A request that never got a connection is retried
+	server, count = serve(t, 200)
+	var dials atomic.Int32
+	client := server.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if dials.Add(1) == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return dial(ctx, network, addr)
+	}
+	client.Transport = transport
+	if _, _, err := policy.Do(context.Background(), client, "test", get(server.URL)); err != nil {
+		t.Errorf("Expected a failed connection to be retried, got %v", err)
+	}
+	if got := count.Load(); got != 1 {
+		t.Errorf("Expected 1 request to reach the server, got %d", got)
+	}
+
+	// A response slower than the client's Timeout may still have been processed
+	var slow atomic.Int32
+	timeout := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slow.Add(1)
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer timeout.Close()
+	client = timeout.Client()
+	client.Timeout = 50 * time.Millisecond
+	if _, _, err := policy.Do(context.Background(), client, "test", get(timeout.URL)); err == nil {
+		t.Error("Expected the slow response to fail")
+	}
+	if got := slow.Load(); got != 1 {
+		t.Errorf("Expected the timeout not to be retried, got %d requests", got)
+	}
+}
+
+func TestDo_ContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Minute}
+
+	start := time.Now()
+	_, _, err := policy.Do(ctx, server.Client(), "test", get(server.URL))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to stop the retries, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the wait to stop with the context, took %v", elapsed)
+	}
+}
+
+func TestServerDelay(t *testing.T) {
+	now := time.Date(2025, time.July, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+	}{
+		{name: "no hint", want: 0},
+		{name: "retry-after seconds", header: map[string]string{"Retry-After": "7"}, want: 7 * time.Second},
+		{name: "retry-after date", header: map[string]string{"Retry-After": now.Add(90 * time.Second).Format(http.TimeFormat)}, want: 90 * time.Second},
+		{name: "retry-after in the past", header: map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)}, want: 0},
+		{name: "invalid retry-after", header: map[string]string{"Retry-After": "soon"}, want: 0},
+		{name: "openai reset headers", header: map[string]string{"x-ratelimit-reset-requests": "120ms", "x-ratelimit-reset-tokens": "6m0s"}, want: 6 * time.Minute},
+		{name: "longest hint wins", header: map[string]string{"Retry-After": "2", "x-ratelimit-reset-tokens": "1s"}, want: 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range tt.header {
+				header.Set(key, value)
+			}
+			if got := serverDelay(header, now); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for attempt, backoff := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 10 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if got := policy.delay(attempt, nil); got < backoff/2 || got > backoff {
+				t.Fatalf("Attempt %d: expected a delay between %v and %v, got %v", attempt, backoff/2, backoff, got)
+			}
+		}
+	}
+
+	// Server hints are honored up to MaxDelay
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"5"}}}
+	if got := policy.delay(1, resp); got != 5*time.Second {
+		t.Errorf("Expected the Retry-After delay, got %v", got)
+	}
+	resp.Header.Set("Retry-After", "3600")
+	if got := policy.delay(1, resp); got != 10*time.Second {
+		t.Errorf("Expected the delay to be capped at MaxDelay, got %v", got)
+	}
+}
@@ -0,0 +1,175 @@
+// Package ident parses and validates Danish identification numbers: CPR numbers
+// for persons and CVR numbers for companies.
+package ident
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCPR is returned when a string is not a valid CPR number
+var ErrInvalidCPR = errors.New("invalid CPR number")
+
+// cprPattern finds CPR numbers in text, e.g. 0605410146, 060541-0146 or 06 05 41 01 46
+var cprPattern = regexp.MustCompile(`\d{6}(?: ?- ?| )?\d{4}|\d{2} \d{2} \d{2} \d{2} \d{2}`)
+
+// cprWeights are the weights of the modulus 11 check used for CPR numbers issued before 2007
+var cprWeights = []int{4, 3, 2, 7, 6, 5, 4, 3, 2, 1}
+
+// CPR is a Danish personal identification number, stored as its 10 digits
+type CPR string
+
+// ParseCPR parses a CPR number in any of the common formats and validates the birth date.
+// The modulus 11 check is not applied, as numbers issued since 2007 don't satisfy it.
+func ParseCPR(s string) (CPR, error) {
+	digits := stripSeparators(s)
+	if len(digits) != 10 || !isDigits(digits) {
+		return "", fmt.Errorf("%w: %q must have 10 digits", ErrInvalidCPR, s)
+	}
+
+	cpr := CPR(digits)
+	if _, ok := cpr.birthDate(); !ok {
+		return "", fmt.Errorf("%w: %q does not start with a valid date", ErrInvalidCPR, s)
+	}
+	return cpr, nil
+}
+
+// String formats the CPR number as DDMMÅÅ-SSSS
+func (c CPR) String() string {
+	if len(c) != 10 {
+		return string(c)
+	}
+	return string(c[:6]) + "-" + string(c[6:])
+}
+
+// Digits returns the 10 digits of the CPR number
+func (c CPR) Digits() string {
+	return string(c)
+}
+
+// BirthDate returns the birth date, with the century derived from the 7th digit
+func (c CPR) BirthDate() time.Time {
+	date, _ := c.birthDate()
+	return date
+}
+
+// ValidChecksum reports whether the CPR number passes the modulus 11 check.
+// All numbers issued before 2007 do; newer numbers may not.
+func (c CPR) ValidChecksum() bool {
+	if len(c) != 10 {
+		return false
+	}
+	sum := 0
+	for i, weight := range cprWeights {
+		sum += int(c[i]-'0') * weight
+	}
+	return sum%11 == 0
+}
+
+// Female reports whether the CPR number belongs to a woman (even last digit)
+func (c CPR) Female() bool {
+	return len(c) == 10 && (c[9]-'0')%2 == 0
+}
+
+// birthDate derives the birth date from the first six digits and the century from the 7th digit
+func (c CPR) birthDate() (time.Time, bool) {
+	if len(c) != 10 {
+		return time.Time{}, false
+	}
+	day := atoi(string(c[0:2]))
+	month := atoi(string(c[2:4]))
+	year := atoi(string(c[4:6]))
+	seventh := int(c[6] - '0')
+
+	switch {
+	case seventh <= 3:
+		year += 1900
+	case seventh == 4 || seventh == 9:
+		if year <= 36 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	default: // 5-8
+		if year <= 57 {
+			year += 2000
+		} else {
+			year += 1800
+		}
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Day() != day || int(date.Month()) != month || date.Year() != year {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// FindCPRs returns the valid CPR numbers in the text, in the order they appear
+func FindCPRs(text string) []CPR {
+	var cprs []CPR
+	for _, match := range findNumbers(cprPattern, text) {
+		if cpr, err := ParseCPR(match); err == nil {
+			cprs = append(cprs, cpr)
+		}
+	}
+	return cprs
+}
+
+// findNumbers returns the pattern matches that are not part of a longer number
+func findNumbers(pattern *regexp.Regexp, text string) []string {
+	var matches []string
+	for _, loc := range pattern.FindAllStringIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if start > 0 && isDigit(text[start-1]) {
+			continue
+		}
+		if end < len(text) && isDigit(text[end]) {
+			continue
+		}
+		// Spaced numbers like "06 05 41 01 46" must not be part of a longer group of digits
+		match := text[start:end]
+		if strings.Contains(match, " ") {
+			if start > 1 && text[start-1] == ' ' && isDigit(text[start-2]) {
+				continue
+			}
+			if end+1 < len(text) && text[end] == ' ' && isDigit(text[end+1]) {
+				continue
+			}
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// stripSeparators removes the spaces, dashes and dots used to group digits
+func stripSeparators(s string) string {
+	return strings.NewReplacer(" ", "", "-", "", ".", "").Replace(strings.TrimSpace(s))
+}
+
+// isDigits reports whether the string consists of ASCII digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isDigit reports whether the byte is an ASCII digit
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// atoi converts a string of ASCII digits to an int
+func atoi(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		n = n*10 + int(s[i]-'0')
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+package ident
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCPR(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected CPR
+		wantErr  bool
+	}{
+		{input: "0605410146", expected: "0605410146"},
+		{input: "060541-0146", expected: "0605410146"},
+		{input: "06 05 41 01 46", expected: "0605410146"},
+		{input: " 060541 0146 ", expected: "0605410146"},
+		{input: "06.05.41-0146", expected: "0605410146"},
+		{input: "3102410146", wantErr: true}, // 31 February
+		{input: "060541014", wantErr: true},
+		{input: "06054101466", wantErr: true},
+		{input: "06054I0146", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			cpr, err := ParseCPR(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCPR) {
+					t.Errorf("Expected ErrInvalidCPR, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if cpr != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, cpr)
+			}
+		})
+	}
+}
+
+func TestCPR_BirthDate(t *testing.T) {
+	tests := []struct {
+		cpr      CPR
+		expected time.Time
+	}{
+		{cpr: "0605410146", expected: time.Date(1941, time.May, 6, 0, 0, 0, 0, time.UTC)},
+		{cpr: "0101054123", expected: time.Date(2005, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{cpr: "0101504123", expected: time.Date(1950, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{cpr: "0101205123", expected: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{cpr: "0101905123", expected: time.Date(1890, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{cpr: "2902009123", expected: time.Date(2000, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cpr.BirthDate(); !got.Equal(tt.expected) {
+			t.Errorf("%s: expected %s, got %s", tt.cpr, tt.expected.Format("02.01.2006"), got.Format("02.01.2006"))
+		}
+	}
+}
+
+func TestCPR_Formatting(t *testing.T) {
+	cpr := CPR("0605410146")
+	if cpr.String() != "060541-0146" {
+		t.Errorf("Expected 060541-0146, got %s", cpr.String())
+	}
+	if cpr.Digits() != "0605410146" {
+		t.Errorf("Expected 0605410146, got %s", cpr.Digits())
+	}
+	if CPR("0707614285").ValidChecksum() != true {
+		t.Error("Expected 0707614285 to pass the modulus 11 check")
+	}
+	if CPR("0707614286").ValidChecksum() {
+		t.Error("Expected 0707614286 to fail the modulus 11 check")
+	}
+	if !CPR("0707614286").Female() || CPR("0707614285").Female() {
+		t.Error("Expected gender from the last digit")
+	}
+}
+
+func TestFindCPRs(t *testing.T) {
+	text := "Afdøde CPR-nr.: 0801620450 Dødsdato: 14.03.2025. Ægtefælle 160739-0572, barn 06 05 41 01 46. " +
+		"Sagsnr. 12345678901, telefon 7080 7780, ugyldig 3102410146"
+
+	cprs := FindCPRs(text)
+	expected := []CPR{"0801620450", "1607390572", "0605410146"}
+	if len(cprs) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, cprs)
+	}
+	for i := range expected {
+		if cprs[i] != expected[i] {
+			t.Errorf("Expected %s at %d, got %s", expected[i], i, cprs[i])
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package ident
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidCVR is returned when a string is not a valid CVR number
+var ErrInvalidCVR = errors.New("invalid CVR number")
+
+// cvrPattern finds CVR numbers in text, e.g. 39293056, 39 29 30 56 or DK39293056
+var cvrPattern = regexp.MustCompile(`(?i)(?:DK[ -]?)?(?:\d{8}|\d{2} \d{2} \d{2} \d{2})`)
+
+// cvrWeights are the weights of the modulus 11 check all CVR numbers satisfy
+var cvrWeights = []int{2, 7, 6, 5, 4, 3, 2, 1}
+
+// CVR is a Danish company registration number, stored as its 8 digits
+type CVR string
+
+// ParseCVR parses a CVR number, optionally prefixed with DK as in VAT numbers, and validates its check digit
+func ParseCVR(s string) (CVR, error) {
+	digits := stripSeparators(s)
+	if len(digits) >= 2 && strings.EqualFold(digits[:2], "DK") {
+		digits = digits[2:]
+	}
+	if len(digits) != 8 || !isDigits(digits) {
+		return "", fmt.Errorf("%w: %q must have 8 digits", ErrInvalidCVR, s)
+	}
+	if digits[0] == '0' {
+		return "", fmt.Errorf("%w: %q cannot start with 0", ErrInvalidCVR, s)
+	}
+
+	cvr := CVR(digits)
+	if !cvr.validChecksum() {
+		return "", fmt.Errorf("%w: %q fails the check digit", ErrInvalidCVR, s)
+	}
+	return cvr, nil
+}
+
+// String returns the 8 digits of the CVR number
+func (c CVR) String() string {
+	return string(c)
+}
+
+// VAT returns the Danish VAT number, e.g. DK39293056
+func (c CVR) VAT() string {
+	return "DK" + string(c)
+}
+
+// validChecksum reports whether the CVR number passes the modulus 11 check
+func (c CVR) validChecksum() bool {
+	if len(c) != 8 {
+		return false
+	}
+	sum := 0
+	for i, weight := range cvrWeights {
+		sum += int(c[i]-'0') * weight
+	}
+	return sum%11 == 0
+}
+
+// FindCVRs returns the valid CVR numbers in the text, in the order they appear
+func FindCVRs(text string) []CVR {
+	var cvrs []CVR
+	for _, match := range findNumbers(cvrPattern, text) {
+		if cvr, err := ParseCVR(match); err == nil {
+			cvrs = append(cvrs, cvr)
+		}
+	}
+	return cvrs
+}
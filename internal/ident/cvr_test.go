@@ -0,0 +1,62 @@
+package ident
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCVR(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected CVR
+		wantErr  bool
+	}{
+		{input: "39293056", expected: "39293056"},
+		{input: "39 29 30 56", expected: "39293056"},
+		{input: "DK39293056", expected: "39293056"},
+		{input: "dk-39293056", expected: "39293056"},
+		{input: "61126228", expected: "61126228"},
+		{input: "39293057", wantErr: true}, // Wrong check digit
+		{input: "12345678", wantErr: true},
+		{input: "03929305", wantErr: true},
+		{input: "3929305", wantErr: true},
+		{input: "DK", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			cvr, err := ParseCVR(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCVR) {
+					t.Errorf("Expected ErrInvalidCVR, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if cvr != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, cvr)
+			}
+			if cvr.VAT() != "DK"+string(tt.expected) {
+				t.Errorf("Expected VAT number DK%s, got %s", tt.expected, cvr.VAT())
+			}
+		})
+	}
+}
+
+func TestFindCVRs(t *testing.T) {
+	text := "ACEZONE ApS CVR-nr.: 39293056 Nordre Fasanvej 113. Drevet virksomhed med CVR.nr. 26412552.Enhver, " +
+		"momsnr. DK 61126228, CPR-nr.: 0801620450, ugyldigt 39293057"
+
+	cvrs := FindCVRs(text)
+	expected := []CVR{"39293056", "26412552", "61126228"}
+	if len(cvrs) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, cvrs)
+	}
+	for i := range expected {
+		if cvrs[i] != expected[i] {
+			t.Errorf("Expected %s at %d, got %s", expected[i], i, cvrs[i])
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package pdf
+
+import (
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+const (
+	// sameLine is the distance in points within which glyphs are on the same baseline
+	sameLine = 1.0
+	// minColumnLines is how many lines must start at a position for it to be the left edge of a column
+	minColumnLines = 3
+	// minColumnWidth is the width of a column in ems at least, so indented lines don't make one
+	minColumnWidth = 12
+	// marginGap is how many line spacings set running headers and footers apart from the text
+	marginGap = 3
+	// maxMarginRows is the number of rows a running header or footer takes up at most
+	maxMarginRows = 3
+)
+
+// Layout selects the order in which the lines of a page are extracted
+type Layout string
+
+const (
+	LayoutPlain   Layout = "plain"   // The order the text is drawn in, see Read
+	LayoutColumns Layout = "columns" // Column by column, see ReadColumns
+)
+
+// Read extracts the text of each page in the layout; any layout but LayoutColumns is plain
+func (l Layout) Read(r io.Reader) (*Document, error) {
+	if l == LayoutColumns {
+		return ReadColumns(r)
+	}
+	return Read(r)
+}
+
+// ReadAt extracts the text of each page from the size bytes of a PDF in the layout, like Read
+func (l Layout) ReadAt(r io.ReaderAt, size int64) (*Document, error) {
+	if l == LayoutColumns {
+		return ReadColumnsAt(r, size)
+	}
+	return ReadAt(r, size)
+}
+
+// textLine is a line of text put together from glyphs drawn next to each other
+type textLine struct {
+	x, y   float64 // Where the line starts, in points from the bottom left corner
+	end    float64 // Where the last glyph ends
+	size   float64 // Font size of the first glyph
+	text   string
+	column int
+}
+
+// ReadColumns extracts the text of each page like Read, but puts the lines back in reading
+// order from the positions of the glyphs: column by column, each from top to bottom. Read
+// keeps the order the text is drawn in, which mixes the columns of pages drawn row by row.
+// Running headers and footers follow the text of the page in the order they are drawn, as
+// with Read, so both split into the same notices.
+func ReadColumns(r io.Reader) (*Document, error) {
+	return read(r, columnText)
+}
+
+// ReadColumnsAt extracts the text of each page from the size bytes of a PDF, like ReadColumns
+func ReadColumnsAt(r io.ReaderAt, size int64) (*Document, error) {
+	return readAt(r, size, columnText)
+}
+
+// columnText returns the text of the page in column order, or its plain text if the glyphs
+// can't be read
+func columnText(page pdf.Page) (text string) {
+	defer func() {
+		if recover() != nil {
+			text, _ = page.GetPlainText(nil)
+		}
+	}()
+	return layoutText(page.Content().Text)
+}
+
+// layoutText returns the text of the glyphs in column order, one line per line
+func layoutText(glyphs []pdf.Text) string {
+	fragments := textFragments(glyphs)
+	edges := columnEdges(fragments)
+	for i := range fragments {
+		fragments[i].column = columnOf(fragments[i].x, edges)
+	}
+
+	body, margins := splitMargins(joinFragments(fragments))
+	sort.SliceStable(body, func(i, j int) bool {
+		if body[i].column != body[j].column {
+			return body[i].column < body[j].column
+		}
+		return body[i].y > body[j].y+sameLine
+	})
+
+	texts := make([]string, 0, len(body)+len(margins))
+	for _, line := range slices.Concat(body, margins) {
+		texts = append(texts, line.text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// textFragments joins the glyphs into fragments of lines in the order they are drawn. A
+// glyph continues the fragment when it is on the same baseline and starts near its start or
+// end; glyphs without a width are placed at the start of the text they are part of, so text
+// positioned further along the line starts a new fragment. Glyphs that couldn't be decoded
+// are left out.
+func textFragments(glyphs []pdf.Text) []textLine {
+	var fragments []textLine
+	for _, glyph := range glyphs {
+		s := strings.ReplaceAll(glyph.S, "\uFFFD", "")
+		if s == "" {
+			continue
+		}
+		fragment := textLine{x: glyph.X, y: glyph.Y, end: glyph.X + glyph.W, size: glyph.FontSize, text: s}
+		if n := len(fragments); n > 0 && fragments[n-1].continues(fragment, 0) {
+			fragments[n-1].append(fragment)
+			continue
+		}
+		fragments = append(fragments, fragment)
+	}
+	return fragments
+}
+
+// joinFragments joins the fragments drawn one after the other along the same line of a column
+func joinFragments(fragments []textLine) []textLine {
+	var lines []textLine
+	for _, fragment := range fragments {
+		if n := len(lines); n > 0 && lines[n-1].column == fragment.column && lines[n-1].continues(fragment, math.Inf(1)) {
+			lines[n-1].append(fragment)
+			continue
+		}
+		lines = append(lines, fragment)
+	}
+	return lines
+}
+
+// continues reports whether the text continues the line: it is on the same baseline and
+// starts near the start or end of the line, or at most the given distance further along
+func (l textLine) continues(text textLine, further float64) bool {
+	return math.Abs(text.y-l.y) <= sameLine &&
+		(math.Abs(text.x-l.x) <= l.em() || text.x >= l.end-l.em() && text.x-l.end <= math.Max(further, l.em()))
+}
+
+// append adds the text to the end of the line, separated by a space if there is room for one
+func (l *textLine) append(text textLine) {
+	if text.x-l.end > l.em()/4 && !strings.HasSuffix(l.text, " ") && !strings.HasPrefix(text.text, " ") {
+		l.text += " "
+	}
+	l.text += text.text
+	l.end = math.Max(l.end, text.end)
+}
+
+// em returns the font size of the line, the width within which glyphs belong together
+func (l textLine) em() float64 {
+	return math.Max(l.size, sameLine)
+}
+
+// columnEdges returns the left edges of the columns, from left to right: positions where at
+// least minColumnLines lines start, at least minColumnWidth apart
+func columnEdges(lines []textLine) []float64 {
+	starts := make([]float64, 0, len(lines))
+	sizes := make([]float64, 0, len(lines))
+	for _, line := range lines {
+		starts = append(starts, line.x)
+		sizes = append(sizes, line.em())
+	}
+	slices.Sort(starts)
+	slices.Sort(sizes)
+
+	var edges []float64
+	for i := 0; i < len(starts); {
+		j := i
+		for j < len(starts) && starts[j]-starts[i] <= sameLine {
+			j++
+		}
+		if j-i >= minColumnLines && (len(edges) == 0 || starts[i]-edges[len(edges)-1] >= minColumnWidth*sizes[len(sizes)/2]) {
+			edges = append(edges, starts[i])
+		}
+		i = j
+	}
+	return edges
+}
+
+// columnOf returns the column of a line starting at x: the rightmost column starting at or
+// before it. Lines left of all columns are in the first.
+func columnOf(x float64, edges []float64) int {
+	column := 0
+	for i, edge := range edges {
+		if x >= edge-sameLine {
+			column = i
+		}
+	}
+	return column
+}
+
+// splitMargins separates the running headers and footers from the text of the page: the
+// rows at the top and bottom set apart from the rest by a gap of marginGap line spacings.
+// The margins are returned in the order they are drawn.
+func splitMargins(lines []textLine) (body, margins []textLine) {
+	var rows []float64
+	for _, line := range lines {
+		rows = append(rows, line.y)
+	}
+	slices.Sort(rows)
+	slices.Reverse(rows)
+	rows = slices.CompactFunc(rows, func(a, b float64) bool { return math.Abs(a-b) <= sameLine })
+
+	gap := marginGap * lineSpacing(lines)
+	if gap == 0 {
+		return lines, nil
+	}
+	top, bottom := math.Inf(1), math.Inf(-1)
+	for k := 1; k <= maxMarginRows && k < len(rows); k++ {
+		if rows[k-1]-rows[k] > gap {
+			top = rows[k-1]
+			break
+		}
+	}
+	for k := 1; k <= maxMarginRows && k < len(rows); k++ {
+		if rows[len(rows)-k-1]-rows[len(rows)-k] > gap {
+			bottom = rows[len(rows)-k]
+			break
+		}
+	}
+	if top <= bottom {
+		return lines, nil
+	}
+
+	for _, line := range lines {
+		if line.y >= top-sameLine || line.y <= bottom+sameLine {
+			margins = append(margins, line)
+		} else {
+			body = append(body, line)
+		}
+	}
+	return body, margins
+}
+
+// lineSpacing returns the most common distance between the baselines of successive lines in
+// the same column, or 0 if no column has more than one line
+func lineSpacing(lines []textLine) float64 {
+	byColumn := make(map[int][]float64)
+	for _, line := range lines {
+		byColumn[line.column] = append(byColumn[line.column], line.y)
+	}
+	counts := make(map[float64]int)
+	spacing := 0.0
+	for _, ys := range byColumn {
+		slices.Sort(ys)
+		for i := 1; i < len(ys); i++ {
+			d := math.Round(ys[i] - ys[i-1]) // Rounded so slightly different distances count together
+			if d <= sameLine {
+				continue
+			}
+			counts[d]++
+			if counts[d] > counts[spacing] || counts[d] == counts[spacing] && d < spacing {
+				spacing = d
+			}
+		}
+	}
+	return spacing
+}
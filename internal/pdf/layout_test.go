@@ -0,0 +1,122 @@
+package pdf
+
+import (
+	"cmp"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// glyphs returns the glyphs of the text drawn at the position, without widths like those of
+// the sample PDF
+func glyphs(x, y float64, s string) []pdf.Text {
+	var text []pdf.Text
+	for _, r := range s {
+		text = append(text, pdf.Text{FontSize: 10, X: x, Y: y, S: string(r)})
+	}
+	return text
+}
+
+func TestLayoutText(t *testing.T) {
+	// Two columns drawn row by row, between a running header and footer
+	page := slices.Concat(
+		glyphs(56, 797, "19.07.2025"),
+		glyphs(500, 797, "Nr. 138."),
+		glyphs(201.5, 747, "S1"),
+		glyphs(201.3, 747, "7072025-23"),
+		glyphs(320, 747, "S17072025-96"),
+		glyphs(56, 732, "ACEZONE ApS"),
+		glyphs(320, 732, "Smartbooks ApS"),
+		glyphs(56, 717, "CVR-nr.: 39293056"),
+		glyphs(320, 717, "CVR-nr.: 36500808"),
+		glyphs(56, 702, "samt "),
+		glyphs(90, 702, "mortifikationer"),
+		glyphs(320, 702, "Studsgade 17"),
+		glyphs(56, 39, "Konkursboer"),
+		glyphs(527, 39, "44"),
+	)
+
+	expected := strings.Join([]string{
+		"S17072025-23",
+		"ACEZONE ApS",
+		"CVR-nr.: 39293056",
+		"samt mortifikationer",
+		"S17072025-96",
+		"Smartbooks ApS",
+		"CVR-nr.: 36500808",
+		"Studsgade 17",
+		"19.07.2025",
+		"Nr. 138.",
+		"Konkursboer",
+		"44",
+	}, "\n")
+	if text := layoutText(page); text != expected {
+		t.Errorf("Unexpected text:\n%s\nexpected:\n%s", text, expected)
+	}
+}
+
+func TestLayoutText_SamplePDF(t *testing.T) {
+	file, reader, err := pdf.Open("../../statstidende_sample.pdf")
+	if err != nil {
+		t.Skipf("Sample PDF not available: %v", err)
+	}
+	defer file.Close()
+	page := reader.Page(44).Content().Text
+
+	// The sample draws its columns one after the other; with the text drawn row by row
+	// instead, followed by the header and footer, the columns are put back in the same order
+	var rows, margins []pdf.Text
+	for _, glyph := range page {
+		if glyph.Y > 780 || glyph.Y < 50 {
+			margins = append(margins, glyph)
+		} else {
+			rows = append(rows, glyph)
+		}
+	}
+	slices.SortStableFunc(rows, func(a, b pdf.Text) int {
+		return cmp.Compare(b.Y, a.Y)
+	})
+	rows = append(rows, margins...)
+	text := layoutText(page)
+	if rowText := layoutText(rows); rowText != text {
+		t.Errorf("Expected the same text from glyphs drawn row by row, got:\n%s\nexpected:\n%s", rowText, text)
+	}
+
+	lines := strings.Split(text, "\n")
+	acezone := slices.Index(lines, "ACEZONE ApS")
+	smartbooks := slices.Index(lines, "Smartbooks ApS")
+	if acezone < 0 || smartbooks < acezone {
+		t.Fatalf("Expected ACEZONE ApS in the left column before Smartbooks ApS in the right, got:\n%s", text)
+	}
+	if lines[acezone+1] != "CVR-nr.: 39293056" || lines[smartbooks+1] != "CVR-nr.: 36500808" {
+		t.Errorf("Expected each company followed by its own CVR number, got %q and %q", lines[acezone+1], lines[smartbooks+1])
+	}
+	if footer := lines[len(lines)-5:]; !slices.Equal(footer, []string{"Nr. 138.", " ", "19.07.2025", "Konkursboer", "44"}) {
+		t.Errorf("Expected the page to end with its footer, got %q", footer)
+	}
+}
+
+func TestReadColumns_SamplePDF(t *testing.T) {
+	file, err := os.Open("../../statstidende_sample.pdf")
+	if err != nil {
+		t.Skipf("Sample PDF not available: %v", err)
+	}
+	defer file.Close()
+
+	doc, err := ReadColumns(file)
+	if err != nil {
+		t.Fatalf("ReadColumns failed: %v", err)
+	}
+
+	// Every printed line is a line of the text, so matches are cited by their printed line
+	offset := strings.Index(doc.Text(), "Ved dekret af 16.07.2025 har Sø- og \nHandelsrettens skifteret taget\n")
+	if offset < 0 {
+		t.Fatal("Expected the text to follow the printed lines")
+	}
+	if page, _, _ := doc.Locate(offset); page != 44 {
+		t.Errorf("Expected the ACEZONE decree on page 44, got page %d", page)
+	}
+}
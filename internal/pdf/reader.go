@@ -2,42 +2,219 @@
 package pdf
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/ledongthuc/pdf"
 )
 
-// ExtractText extracts all text from a PDF file reader.
-func ExtractText(r io.Reader) (string, error) {
+// Document is the text of a PDF, page by page, so text found in it can be cited by page and
+// line of the printed document
+type Document struct {
+	Pages    []Page // Pages with content, in order
+	NumPages int    // Pages in the PDF, including those without content
+}
+
+// Page is the plain text of a single PDF page, one line per line of the page
+type Page struct {
+	Number int // 1-based page number
+	Text   string
+}
+
+// MaxSize is the size in bytes of the largest PDF that is read
+var MaxSize int64 = 100 << 20
+
+// ErrTooLarge is returned for a PDF larger than MaxSize
+var ErrTooLarge = errors.New("PDF is too large")
+
+// Read extracts the text of each page from a PDF file reader.
+// Pages without content are skipped, so page numbers may have gaps.
+// Readers that can seek, like bytes.Reader and os.File, are read in place; other streams
+// are copied to a temporary file first.
+func Read(r io.Reader) (*Document, error) {
+	return read(r, plainText)
+}
+
+// ReadAt extracts the text of each page from the size bytes of a PDF, like Read
+func ReadAt(r io.ReaderAt, size int64) (*Document, error) {
+	return readAt(r, size, plainText)
+}
+
+// plainText returns the text of the page in the order it is drawn
+func plainText(page pdf.Page) string {
+	content, _ := page.GetPlainText(nil)
+	return content
+}
+
+// read extracts the text of each page with the given function, from the reader in place if
+// it can seek or from a temporary copy of the stream
+func read(r io.Reader, pageText func(pdf.Page) string) (*Document, error) {
+	if section, ok := sectionOf(r); ok {
+		return readAt(section, section.Size(), pageText)
+	}
+
 	tmpFile, err := os.CreateTemp("", "egobot_pdf_*.pdf")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer os.Remove(tmpFile.Name())
-	_, err = io.Copy(tmpFile, r)
+	defer tmpFile.Close()
+
+	size, err := io.Copy(tmpFile, io.LimitReader(r, MaxSize+1))
 	if err != nil {
-		tmpFile.Close()
-		return "", err
+		return nil, err
+	}
+	return readAt(tmpFile, size, pageText)
+}
+
+// Section returns the PDF in the reader as a section that can be read again from any offset,
+// checking its size before anything is buffered: the reader itself from its current offset
+// if it can seek, or else the stream read into memory up to MaxSize.
+func Section(r io.Reader) (*io.SectionReader, error) {
+	section, ok := sectionOf(r)
+	if !ok {
+		data, err := io.ReadAll(io.LimitReader(r, MaxSize+1))
+		if err != nil {
+			return nil, err
+		}
+		section = io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data)))
 	}
-	tmpFile.Close()
+	if section.Size() > MaxSize {
+		return nil, errTooLarge()
+	}
+	return section, nil
+}
+
+// errTooLarge returns ErrTooLarge with the limit
+func errTooLarge() error {
+	return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, MaxSize)
+}
 
-	file, reader, err := pdf.Open(tmpFile.Name())
+// sectionOf returns the rest of the reader from its current offset, if it can be read at
+// any offset and seek to find its size
+func sectionOf(r io.Reader) (*io.SectionReader, bool) {
+	seeker, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	if !ok {
+		return nil, false
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return "", err
+		return nil, false
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, false
 	}
-	defer file.Close()
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return nil, false
+	}
+	return io.NewSectionReader(seeker, offset, end-offset), true
+}
 
-	var sb strings.Builder
-	n := reader.NumPage()
-	for i := 1; i <= n; i++ {
+// readAt extracts the text of each page of the size bytes of a PDF with the given function
+func readAt(r io.ReaderAt, size int64, pageText func(pdf.Page) string) (*Document, error) {
+	if size > MaxSize {
+		return nil, errTooLarge()
+	}
+	reader, err := pdf.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{NumPages: reader.NumPage()}
+	for i := 1; i <= doc.NumPages; i++ {
 		page := reader.Page(i)
 		if page.V.IsNull() {
 			continue
 		}
-		content, _ := page.GetPlainText(nil)
-		sb.WriteString(content)
+		doc.Pages = append(doc.Pages, Page{Number: i, Text: pageText(page)})
+	}
+	return doc, nil
+}
+
+// ExtractText extracts all text from a PDF file reader.
+//
+// Deprecated: use Read, which keeps the page boundaries.
+func ExtractText(r io.Reader) (string, error) {
+	doc, err := Read(r)
+	if err != nil {
+		return "", err
+	}
+	return doc.Text(), nil
+}
+
+// Text returns the text of all pages, each ending with a line break. Offsets in the text can
+// be mapped back to a page and line with Locate.
+func (d *Document) Text() string {
+	var sb strings.Builder
+	for _, page := range d.Pages {
+		sb.WriteString(page.text())
+	}
+	return sb.String()
+}
+
+// Page returns the page with the 1-based number, and false if it has no content
+func (d *Document) Page(number int) (Page, bool) {
+	for _, page := range d.Pages {
+		if page.Number == number {
+			return page, true
+		}
+	}
+	return Page{}, false
+}
+
+// Locate returns the page number and 1-based line on that page of the byte offset in Text,
+// and false if the offset is outside the text
+func (d *Document) Locate(offset int) (page, line int, ok bool) {
+	if offset < 0 {
+		return 0, 0, false
+	}
+	start := 0
+	for _, p := range d.Pages {
+		end := start + len(p.text())
+		if offset < end {
+			return p.Number, p.LineAt(offset - start), true
+		}
+		start = end
+	}
+	return 0, 0, false
+}
+
+// Lines returns the lines of the page, without line breaks
+func (p Page) Lines() []string {
+	return strings.Split(strings.TrimSuffix(p.Text, "\n"), "\n")
+}
+
+// LineOffsets returns the byte offset in Text at which each line starts
+func (p Page) LineOffsets() []int {
+	offsets := []int{0}
+	text := strings.TrimSuffix(p.Text, "\n")
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// LineAt returns the 1-based line containing the byte offset in Text
+func (p Page) LineAt(offset int) int {
+	offsets := p.LineOffsets()
+	return sort.Search(len(offsets), func(i int) bool { return offsets[i] > offset })
+}
+
+// text returns the text of the page ending with a line break, so pages don't run together
+func (p Page) text() string {
+	if strings.HasSuffix(p.Text, "\n") {
+		return p.Text
 	}
-	return sb.String(), nil
+	return p.Text + "\n"
 }
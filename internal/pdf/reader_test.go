@@ -0,0 +1,197 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRead_SamplePDF(t *testing.T) {
+	file, err := os.Open("../../statstidende_sample.pdf")
+	if err != nil {
+		t.Skipf("Sample PDF not available: %v", err)
+	}
+	defer file.Close()
+
+	doc, err := Read(file)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(doc.Pages) == 0 || doc.NumPages < len(doc.Pages) {
+		t.Fatalf("Expected pages, got %d of %d", len(doc.Pages), doc.NumPages)
+	}
+	for i, page := range doc.Pages {
+		if i > 0 && page.Number <= doc.Pages[i-1].Number {
+			t.Errorf("Expected increasing page numbers, got %d after %d", page.Number, doc.Pages[i-1].Number)
+		}
+	}
+
+	// The ACEZONE bankruptcy is printed on page 44
+	offset := strings.Index(doc.Text(), "ACEZONE ApS")
+	if offset < 0 {
+		t.Fatal("Expected the text to mention ACEZONE ApS")
+	}
+	page, line, ok := doc.Locate(offset)
+	if !ok || page != 44 {
+		t.Fatalf("Expected ACEZONE ApS on page 44, got page %d (%v)", page, ok)
+	}
+	p, _ := doc.Page(page)
+	if !strings.Contains(p.Lines()[line-1], "ACEZONE ApS") {
+		t.Errorf("Expected line %d of page 44 to mention ACEZONE ApS, got %q", line, p.Lines()[line-1])
+	}
+}
+
+func TestRead_Streams(t *testing.T) {
+	data, err := os.ReadFile("../../statstidende_sample.pdf")
+	if err != nil {
+		t.Skipf("Sample PDF not available: %v", err)
+	}
+	want, err := ReadAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("ReadAt failed: %v", err)
+	}
+
+	// Without a writable temporary directory, only streams that can't seek fail
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	doc, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if doc.Text() != want.Text() {
+		t.Error("Expected the same text from Read and ReadAt")
+	}
+	stream := struct{ io.Reader }{bytes.NewReader(data)}
+	if _, err := Read(stream); err == nil {
+		t.Error("Expected a stream to need a temporary file")
+	}
+
+	t.Setenv("TMPDIR", t.TempDir())
+	doc, err = Read(struct{ io.Reader }{bytes.NewReader(data)})
+	if err != nil {
+		t.Fatalf("Read failed for a stream: %v", err)
+	}
+	if doc.Text() != want.Text() {
+		t.Error("Expected the same text from a stream")
+	}
+	if files, _ := os.ReadDir(os.Getenv("TMPDIR")); len(files) != 0 {
+		t.Errorf("Expected the temporary file to be removed, found %d files", len(files))
+	}
+
+	// A reader is read from its current offset
+	padded := bytes.NewReader(append([]byte("garbage"), data...))
+	padded.Seek(int64(len("garbage")), io.SeekStart)
+	if doc, err := Read(padded); err != nil || doc.NumPages != want.NumPages {
+		t.Errorf("Expected the PDF after the offset to be read, got %v", err)
+	}
+}
+
+func TestRead_TooLarge(t *testing.T) {
+	data, err := os.ReadFile("../../statstidende_sample.pdf")
+	if err != nil {
+		t.Skipf("Sample PDF not available: %v", err)
+	}
+	maxSize := MaxSize
+	MaxSize = int64(len(data)) - 1
+	defer func() { MaxSize = maxSize }()
+
+	if _, err := ReadAt(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge from ReadAt, got %v", err)
+	}
+	if _, err := Read(bytes.NewReader(data)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge from Read, got %v", err)
+	}
+	if _, err := Read(struct{ io.Reader }{bytes.NewReader(data)}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge from a stream, got %v", err)
+	}
+}
+
+// endless is a stream that never ends
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestSection(t *testing.T) {
+	maxSize := MaxSize
+	MaxSize = 16
+	defer func() { MaxSize = maxSize }()
+
+	// A reader that can seek is used in place from its current offset
+	padded := bytes.NewReader([]byte("garbage%PDF-1.4"))
+	padded.Seek(int64(len("garbage")), io.SeekStart)
+	section, err := Section(padded)
+	if err != nil {
+		t.Fatalf("Section failed: %v", err)
+	}
+	if data, _ := io.ReadAll(section); string(data) != "%PDF-1.4" {
+		t.Errorf("Expected the PDF after the offset, got %q", data)
+	}
+	if padded.Len() != len("%PDF-1.4") {
+		t.Error("Expected the reader to be left at its offset")
+	}
+
+	section, err = Section(struct{ io.Reader }{strings.NewReader("%PDF-1.4")})
+	if err != nil {
+		t.Fatalf("Section failed for a stream: %v", err)
+	}
+	if data, _ := io.ReadAll(io.NewSectionReader(section, 0, section.Size())); string(data) != "%PDF-1.4" {
+		t.Errorf("Expected the stream, got %q", data)
+	}
+
+	if _, err := Section(bytes.NewReader(make([]byte, MaxSize+1))); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge for a large reader, got %v", err)
+	}
+	if _, err := Section(endless{}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge for a stream that never ends, got %v", err)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	doc := &Document{Pages: []Page{
+		{Number: 1, Text: "Indhold\nDødsboer"},
+		{Number: 3, Text: "S17072025-1\nAfdøde\nOle Keinicke\n"},
+	}, NumPages: 3}
+
+	// Pages don't run together in the text
+	text := doc.Text()
+	if text != "Indhold\nDødsboer\nS17072025-1\nAfdøde\nOle Keinicke\n" {
+		t.Errorf("Unexpected text %q", text)
+	}
+
+	tests := []struct {
+		needle     string
+		page, line int
+	}{
+		{"Indhold", 1, 1},
+		{"boer", 1, 2},
+		{"S17072025-1", 3, 1},
+		{"Keinicke", 3, 3},
+	}
+	for _, test := range tests {
+		page, line, ok := doc.Locate(strings.Index(text, test.needle))
+		if !ok || page != test.page || line != test.line {
+			t.Errorf("Locate(%q) = page %d line %d, expected page %d line %d", test.needle, page, line, test.page, test.line)
+		}
+	}
+	if _, _, ok := doc.Locate(len(text)); ok {
+		t.Error("Expected an offset past the text not to be found")
+	}
+
+	if _, ok := doc.Page(2); ok {
+		t.Error("Expected page 2 without content not to be found")
+	}
+	page, _ := doc.Page(3)
+	if lines := page.Lines(); !reflect.DeepEqual(lines, []string{"S17072025-1", "Afdøde", "Ole Keinicke"}) {
+		t.Errorf("Unexpected lines %q", lines)
+	}
+	if offsets := page.LineOffsets(); !reflect.DeepEqual(offsets, []int{0, 12, 20}) {
+		t.Errorf("Unexpected line offsets %v", offsets)
+	}
+}
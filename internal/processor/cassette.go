@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"egobot/internal/ai"
+	"egobot/internal/email"
+	"egobot/internal/pdf"
+)
+
+// CassetteFetcher records the fetched emails and their attachments to the cassette directory, or
+// replays the recorded emails without connecting to the mail server, so a run can be repeated
+// offline after its emails have left the search window
+type CassetteFetcher struct {
+	fetcher EmailFetcher
+	dir     string // Directory of the emails within the cassette directory
+	replay  bool
+}
+
+// recordedEmail is a fetched email as recorded in emails.json
+type recordedEmail struct {
+	ID          string               `json:"id"`
+	Subject     string               `json:"subject"`
+	From        string               `json:"from"`
+	Date        time.Time            `json:"date"`
+	PDFURLs     []string             `json:"pdf_urls,omitempty"`
+	Attachments []recordedAttachment `json:"attachments,omitempty"`
+}
+
+// recordedAttachment is an attachment of a recorded email, stored next to emails.json in a file
+// named by the SHA-256 of its content
+type recordedAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	File        string `json:"file"`
+}
+
+// NewCassetteFetcher wraps the fetcher, recording to or replaying from the cassette directory.
+// When replaying, the fetcher is not used.
+func NewCassetteFetcher(fetcher EmailFetcher, dir, mode string) (*CassetteFetcher, error) {
+	if err := ai.OpenCassette(dir, mode); err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "emails")
+	if mode == ai.CassetteRecord {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create cassette directory %s: %w", dir, err)
+		}
+	}
+	return &CassetteFetcher{fetcher: fetcher, dir: dir, replay: mode == ai.CassetteReplay}, nil
+}
+
+// FetchPDFEmails replays the recorded emails, or fetches them and records them. A failure to
+// record is logged, as the fetched emails can still be processed.
+func (f *CassetteFetcher) FetchPDFEmails() ([]email.EmailMessage, error) {
+	if f.replay {
+		return f.load()
+	}
+
+	messages, err := f.fetcher.FetchPDFEmails()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.record(messages); err != nil {
+		log.Printf("Failed to record the fetched emails: %v", err)
+	}
+	return messages, nil
+}
+
+// record writes the emails and their attachments to the cassette. The attachments are read in
+// place where possible, and replaced by readers from the start of the same content.
+func (f *CassetteFetcher) record(messages []email.EmailMessage) error {
+	recorded := make([]recordedEmail, 0, len(messages))
+	for i := range messages {
+		message := &messages[i]
+		recordedMessage := recordedEmail{
+			ID:      message.ID,
+			Subject: message.Subject,
+			From:    message.From,
+			Date:    message.Date,
+			PDFURLs: message.PDFURLs,
+		}
+		for j := range message.Attachments {
+			attachment := &message.Attachments[j]
+			section, err := pdf.Section(attachment.Data)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", attachment.Filename, err)
+			}
+			attachment.Data = io.NewSectionReader(section, 0, section.Size())
+
+			file, err := f.writeAttachment(io.NewSectionReader(section, 0, section.Size()))
+			if err != nil {
+				return fmt.Errorf("failed to record %s: %w", attachment.Filename, err)
+			}
+			recordedMessage.Attachments = append(recordedMessage.Attachments, recordedAttachment{
+				Filename:    attachment.Filename,
+				ContentType: attachment.ContentType,
+				File:        file,
+			})
+		}
+		recorded = append(recorded, recordedMessage)
+	}
+
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode emails: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(f.dir, "emails.json"), data, 0o644); err != nil {
+		return err
+	}
+	log.Printf("Recorded %d emails to %s", len(messages), f.dir)
+	return nil
+}
+
+// writeAttachment writes the content to a file named by its SHA-256 and returns the name
+func (f *CassetteFetcher) writeAttachment(content *io.SectionReader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	name := hex.EncodeToString(hash.Sum(nil)) + ".pdf"
+
+	file, err := os.Create(filepath.Join(f.dir, name))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(file, io.NewSectionReader(content, 0, content.Size())); err != nil {
+		file.Close()
+		return "", err
+	}
+	return name, file.Close()
+}
+
+// load returns the recorded emails with their attachments
+func (f *CassetteFetcher) load() ([]email.EmailMessage, error) {
+	data, err := os.ReadFile(filepath.Join(f.dir, "emails.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no emails recorded in %s", f.dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recorded emails: %w", err)
+	}
+	var recorded []recordedEmail
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("failed to parse recorded emails: %w", err)
+	}
+
+	messages := make([]email.EmailMessage, 0, len(recorded))
+	for _, recordedMessage := range recorded {
+		message := email.EmailMessage{
+			ID:      recordedMessage.ID,
+			Subject: recordedMessage.Subject,
+			From:    recordedMessage.From,
+			Date:    recordedMessage.Date,
+			PDFURLs: recordedMessage.PDFURLs,
+		}
+		for _, attachment := range recordedMessage.Attachments {
+			content, err := os.ReadFile(filepath.Join(f.dir, filepath.Base(attachment.File)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read recorded attachment %s: %w", attachment.Filename, err)
+			}
+			message.Attachments = append(message.Attachments, email.Attachment{
+				Filename:    attachment.Filename,
+				ContentType: attachment.ContentType,
+				Data:        bytes.NewReader(content),
+			})
+		}
+		messages = append(messages, message)
+	}
+	log.Printf("Replaying %d emails recorded in %s", len(messages), f.dir)
+	return messages, nil
+}
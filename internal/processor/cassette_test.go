@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"egobot/internal/ai"
+	"egobot/internal/ai/aitest"
+	"egobot/internal/config"
+	"egobot/internal/email"
+	"egobot/internal/watchlist"
+)
+
+func TestCassette_ReplayOffline(t *testing.T) {
+	data, err := os.ReadFile("../../statstidende_sample.pdf")
+	if err != nil {
+		t.Skipf("Sample PDF not available: %v", err)
+	}
+	pdfServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(data)
+	}))
+	defer pdfServer.Close()
+
+	answer := `{"matches":[{"entity":"ACEZONE ApS","announcement_id":"S17072025-23","case_type":"konkursbo","name":"ACEZONE ApS","cvr":"39293056"}]}`
+	server := aitest.NewServer(t)
+	server.Enqueue(aitest.Completed("gpt-4o-mini", answer), aitest.Completed("gpt-4o-mini", answer))
+
+	dir := t.TempDir()
+	cfg := &config.Config{
+		OpenAIAPIKey:   "sk-test",
+		OpenAIBaseURL:  server.BaseURL(),
+		LLMProvider:    ai.ProviderOpenAI,
+		LLMCassette:    ai.CassetteRecord,
+		LLMCassetteDir: dir,
+		Watchlist:      watchlist.FromStrings([]string{"ACEZONE ApS"}),
+	}
+	emails := []email.EmailMessage{{
+		ID:      "1",
+		Subject: "Statstidende",
+		From:    "noreply@statstidende.dk",
+		Date:    time.Date(2025, time.July, 17, 6, 0, 0, 0, time.UTC),
+		PDFURLs: []string{pdfServer.URL + "/statstidende.pdf"},
+		Attachments: []email.Attachment{
+			{Filename: "statstidende.pdf", ContentType: "application/pdf", Data: bytes.NewReader(data)},
+		},
+	}}
+
+	run := func(fetcher EmailFetcher) []email.AnalysisResult {
+		t.Helper()
+		extractor, err := NewExtractor(cfg)
+		if err != nil {
+			t.Fatalf("NewExtractor failed: %v", err)
+		}
+		cassette, err := NewCassetteFetcher(fetcher, cfg.LLMCassetteDir, cfg.LLMCassette)
+		if err != nil {
+			t.Fatalf("NewCassetteFetcher failed: %v", err)
+		}
+		sender := &MockEmailSender{}
+		proc := &Processor{config: cfg, fetcher: cassette, sender: sender, extractor: extractor, usage: NewUsageLedger()}
+		if err := proc.ProcessEmails(); err != nil {
+			t.Fatalf("ProcessEmails failed: %v", err)
+		}
+		for _, result := range sender.sentResults {
+			if result.Error != "" {
+				t.Errorf("Expected %s to be analysed, got %s", result.Filename, result.Error)
+			}
+		}
+		return sender.sentResults
+	}
+	recorded := run(&MockEmailFetcher{emails: emails})
+	if len(server.Requests()) != 2 {
+		t.Fatalf("Expected 2 requests to the model, got %d", len(server.Requests()))
+	}
+
+	// Without the mailbox, the model and the PDF server, the run is replayed from the cassette
+	pdfServer.Close()
+	cfg.OpenAIBaseURL = pdfServer.URL
+	cfg.LLMCassette = ai.CassetteReplay
+	replayed := run(&MockEmailFetcher{err: errors.New("the mailbox is not available")})
+
+	if len(replayed) != 2 || !reflect.DeepEqual(replayed, recorded) {
+		t.Fatalf("Expected the recorded results %+v, got %+v", recorded, replayed)
+	}
+	// The announcement the model read from the URL was verified against the replayed download
+	match, _ := replayed[0].Matches.Match("ACEZONE ApS")
+	if len(match.Announcements) != 1 || !match.Announcements[0].Verified {
+		t.Errorf("Expected a verified announcement, got %+v", match.Announcements)
+	}
+}
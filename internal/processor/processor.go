@@ -10,6 +10,8 @@ import (
 	"egobot/internal/ai"
 	"egobot/internal/config"
 	"egobot/internal/email"
+	"egobot/internal/prompt"
+	"egobot/internal/watchlist"
 )
 
 // Processor orchestrates the email fetching, PDF analysis, and result sending
@@ -18,6 +20,7 @@ type Processor struct {
 	fetcher   EmailFetcher
 	sender    EmailSender
 	extractor Extractor
+	usage     *UsageLedger
 }
 
 // EmailFetcher interface for email fetching
@@ -27,18 +30,18 @@ type EmailFetcher interface {
 
 // EmailSender interface for email sending
 type EmailSender interface {
-	SendAnalysisResults(results []email.AnalysisResult) error
+	SendAnalysisResults(results []email.AnalysisResult, today ai.Usage) error
 	SendErrorNotification(errorMsg string) error
 }
 
 // Extractor interface for AI extraction (allows both real and stubbed implementations)
 type Extractor interface {
-	ExtractEntitiesFromPDFFile(ctx context.Context, file interface{}, filename string, entities []string) (ai.ExtractionResult, error)
-	ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities []string) (ai.ExtractionResponse, error)
+	ExtractEntitiesFromPDFFile(ctx context.Context, file interface{}, filename string, entities watchlist.Watchlist) (ai.ExtractionResponse, error)
+	ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities watchlist.Watchlist) (ai.ExtractionResponse, error)
 }
 
 // NewProcessor creates a new email processor
-func NewProcessor(config *config.Config) *Processor {
+func NewProcessor(config *config.Config) (*Processor, error) {
 	// Create email fetcher
 	fetcherConfig := &email.Config{
 		Server:   config.IMAPServer,
@@ -47,7 +50,15 @@ func NewProcessor(config *config.Config) *Processor {
 		Password: config.IMAPPassword,
 		Folder:   config.IMAPFolder,
 	}
-	fetcher := email.NewEmailFetcher(fetcherConfig)
+	var fetcher EmailFetcher = email.NewEmailFetcher(fetcherConfig)
+	// A replayed run reads the recorded emails instead of the mailbox
+	if config.LLMCassette != "" {
+		cassette, err := NewCassetteFetcher(fetcher, config.LLMCassetteDir, config.LLMCassette)
+		if err != nil {
+			return nil, err
+		}
+		fetcher = cassette
+	}
 
 	// Create email sender
 	senderConfig := &email.SenderConfig{
@@ -60,14 +71,10 @@ func NewProcessor(config *config.Config) *Processor {
 	}
 	sender := email.NewEmailSender(senderConfig)
 
-	// Create extractor (real or stubbed)
-	var extractor Extractor
-	if config.OpenAIStub {
-		extractor = ai.NewStubExtractor()
-		log.Printf("Using stubbed AI extractor for testing")
-	} else {
-		extractor = &RealExtractor{}
-		log.Printf("Using real OpenAI extractor")
+	// Create extractor (local matcher, stubbed or real)
+	extractor, err := NewExtractor(config)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Processor{
@@ -75,29 +82,189 @@ func NewProcessor(config *config.Config) *Processor {
 		fetcher:   fetcher,
 		sender:    sender,
 		extractor: extractor,
+		usage:     NewUsageLedger(),
+	}, nil
+}
+
+// NewExtractor creates the configured extractor: the local matcher, the stub or an LLM
+func NewExtractor(config *config.Config) (Extractor, error) {
+	var extractor Extractor
+	if config.ExtractorMode == "matcher" {
+		extractor = &RealExtractor{extractor: ai.NewMatcherExtractor().WithLayout(config.PDFLayout)}
+		log.Printf("Using local matcher extractor, no LLM calls will be made")
+	} else if config.OpenAIStub {
+		stub, err := ai.LoadStubExtractor(config.StubFixturesDir)
+		if err != nil {
+			return nil, err
+		}
+		extractor = stub
+		log.Printf("Using stubbed AI extractor for testing")
+	} else {
+		provider, err := ai.NewProvider(providerConfig(config))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create LLM provider: %w", err)
+		}
+		// The processor is shared by the cron job and /extract, so they share the limiter too.
+		// Replayed answers are not sent, so they are not limited.
+		if config.LLMCassette != ai.CassetteReplay && (config.LLMRequestsPerMinute > 0 || config.LLMTokensPerMinute > 0) {
+			limiter := ai.NewRateLimiter(config.LLMRequestsPerMinute, config.LLMTokensPerMinute)
+			provider = ai.NewRateLimitedProvider(provider, limiter)
+			log.Printf("Limiting LLM requests to %d requests and %d tokens per minute", config.LLMRequestsPerMinute, config.LLMTokensPerMinute)
+		}
+		if config.LLMCassette != "" {
+			provider, err = ai.NewCassetteProvider(provider, config.LLMCassetteDir, config.LLMCassette)
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("LLM cassette mode %s, using %s", config.LLMCassette, config.LLMCassetteDir)
+		}
+		prompts, err := loadPrompts(config)
+		if err != nil {
+			return nil, err
+		}
+		llmExtractor := ai.NewLLMExtractor(provider).
+			WithContextWindow(config.LLMContextWindow).
+			WithPrompts(prompts).
+			WithPrices(config.LLMPrices).
+			WithFileUpload(config.LLMFileUpload).
+			WithLayout(config.PDFLayout)
+		// The PDFs downloaded to verify the answers are recorded and replayed with them
+		if config.LLMCassette != "" {
+			transport, err := ai.NewCassetteTransport(nil, config.LLMCassetteDir, config.LLMCassette)
+			if err != nil {
+				return nil, err
+			}
+			llmExtractor.WithTransport(transport)
+		}
+		store, err := cacheStore(config)
+		if err != nil {
+			return nil, err
+		}
+		if store != nil {
+			llmExtractor.WithCache(store, config.CacheTTL)
+		}
+		extractor = &RealExtractor{extractor: llmExtractor}
+		log.Printf("Using real %s extractor with model %s and prompt templates %v", provider.Name(), provider.Model(), prompts.Names())
+	}
+	return extractor, nil
+}
+
+// loadPrompts loads the prompt templates and checks that every template named on the watchlist
+// exists, so a typo fails at startup rather than when the first gazette arrives
+func loadPrompts(config *config.Config) (*prompt.Set, error) {
+	prompts, err := prompt.Load(config.PromptDir, config.PromptTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt templates: %w", err)
+	}
+	for _, name := range config.Watchlist.Prompts() {
+		if _, err := prompts.Get(name); err != nil {
+			return nil, fmt.Errorf("invalid watchlist: %w", err)
+		}
+	}
+	return prompts, nil
+}
+
+// cacheStore creates the configured cache of extraction results, nil if caching is disabled
+func cacheStore(config *config.Config) (ai.CacheStore, error) {
+	// Cached results would not be recorded, nor replayed
+	if config.LLMCassette != "" {
+		log.Printf("Not caching extraction results in cassette mode %s", config.LLMCassette)
+		return nil, nil
+	}
+	switch config.CacheStore {
+	case "disk":
+		store, err := ai.NewDiskCache(config.CacheDir)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Caching extraction results in %s for %v", config.CacheDir, config.CacheTTL)
+		return store, nil
+	case "none":
+		return nil, nil
+	default:
+		// Memory is the default, which also covers configurations created without Load
+		log.Printf("Caching extraction results in memory for %v", config.CacheTTL)
+		return ai.NewMemoryCache(), nil
+	}
+}
+
+// extractionContext returns the context for analysing one PDF
+func (p *Processor) extractionContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	if p.config.CacheBypass {
+		ctx = ai.BypassCache(ctx)
+	}
+	return ctx, cancel
+}
+
+// providerConfig maps the application configuration to the LLM provider settings
+func providerConfig(config *config.Config) ai.ProviderConfig {
+	providerConfig := ai.ProviderConfig{
+		Provider: config.LLMProvider,
+		Model:    config.LLMModel,
+	}
+
+	switch config.LLMProvider {
+	case ai.ProviderOpenAICompatible:
+		providerConfig.APIKey = config.LLMAPIKey
+		providerConfig.BaseURL = config.LLMBaseURL
+	case ai.ProviderAzure:
+		providerConfig.APIKey = config.AzureOpenAIAPIKey
+		providerConfig.AzureEndpoint = config.AzureOpenAIEndpoint
+		providerConfig.AzureDeployment = config.AzureOpenAIDeployment
+		providerConfig.AzureAPIVersion = config.AzureOpenAIAPIVersion
+	default:
+		providerConfig.APIKey = config.OpenAIAPIKey
+		providerConfig.BaseURL = config.OpenAIBaseURL
 	}
+
+	return providerConfig
+}
+
+// Extractor returns the extractor used by the processor, so other entry points can share it
+func (p *Processor) Extractor() Extractor {
+	return p.extractor
 }
 
-// RealExtractor wraps the real AI extractor
-type RealExtractor struct{}
+// ConfidenceThreshold returns the confidence below which matches are reported as possible
+func (p *Processor) ConfidenceThreshold() float64 {
+	return p.config.ConfidenceThreshold
+}
 
-func (r *RealExtractor) ExtractEntitiesFromPDFFile(ctx context.Context, file interface{}, filename string, entities []string) (ai.ExtractionResult, error) {
+// Usage returns the ledger of tokens spent per run and per day
+func (p *Processor) Usage() *UsageLedger {
+	return p.usage
+}
+
+// readerExtractor is implemented by the extractors in the ai package, which read PDFs from an io.Reader
+type readerExtractor interface {
+	ExtractEntitiesFromPDFFile(ctx context.Context, file io.Reader, filename string, entities watchlist.Watchlist) (ai.ExtractionResponse, error)
+	ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities watchlist.Watchlist) (ai.ExtractionResponse, error)
+}
+
+// RealExtractor wraps the real AI extractor or the local matcher
+type RealExtractor struct {
+	extractor readerExtractor
+}
+
+func (r *RealExtractor) ExtractEntitiesFromPDFFile(ctx context.Context, file interface{}, filename string, entities watchlist.Watchlist) (ai.ExtractionResponse, error) {
 	// Convert interface{} to io.Reader for the real extractor
 	if reader, ok := file.(io.Reader); ok {
-		return ai.ExtractEntitiesFromPDFFile(ctx, reader, filename, entities)
+		return r.extractor.ExtractEntitiesFromPDFFile(ctx, reader, filename, entities)
 	}
-	return nil, fmt.Errorf("file is not an io.Reader")
+	return ai.ExtractionResponse{}, fmt.Errorf("file is not an io.Reader")
 }
 
-func (r *RealExtractor) ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities []string) (ai.ExtractionResponse, error) {
-	return ai.ExtractEntitiesFromPDFURL(ctx, pdfURL, entities)
+func (r *RealExtractor) ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities watchlist.Watchlist) (ai.ExtractionResponse, error) {
+	return r.extractor.ExtractEntitiesFromPDFURL(ctx, pdfURL, entities)
 }
 
 // ProcessEmails fetches emails, analyzes PDFs, and sends results
 func (p *Processor) ProcessEmails() error {
-	log.Printf("Starting email processing at %s", time.Now().Format("2006-01-02 15:04:05"))
+	started := time.Now()
+	log.Printf("Starting email processing at %s", started.Format("2006-01-02 15:04:05"))
 
-	// 1. Fetch emails with PDF URLs
+	// 1. Fetch emails with PDF URLs or attachments
 	emailMessages, err := p.fetcher.FetchPDFEmails()
 	if err != nil {
 		log.Printf("Failed to fetch emails: %v", err)
@@ -105,13 +272,13 @@ func (p *Processor) ProcessEmails() error {
 	}
 
 	if len(emailMessages) == 0 {
-		log.Printf("No emails with PDF URLs found")
+		log.Printf("No emails with PDFs found")
 		return nil
 	}
 
-	log.Printf("Found %d emails with PDF URLs", len(emailMessages))
+	log.Printf("Found %d emails with PDFs", len(emailMessages))
 
-	// 2. Process each email and its PDF URLs
+	// 2. Process each email and its PDF URLs and attachments
 	var analysisResults []email.AnalysisResult
 	for _, emailMsg := range emailMessages {
 		log.Printf("Processing email: %s (from %s)", emailMsg.Subject, emailMsg.From)
@@ -120,11 +287,25 @@ func (p *Processor) ProcessEmails() error {
 			result := p.processPDFURL(pdfURL, emailMsg)
 			analysisResults = append(analysisResults, result)
 		}
+
+		for _, attachment := range emailMsg.Attachments {
+			result := p.processAttachment(attachment, emailMsg)
+			analysisResults = append(analysisResults, result)
+		}
+	}
+
+	// 3. Account for the tokens spent before reporting, so the email includes them
+	run := RunUsage{Started: started, PDFs: len(analysisResults)}
+	for _, result := range analysisResults {
+		run.Usage = run.Usage.Add(result.Usage)
 	}
+	p.usage.RecordRun(run)
+	log.Printf("Run used %d input and %d output tokens in %d requests (estimated $%.4f)",
+		run.Usage.InputTokens, run.Usage.OutputTokens, run.Usage.Requests, run.Usage.Cost)
 
-	// 3. Send results email
+	// 4. Send results email
 	if len(analysisResults) > 0 {
-		if err := p.sender.SendAnalysisResults(analysisResults); err != nil {
+		if err := p.sender.SendAnalysisResults(analysisResults, p.usage.Day(time.Now())); err != nil {
 			log.Printf("Failed to send analysis results: %v", err)
 			return fmt.Errorf("failed to send analysis results: %w", err)
 		}
@@ -147,23 +328,54 @@ func (p *Processor) processPDFURL(pdfURL string, emailMsg email.EmailMessage) em
 	log.Printf("Analyzing PDF from URL: %s", pdfURL)
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := p.extractionContext()
 	defer cancel()
 
 	// Extract entities from PDF URL
-	extractionResponse, err := p.extractor.ExtractEntitiesFromPDFURL(ctx, pdfURL, p.config.EntitiesToTrack)
+	extractionResponse, err := p.extractor.ExtractEntitiesFromPDFURL(ctx, pdfURL, p.config.Watchlist)
 	if err != nil {
 		log.Printf("Failed to extract entities from %s: %v", pdfURL, err)
 		result.Error = fmt.Sprintf("Failed to extract entities: %v", err)
 		return result
 	}
 
-	result.Entities = extractionResponse.Results
+	result.Matches = extractionResponse.Results.MarkPossible(p.config.ConfidenceThreshold)
 	result.RawResponse = extractionResponse.RawResponse
+	result.Usage = extractionResponse.Usage
 	log.Printf("Successfully extracted entities from %s", pdfURL)
 	return result
 }
 
+// processAttachment processes a single PDF attachment
+func (p *Processor) processAttachment(attachment email.Attachment, emailMsg email.EmailMessage) email.AnalysisResult {
+	result := email.AnalysisResult{
+		Filename:     attachment.Filename,
+		EmailSubject: emailMsg.Subject,
+		EmailFrom:    emailMsg.From,
+		EmailDate:    emailMsg.Date,
+	}
+
+	log.Printf("Analyzing PDF attachment: %s", attachment.Filename)
+
+	// Create context with timeout
+	ctx, cancel := p.extractionContext()
+	defer cancel()
+
+	// Extract entities from the attached PDF
+	extractionResponse, err := p.extractor.ExtractEntitiesFromPDFFile(ctx, attachment.Data, attachment.Filename, p.config.Watchlist)
+	if err != nil {
+		log.Printf("Failed to extract entities from %s: %v", attachment.Filename, err)
+		result.Error = fmt.Sprintf("Failed to extract entities: %v", err)
+		return result
+	}
+
+	result.Matches = extractionResponse.Results.MarkPossible(p.config.ConfidenceThreshold)
+	result.RawResponse = extractionResponse.RawResponse
+	result.Usage = extractionResponse.Usage
+	log.Printf("Successfully extracted entities from %s", attachment.Filename)
+	return result
+}
+
 // ProcessWithRetry processes emails with retry logic
 func (p *Processor) ProcessWithRetry() error {
 	var lastErr error
@@ -1,14 +1,19 @@
 package processor
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"egobot/internal/ai"
 	"egobot/internal/config"
 	"egobot/internal/email"
+	"egobot/internal/watchlist"
 )
 
 // MockEmailFetcher for testing
@@ -27,14 +32,16 @@ func (m *MockEmailFetcher) FetchPDFEmails() ([]email.EmailMessage, error) {
 // MockEmailSender for testing
 type MockEmailSender struct {
 	sentResults []email.AnalysisResult
+	sentToday   ai.Usage
 	err         error
 }
 
-func (m *MockEmailSender) SendAnalysisResults(results []email.AnalysisResult) error {
+func (m *MockEmailSender) SendAnalysisResults(results []email.AnalysisResult, today ai.Usage) error {
 	if m.err != nil {
 		return m.err
 	}
 	m.sentResults = results
+	m.sentToday = today
 	return nil
 }
 
@@ -45,46 +52,55 @@ func (m *MockEmailSender) SendErrorNotification(errorMsg string) error {
 // MockExtractor for testing
 type MockExtractor struct {
 	results ai.ExtractionResult
+	usage   ai.Usage
 	err     error
 }
 
-func (m *MockExtractor) ExtractEntitiesFromPDFFile(ctx context.Context, file interface{}, filename string, entities []string) (ai.ExtractionResult, error) {
+func (m *MockExtractor) ExtractEntitiesFromPDFFile(ctx context.Context, file interface{}, filename string, entities watchlist.Watchlist) (ai.ExtractionResponse, error) {
 	if m.err != nil {
-		return nil, m.err
+		return ai.ExtractionResponse{}, m.err
 	}
-	return m.results, nil
+	return ai.ExtractionResponse{
+		Results:     m.results,
+		RawResponse: `{"matches": []}`,
+		Usage:       m.usage,
+	}, nil
 }
 
-func (m *MockExtractor) ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities []string) (ai.ExtractionResponse, error) {
+func (m *MockExtractor) ExtractEntitiesFromPDFURL(ctx context.Context, pdfURL string, entities watchlist.Watchlist) (ai.ExtractionResponse, error) {
 	if m.err != nil {
 		return ai.ExtractionResponse{}, m.err
 	}
 	return ai.ExtractionResponse{
 		Results:     m.results,
-		RawResponse: "Mock raw response for testing",
+		RawResponse: `{"matches": []}`,
+		Usage:       m.usage,
 	}, nil
 }
 
 func TestNewProcessor(t *testing.T) {
 	cfg := &config.Config{
-		IMAPServer:      "imap.test.com",
-		IMAPPort:        993,
-		IMAPUsername:    "test@example.com",
-		IMAPPassword:    "password",
-		IMAPFolder:      "INBOX",
-		SMTPHost:        "smtp.test.com",
-		SMTPPort:        587,
-		SMTPUsername:    "test@example.com",
-		SMTPPassword:    "password",
-		SMTPFrom:        "from@example.com",
-		SMTPTo:          "to@example.com",
-		OpenAIStub:      true,
-		EntitiesToTrack: []string{"test"},
-	}
-
-	proc := NewProcessor(cfg)
+		IMAPServer:   "imap.test.com",
+		IMAPPort:     993,
+		IMAPUsername: "test@example.com",
+		IMAPPassword: "password",
+		IMAPFolder:   "INBOX",
+		SMTPHost:     "smtp.test.com",
+		SMTPPort:     587,
+		SMTPUsername: "test@example.com",
+		SMTPPassword: "password",
+		SMTPFrom:     "from@example.com",
+		SMTPTo:       "to@example.com",
+		OpenAIStub:   true,
+		Watchlist:    watchlist.FromStrings([]string{"test"}),
+	}
+
+	proc, err := NewProcessor(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
 	if proc == nil {
-		t.Error("Expected processor to be created")
+		t.Fatal("Expected processor to be created")
 	}
 
 	if proc.config != cfg {
@@ -92,9 +108,70 @@ func TestNewProcessor(t *testing.T) {
 	}
 }
 
+func TestNewProcessor_OpenAICompatible(t *testing.T) {
+	cfg := &config.Config{
+		OpenAIStub:  false,
+		LLMProvider: ai.ProviderOpenAICompatible,
+		LLMBaseURL:  "http://localhost:11434/v1",
+		LLMModel:    "llama3.1",
+	}
+
+	proc, err := NewProcessor(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, ok := proc.Extractor().(*RealExtractor); !ok {
+		t.Errorf("Expected a real extractor, got %T", proc.Extractor())
+	}
+}
+
+func TestNewProcessor_InvalidProvider(t *testing.T) {
+	cfg := &config.Config{
+		OpenAIStub:  false,
+		LLMProvider: "unknown",
+	}
+
+	if _, err := NewProcessor(cfg); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+}
+
+func TestNewProcessor_DiskCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	cfg := &config.Config{
+		OpenAIAPIKey: "sk-test",
+		LLMProvider:  ai.ProviderOpenAI,
+		CacheStore:   "disk",
+		CacheDir:     dir,
+		CacheTTL:     time.Hour,
+	}
+
+	if _, err := NewProcessor(cfg); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("Expected the cache directory to be created, got %v", err)
+	}
+}
+
+func TestNewProcessor_UnknownPrompt(t *testing.T) {
+	cfg := &config.Config{
+		OpenAIStub:   false,
+		OpenAIAPIKey: "sk-test",
+		LLMProvider:  ai.ProviderOpenAI,
+		Watchlist:    watchlist.Watchlist{{Kind: watchlist.KindCPR, Value: "0801620450", Prompt: "foged"}},
+	}
+
+	_, err := NewProcessor(cfg)
+	if err == nil || !strings.Contains(err.Error(), `unknown prompt template "foged"`) {
+		t.Errorf("Expected an error for the unknown prompt template, got %v", err)
+	}
+}
+
 func TestProcessor_ProcessEmails_NoEmails(t *testing.T) {
 	cfg := &config.Config{
-		EntitiesToTrack: []string{"test"},
+		Watchlist: watchlist.FromStrings([]string{"test"}),
 	}
 
 	proc := &Processor{
@@ -104,6 +181,7 @@ func TestProcessor_ProcessEmails_NoEmails(t *testing.T) {
 		},
 		sender:    &MockEmailSender{},
 		extractor: &MockExtractor{},
+		usage:     NewUsageLedger(),
 	}
 
 	err := proc.ProcessEmails()
@@ -114,7 +192,7 @@ func TestProcessor_ProcessEmails_NoEmails(t *testing.T) {
 
 func TestProcessor_ProcessEmails_WithEmails(t *testing.T) {
 	cfg := &config.Config{
-		EntitiesToTrack: []string{"test", "example"},
+		Watchlist: watchlist.FromStrings([]string{"test", "example"}),
 	}
 
 	mockFetcher := &MockEmailFetcher{
@@ -133,9 +211,10 @@ func TestProcessor_ProcessEmails_WithEmails(t *testing.T) {
 
 	mockExtractor := &MockExtractor{
 		results: ai.ExtractionResult{
-			"test":    "Test entity found",
-			"example": "Example entity found",
+			{Entity: "test", Announcements: []ai.Announcement{{Kind: ai.KindDoedsbo, Name: "Test Person"}}},
+			{Entity: "example", Announcements: []ai.Announcement{{Kind: ai.KindKonkursbo, Name: "Example ApS"}}},
 		},
+		usage: ai.Usage{Requests: 2, InputTokens: 12000, OutputTokens: 800, Cost: 0.00228},
 	}
 
 	proc := &Processor{
@@ -143,7 +222,11 @@ func TestProcessor_ProcessEmails_WithEmails(t *testing.T) {
 		fetcher:   mockFetcher,
 		sender:    mockSender,
 		extractor: mockExtractor,
+		usage:     NewUsageLedger(),
 	}
+	// An earlier run today
+	earlier := ai.Usage{Requests: 1, InputTokens: 5000, OutputTokens: 100, Cost: 0.00081}
+	proc.usage.Record(time.Now(), earlier)
 
 	err := proc.ProcessEmails()
 	if err != nil {
@@ -159,14 +242,30 @@ func TestProcessor_ProcessEmails_WithEmails(t *testing.T) {
 		t.Errorf("Expected filename 'statstidende.pdf', got %s", result.Filename)
 	}
 
-	if len(result.Entities) != 2 {
-		t.Errorf("Expected 2 entities, got %d", len(result.Entities))
+	if len(result.Matches) != 2 {
+		t.Errorf("Expected 2 entities, got %d", len(result.Matches))
+	}
+	if result.Usage != mockExtractor.usage {
+		t.Errorf("Expected the usage to be reported, got %+v", result.Usage)
+	}
+
+	report := proc.Usage().Report()
+	if report.LastRun == nil || report.LastRun.PDFs != 1 || report.LastRun.Usage != mockExtractor.usage {
+		t.Errorf("Expected the run to be recorded, got %+v", report.LastRun)
+	}
+	today := earlier.Add(mockExtractor.usage)
+	if len(report.Days) != 1 || report.Days[0].Usage != today {
+		t.Errorf("Expected the usage of today, got %+v", report.Days)
+	}
+	// The run is recorded before the email is sent, so the day total includes it
+	if mockSender.sentToday != today {
+		t.Errorf("Expected the email to report %+v used today, got %+v", today, mockSender.sentToday)
 	}
 }
 
 func TestProcessor_ProcessEmails_ExtractionError(t *testing.T) {
 	cfg := &config.Config{
-		EntitiesToTrack: []string{"test"},
+		Watchlist: watchlist.FromStrings([]string{"test"}),
 	}
 
 	mockFetcher := &MockEmailFetcher{
@@ -192,6 +291,7 @@ func TestProcessor_ProcessEmails_ExtractionError(t *testing.T) {
 		fetcher:   mockFetcher,
 		sender:    mockSender,
 		extractor: mockExtractor,
+		usage:     NewUsageLedger(),
 	}
 
 	err := proc.ProcessEmails()
@@ -208,3 +308,98 @@ func TestProcessor_ProcessEmails_ExtractionError(t *testing.T) {
 		t.Error("Expected error to be set in result")
 	}
 }
+
+func TestProcessor_ProcessEmails_WithAttachments(t *testing.T) {
+	cfg := &config.Config{
+		Watchlist: watchlist.FromStrings([]string{"test"}),
+	}
+
+	mockFetcher := &MockEmailFetcher{
+		emails: []email.EmailMessage{
+			{
+				ID:      "1",
+				Subject: "Statstidende PDF",
+				From:    "sender@example.com",
+				Date:    time.Now(),
+				Attachments: []email.Attachment{
+					{
+						Filename:    "kundgoerelser.pdf",
+						ContentType: "application/pdf",
+						Data:        bytes.NewReader([]byte("%PDF-1.4")),
+					},
+				},
+			},
+		},
+	}
+
+	mockSender := &MockEmailSender{}
+
+	mockExtractor := &MockExtractor{
+		results: ai.ExtractionResult{
+			{Entity: "test", Announcements: []ai.Announcement{{Kind: ai.KindDoedsbo, Name: "Test Person"}}},
+		},
+	}
+
+	proc := &Processor{
+		config:    cfg,
+		fetcher:   mockFetcher,
+		sender:    mockSender,
+		extractor: mockExtractor,
+		usage:     NewUsageLedger(),
+	}
+
+	if err := proc.ProcessEmails(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if len(mockSender.sentResults) != 1 {
+		t.Fatalf("Expected 1 result to be sent, got %d", len(mockSender.sentResults))
+	}
+
+	result := mockSender.sentResults[0]
+	if result.Filename != "kundgoerelser.pdf" {
+		t.Errorf("Expected filename 'kundgoerelser.pdf', got %s", result.Filename)
+	}
+
+	if len(result.Matches) != 1 || !result.Matches[0].Found() {
+		t.Errorf("Expected 1 match with announcements, got %v", result.Matches)
+	}
+}
+
+func TestNewProcessor_MatcherMode(t *testing.T) {
+	cfg := &config.Config{
+		OpenAIStub:    true,
+		ExtractorMode: "matcher",
+	}
+
+	proc, err := NewProcessor(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	real, ok := proc.Extractor().(*RealExtractor)
+	if !ok {
+		t.Fatalf("Expected a real extractor, got %T", proc.Extractor())
+	}
+	if _, ok := real.extractor.(*ai.MatcherExtractor); !ok {
+		t.Errorf("Expected the local matcher, got %T", real.extractor)
+	}
+}
+
+func TestNewProcessor_CassetteReplay(t *testing.T) {
+	cfg := &config.Config{
+		OpenAIAPIKey:   "sk-test",
+		LLMProvider:    ai.ProviderOpenAI,
+		LLMCassette:    ai.CassetteReplay,
+		LLMCassetteDir: filepath.Join(t.TempDir(), "missing"),
+	}
+
+	if _, err := NewProcessor(cfg); err == nil || !strings.Contains(err.Error(), "cassette directory") {
+		t.Errorf("Expected an error for the missing recordings, got %v", err)
+	}
+
+	cfg.LLMCassetteDir = t.TempDir()
+	if _, err := NewProcessor(cfg); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
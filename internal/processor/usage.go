@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"egobot/internal/ai"
+)
+
+// usageDays is the number of days the ledger keeps
+const usageDays = 90
+
+// RunUsage is the usage of one processing run
+type RunUsage struct {
+	Started time.Time `json:"started"`
+	PDFs    int       `json:"pdfs"`
+	Usage   ai.Usage  `json:"usage"`
+}
+
+// DayUsage is the usage of one day, including requests to /extract
+type DayUsage struct {
+	Date  string   `json:"date"` // YYYY-MM-DD in local time
+	Usage ai.Usage `json:"usage"`
+}
+
+// UsageReport summarizes the usage for the /usage endpoint
+type UsageReport struct {
+	LastRun *RunUsage  `json:"last_run"`
+	Days    []DayUsage `json:"days"` // Newest first
+	Total   ai.Usage   `json:"total"`
+}
+
+// UsageLedger aggregates the tokens spent per run and per day. It is kept in memory,
+// so the totals start over when the service restarts.
+type UsageLedger struct {
+	mu      sync.Mutex
+	days    map[string]ai.Usage
+	lastRun *RunUsage
+}
+
+// NewUsageLedger creates an empty ledger
+func NewUsageLedger() *UsageLedger {
+	return &UsageLedger{days: make(map[string]ai.Usage)}
+}
+
+// Record adds the usage of a single extraction to the day it happened
+func (l *UsageLedger) Record(at time.Time, usage ai.Usage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.record(at, usage)
+}
+
+// RecordRun records a processing run and adds its usage to the day it started
+func (l *UsageLedger) RecordRun(run RunUsage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastRun = &run
+	l.record(run.Started, run.Usage)
+}
+
+// Day returns the usage of the day of the given time
+func (l *UsageLedger) Day(at time.Time) ai.Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.days[at.Format(time.DateOnly)]
+}
+
+// Report returns the last run and the usage per day
+func (l *UsageLedger) Report() UsageReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	report := UsageReport{Days: make([]DayUsage, 0, len(l.days))}
+	if l.lastRun != nil {
+		run := *l.lastRun
+		report.LastRun = &run
+	}
+	for date, usage := range l.days {
+		report.Days = append(report.Days, DayUsage{Date: date, Usage: usage})
+		report.Total = report.Total.Add(usage)
+	}
+	sort.Slice(report.Days, func(i, j int) bool {
+		return report.Days[i].Date > report.Days[j].Date
+	})
+	return report
+}
+
+// record adds the usage to its day and drops the days beyond usageDays; the caller holds the lock
+func (l *UsageLedger) record(at time.Time, usage ai.Usage) {
+	date := at.Format(time.DateOnly)
+	l.days[date] = l.days[date].Add(usage)
+
+	cutoff := at.AddDate(0, 0, -usageDays).Format(time.DateOnly)
+	for day := range l.days {
+		if day <= cutoff {
+			delete(l.days, day)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"egobot/internal/ai"
+)
+
+func TestUsageLedger(t *testing.T) {
+	ledger := NewUsageLedger()
+	day := time.Date(2025, 7, 17, 6, 0, 0, 0, time.Local)
+
+	ledger.RecordRun(RunUsage{Started: day, PDFs: 1, Usage: ai.Usage{Requests: 1, InputTokens: 1000, OutputTokens: 100, Cost: 0.01}})
+	ledger.Record(day.Add(3*time.Hour), ai.Usage{Requests: 2, InputTokens: 500, OutputTokens: 50, Cost: 0.005})
+	ledger.RecordRun(RunUsage{Started: day.AddDate(0, 0, 1), PDFs: 2, Usage: ai.Usage{Requests: 1, InputTokens: 10, OutputTokens: 1}})
+
+	if got := ledger.Day(day); got.Requests != 3 || got.InputTokens != 1500 || got.OutputTokens != 150 {
+		t.Errorf("Expected the run and the extraction on the same day to be added, got %+v", got)
+	}
+
+	report := ledger.Report()
+	if report.LastRun == nil || report.LastRun.PDFs != 2 {
+		t.Errorf("Expected the last run, got %+v", report.LastRun)
+	}
+	if len(report.Days) != 2 || report.Days[0].Date != "2025-07-18" || report.Days[1].Date != "2025-07-17" {
+		t.Errorf("Expected the days newest first, got %+v", report.Days)
+	}
+	if report.Total.InputTokens != 1510 || report.Total.Requests != 4 {
+		t.Errorf("Unexpected total %+v", report.Total)
+	}
+
+	// Old days are dropped
+	ledger.Record(day.AddDate(0, 0, usageDays), ai.Usage{Requests: 1})
+	if got := ledger.Day(day); got.Requests != 0 {
+		t.Errorf("Expected days older than %d days to be dropped, got %+v", usageDays, got)
+	}
+}
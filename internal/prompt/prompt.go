@@ -0,0 +1,156 @@
+// Package prompt loads the instructions sent to the language model from text/template files.
+// The default templates are embedded in the binary and can be overridden or extended with
+// templates from a directory, so clients can get different instructions without a redeploy.
+package prompt
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"egobot/internal/watchlist"
+)
+
+// DefaultName is the template used for entities that don't name one
+const DefaultName = "advokat"
+
+// extension is the file extension of prompt templates; the file name without it is the template name
+const extension = ".tmpl"
+
+//go:embed templates/*.tmpl
+var defaults embed.FS
+
+// Data is passed to the templates when rendering a prompt
+type Data struct {
+	Task     string              // What is analysed, e.g. "Analyser denne udgave af statstidende"
+	Entities watchlist.Watchlist // The entities to look for
+}
+
+// Template is a parsed prompt template. Every template defines a "version" template holding
+// its version id, which is recorded with the results it produces.
+type Template struct {
+	Name    string
+	Version string
+	tmpl    *template.Template
+}
+
+// ID identifies the template and its version, e.g. advokat@1
+func (t *Template) ID() string {
+	return t.Name + "@" + t.Version
+}
+
+// Render renders the prompt for the data
+func (t *Template) Render(data Data) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", t.ID(), err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// Set holds the available templates by name
+type Set struct {
+	templates   map[string]*Template
+	defaultName string
+}
+
+// Load loads the embedded templates and then the *.tmpl files in dir, which replace the
+// embedded template of the same name. dir may be empty. defaultName selects the template
+// for entities that don't name one, DefaultName if empty.
+func Load(dir, defaultName string) (*Set, error) {
+	if defaultName == "" {
+		defaultName = DefaultName
+	}
+	set := &Set{templates: make(map[string]*Template), defaultName: defaultName}
+
+	if err := set.load(defaults, "templates"); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := set.load(os.DirFS(dir), "."); err != nil {
+			return nil, fmt.Errorf("failed to load prompt templates from %s: %w", dir, err)
+		}
+	}
+
+	if _, ok := set.templates[defaultName]; !ok {
+		return nil, fmt.Errorf("default prompt template %q not found, have %s", defaultName, strings.Join(set.Names(), ", "))
+	}
+	return set, nil
+}
+
+// Default returns the embedded templates
+func Default() *Set {
+	set, err := Load("", "")
+	if err != nil {
+		panic(err) // The embedded templates are covered by tests
+	}
+	return set
+}
+
+// Get returns the template with the given name, or the default template if name is empty
+func (s *Set) Get(name string) (*Template, error) {
+	if name == "" {
+		name = s.defaultName
+	}
+	tmpl, ok := s.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt template %q, have %s", name, strings.Join(s.Names(), ", "))
+	}
+	return tmpl, nil
+}
+
+// Names returns the names of the templates in alphabetical order
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.templates))
+	for name := range s.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// load parses every template in the directory of the file system
+func (s *Set) load(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*"+extension))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		text, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		tmpl, err := Parse(strings.TrimSuffix(path.Base(file), extension), string(text))
+		if err != nil {
+			return err
+		}
+		s.templates[tmpl.Name] = tmpl
+	}
+	return nil
+}
+
+// Parse parses a prompt template, which must define its version
+func Parse(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template %s: %w", name, err)
+	}
+
+	if tmpl.Lookup("version") == nil {
+		return nil, fmt.Errorf("prompt template %s has no version, add {{define \"version\"}}1{{end}}", name)
+	}
+	var version strings.Builder
+	if err := tmpl.ExecuteTemplate(&version, "version", nil); err != nil {
+		return nil, fmt.Errorf("failed to read the version of prompt template %s: %w", name, err)
+	}
+	if strings.TrimSpace(version.String()) == "" {
+		return nil, fmt.Errorf("prompt template %s has an empty version", name)
+	}
+
+	return &Template{Name: name, Version: strings.TrimSpace(version.String()), tmpl: tmpl}, nil
+}
@@ -0,0 +1,117 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"egobot/internal/watchlist"
+)
+
+func TestDefault(t *testing.T) {
+	tmpl, err := Default().Get("")
+	if err != nil {
+		t.Fatalf("Expected the default template, got %v", err)
+	}
+	if tmpl.ID() != "advokat@2" {
+		t.Errorf("Expected ID advokat@2, got %s", tmpl.ID())
+	}
+
+	text, err := tmpl.Render(Data{
+		Task:     "Analyser denne udgave af statstidende",
+		Entities: watchlist.Watchlist{{Value: "0801620450", Kind: watchlist.KindCPR}, {Value: "Jette Fries Lundsted", Kind: watchlist.KindPerson}, {Value: "Husmandsvej"}},
+	})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	expected := []string{
+		"Du er advokat",
+		"Analyser denne udgave af statstidende og find relevant info",
+		"\t- 0801620450 (cpr-nummer, kan også stå som DDMMÅÅ-SSSS)\n",
+		"\t- Jette Fries Lundsted (personnavn)\n",
+		"\t- Husmandsvej\n\n",
+	}
+	for _, part := range expected {
+		if !strings.Contains(text, part) {
+			t.Errorf("Expected the prompt to contain %q, got:\n%s", part, text)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "advokat.tmpl", `{{define "version"}}2{{end}}Ny prompt: {{.Task}}`)
+	writeTemplate(t, dir, "ejendom.tmpl", `{{define "version"}}2025-07{{end}}{{range .Entities}}{{.Value}};{{end}}`)
+	writeTemplate(t, dir, "README.md", "not a template")
+
+	set, err := Load(dir, "ejendom")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if names := strings.Join(set.Names(), ","); names != "advokat,ejendom" {
+		t.Errorf("Expected templates advokat,ejendom, got %s", names)
+	}
+
+	// The directory replaces the embedded template of the same name
+	advokat, _ := set.Get("advokat")
+	if text, _ := advokat.Render(Data{Task: "Analyser"}); advokat.ID() != "advokat@2" || text != "Ny prompt: Analyser" {
+		t.Errorf("Expected the overridden template, got %s: %q", advokat.ID(), text)
+	}
+
+	tmpl, err := set.Get("")
+	if err != nil || tmpl.ID() != "ejendom@2025-07" {
+		t.Fatalf("Expected the configured default, got %v, %v", tmpl, err)
+	}
+	text, _ := tmpl.Render(Data{Entities: watchlist.FromStrings([]string{"1a", "2b"})})
+	if text != "1a;2b;" {
+		t.Errorf("Unexpected rendering %q", text)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := map[string]struct {
+		template    string
+		defaultName string
+		expected    string
+	}{
+		"no version":      {template: `Analyser {{.Task}}`, expected: "has no version"},
+		"empty version":   {template: `{{define "version"}} {{end}}{{.Task}}`, expected: "empty version"},
+		"syntax error":    {template: `{{define "version"}}1{{end}}{{.Task`, expected: "failed to parse"},
+		"unknown default": {template: `{{define "version"}}1{{end}}`, defaultName: "foged", expected: `default prompt template "foged" not found`},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTemplate(t, dir, "test.tmpl", tt.template)
+			_, err := Load(dir, tt.defaultName)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestGet_Unknown(t *testing.T) {
+	_, err := Default().Get("foged")
+	if err == nil || !strings.Contains(err.Error(), "advokat") {
+		t.Errorf("Expected an error listing the available templates, got %v", err)
+	}
+}
+
+func TestRender_MissingField(t *testing.T) {
+	tmpl, err := Parse("test", `{{define "version"}}1{{end}}{{.Opgave}}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := tmpl.Render(Data{}); err == nil {
+		t.Error("Expected an error for a field Data doesn't have")
+	}
+}
+
+func writeTemplate(t *testing.T, dir, name, text string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,307 @@
+// Package watchlist describes the persons, companies and properties we watch
+// Statstidende for, and how they are configured.
+package watchlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"egobot/internal/ident"
+)
+
+// Kind is the type of a watched entity, which decides how it is matched
+type Kind string
+
+const (
+	KindPerson   Kind = "person"   // Full name of a person
+	KindCPR      Kind = "cpr"      // CPR number of a person
+	KindCompany  Kind = "company"  // Company name
+	KindCVR      Kind = "cvr"      // CVR number of a company
+	KindAddress  Kind = "address"  // Street address, postcode or town
+	KindMatrikel Kind = "matrikel" // Cadastral number of a property
+	KindAny      Kind = ""         // Not specified, every matching strategy is tried
+)
+
+// Kinds lists the kinds that can be configured
+var Kinds = []Kind{KindPerson, KindCPR, KindCompany, KindCVR, KindAddress, KindMatrikel}
+
+// companySuffixes are the legal forms that identify a company name
+var companySuffixes = []string{"aps", "a/s", "i/s", "ivs", "k/s", "p/s", "amba", "a.m.b.a.", "smba", "s.m.b.a.", "holding"}
+
+// Entity is a single identifier on the watchlist
+type Entity struct {
+	Kind    Kind   `json:"kind"`
+	Value   string `json:"value"`
+	Subject string `json:"subject,omitempty"` // Optional subject (e.g. a client) the entity belongs to
+	Prompt  string `json:"prompt,omitempty"`  // Prompt template used for the entity, empty for the default
+}
+
+// String returns the value, which is how the entity is shown and reported
+func (e Entity) String() string {
+	return e.Value
+}
+
+// Watchlist is the list of entities to look for, in the order they were configured
+type Watchlist []Entity
+
+// Values returns the values of all entities
+func (w Watchlist) Values() []string {
+	values := make([]string, 0, len(w))
+	for _, entity := range w {
+		values = append(values, entity.Value)
+	}
+	return values
+}
+
+// Subjects returns the distinct subjects in the order they first appear
+func (w Watchlist) Subjects() []string {
+	var subjects []string
+	seen := make(map[string]bool)
+	for _, entity := range w {
+		if entity.Subject != "" && !seen[entity.Subject] {
+			seen[entity.Subject] = true
+			subjects = append(subjects, entity.Subject)
+		}
+	}
+	return subjects
+}
+
+// Subject returns the entities belonging to the given subject
+func (w Watchlist) Subject(subject string) Watchlist {
+	var entities Watchlist
+	for _, entity := range w {
+		if entity.Subject == subject {
+			entities = append(entities, entity)
+		}
+	}
+	return entities
+}
+
+// Prompts returns the distinct prompt templates in the order they first appear,
+// with "" standing for the default template
+func (w Watchlist) Prompts() []string {
+	var prompts []string
+	seen := make(map[string]bool)
+	for _, entity := range w {
+		if !seen[entity.Prompt] {
+			seen[entity.Prompt] = true
+			prompts = append(prompts, entity.Prompt)
+		}
+	}
+	return prompts
+}
+
+// Prompt returns the entities using the given prompt template, "" for the default
+func (w Watchlist) Prompt(prompt string) Watchlist {
+	var entities Watchlist
+	for _, entity := range w {
+		if entity.Prompt == prompt {
+			entities = append(entities, entity)
+		}
+	}
+	return entities
+}
+
+// subjectPayload groups several identifiers under one subject in the JSON configuration
+type subjectPayload struct {
+	Subject  string            `json:"subject"`
+	Prompt   string            `json:"prompt"` // Prompt template for the subject's entities
+	Entities []json.RawMessage `json:"entities"`
+}
+
+// Parse parses a watchlist. It accepts a JSON array whose items are plain strings, typed entities
+// ({"kind":"cpr","value":"0605410146"}) or subjects grouping several entities
+// ({"subject":"Client A","prompt":"konkurs","entities":[...]}), as well as a comma-separated list
+// of strings.
+// Strings may be prefixed with their kind ("cvr:39293056"); otherwise the kind is inferred.
+func Parse(s string) (Watchlist, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Watchlist{}, nil
+	}
+
+	if !strings.HasPrefix(s, "[") {
+		// Fallback to comma-separated values
+		var watchlist Watchlist
+		for _, item := range strings.Split(s, ",") {
+			entity, err := ParseEntity(item)
+			if err != nil {
+				return nil, err
+			}
+			watchlist = append(watchlist, entity)
+		}
+		return watchlist, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(s), &items); err != nil {
+		return nil, fmt.Errorf("failed to parse watchlist: %w", err)
+	}
+	return parseItems(items, "", "")
+}
+
+// parseItems parses the JSON items of a watchlist, assigning them to the subject if given.
+// Entities use the subject's prompt template unless they name their own.
+func parseItems(items []json.RawMessage, subject, prompt string) (Watchlist, error) {
+	watchlist := Watchlist{}
+	for _, item := range items {
+		var str string
+		if err := json.Unmarshal(item, &str); err == nil {
+			entity, err := ParseEntity(str)
+			if err != nil {
+				return nil, err
+			}
+			entity.Subject = subject
+			entity.Prompt = prompt
+			watchlist = append(watchlist, entity)
+			continue
+		}
+
+		var group subjectPayload
+		if err := json.Unmarshal(item, &group); err == nil && group.Entities != nil {
+			if subject != "" {
+				return nil, fmt.Errorf("subject %q cannot be nested in subject %q", group.Subject, subject)
+			}
+			if strings.TrimSpace(group.Subject) == "" {
+				return nil, fmt.Errorf("subject name is required: %s", item)
+			}
+			entities, err := parseItems(group.Entities, strings.TrimSpace(group.Subject), strings.TrimSpace(group.Prompt))
+			if err != nil {
+				return nil, err
+			}
+			watchlist = append(watchlist, entities...)
+			continue
+		}
+
+		var entity Entity
+		if err := json.Unmarshal(item, &entity); err != nil {
+			return nil, fmt.Errorf("invalid watchlist item %s: %w", item, err)
+		}
+		if subject != "" {
+			entity.Subject = subject
+		}
+		entityPrompt := strings.TrimSpace(entity.Prompt)
+		if entityPrompt == "" {
+			entityPrompt = prompt
+		}
+		entity, err := NewEntity(entity.Kind, entity.Value, entity.Subject)
+		if err != nil {
+			return nil, err
+		}
+		entity.Prompt = entityPrompt
+		watchlist = append(watchlist, entity)
+	}
+	return watchlist, nil
+}
+
+// ParseEntity parses a single string, optionally prefixed with its kind ("cpr:0605410146").
+// Without a prefix the kind is inferred from the value.
+func ParseEntity(s string) (Entity, error) {
+	s = strings.TrimSpace(s)
+	if prefix, value, ok := strings.Cut(s, ":"); ok {
+		kind := Kind(strings.ToLower(strings.TrimSpace(prefix)))
+		if kind.valid() && kind != KindAny {
+			return NewEntity(kind, value, "")
+		}
+	}
+	return NewEntity(Infer(s), s, "")
+}
+
+// NewEntity creates an entity, validating CPR and CVR numbers and normalising them to their digits
+func NewEntity(kind Kind, value, subject string) (Entity, error) {
+	kind = Kind(strings.ToLower(string(kind)))
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Entity{}, fmt.Errorf("watchlist entity of kind %q has no value", kind)
+	}
+	if !kind.valid() {
+		return Entity{}, fmt.Errorf("unknown watchlist kind %q for %q", kind, value)
+	}
+
+	switch kind {
+	case KindCPR:
+		cpr, err := ident.ParseCPR(value)
+		if err != nil {
+			return Entity{}, err
+		}
+		value = cpr.Digits()
+	case KindCVR:
+		cvr, err := ident.ParseCVR(value)
+		if err != nil {
+			return Entity{}, err
+		}
+		value = cvr.String()
+	}
+
+	return Entity{Kind: kind, Value: value, Subject: subject}, nil
+}
+
+// Infer guesses the kind of an entity from its value. Values that don't clearly
+// look like one of the kinds are left unspecified, so every strategy is tried.
+func Infer(value string) Kind {
+	value = strings.TrimSpace(value)
+	lower := strings.ToLower(value)
+
+	if _, err := ident.ParseCPR(value); err == nil {
+		return KindCPR
+	}
+	if _, err := ident.ParseCVR(value); err == nil {
+		return KindCVR
+	}
+	if strings.Contains(lower, "matr") || strings.Contains(lower, "ejerlav") {
+		return KindMatrikel
+	}
+	for _, word := range strings.Fields(lower) {
+		for _, suffix := range companySuffixes {
+			if strings.Trim(word, ",") == suffix {
+				return KindCompany
+			}
+		}
+	}
+
+	hasLetter, hasDigit := false, false
+	for _, r := range value {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if hasLetter && hasDigit {
+		return KindAddress
+	}
+	if hasLetter && !hasDigit && len(strings.Fields(value)) >= 2 {
+		return KindPerson
+	}
+	return KindAny
+}
+
+// valid reports whether the kind is known
+func (k Kind) valid() bool {
+	if k == KindAny {
+		return true
+	}
+	for _, kind := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// FromStrings creates a watchlist from plain values, inferring the kind of each.
+// Empty values are skipped.
+func FromStrings(values []string) Watchlist {
+	watchlist := make(Watchlist, 0, len(values))
+	for _, value := range values {
+		entity, err := NewEntity(Infer(value), value, "")
+		if err != nil {
+			continue
+		}
+		watchlist = append(watchlist, entity)
+	}
+	return watchlist
+}
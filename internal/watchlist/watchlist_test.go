@@ -0,0 +1,113 @@
+package watchlist
+
+import (
+	"testing"
+)
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Kind
+	}{
+		{value: "0605410146", expected: KindCPR},
+		{value: "060541-0146", expected: KindCPR},
+		{value: "39293056", expected: KindCVR},
+		{value: "DK39293056", expected: KindCVR},
+		{value: "ACEZONE ApS", expected: KindCompany},
+		{value: "Danske Bank A/S", expected: KindCompany},
+		{value: "Lægårdsvej 12A", expected: KindAddress},
+		{value: "Matr.nr. 12a Nykøbing", expected: KindMatrikel},
+		{value: "Benny Gotfred Schmidt", expected: KindPerson},
+		{value: "12345678", expected: KindAny}, // Not a valid CVR number
+		{value: "pikkemand", expected: KindAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := Infer(tt.value); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	input := `[
+		"Benny Gotfred Schmidt",
+		"cvr:DK39293056",
+		{"kind": "address", "value": "Lægårdsvej 12A"},
+		{"subject": "Client A", "prompt": "konkurs", "entities": [
+			"Jette Fries Lundsted",
+			{"kind": "cpr", "value": "080162-0450", "prompt": "doedsbo"}
+		]}
+	]`
+
+	watchlist, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := Watchlist{
+		{Kind: KindPerson, Value: "Benny Gotfred Schmidt"},
+		{Kind: KindCVR, Value: "39293056"},
+		{Kind: KindAddress, Value: "Lægårdsvej 12A"},
+		{Kind: KindPerson, Value: "Jette Fries Lundsted", Subject: "Client A", Prompt: "konkurs"},
+		{Kind: KindCPR, Value: "0801620450", Subject: "Client A", Prompt: "doedsbo"},
+	}
+	if len(watchlist) != len(expected) {
+		t.Fatalf("Expected %d entities, got %d: %+v", len(expected), len(watchlist), watchlist)
+	}
+	for i := range expected {
+		if watchlist[i] != expected[i] {
+			t.Errorf("Entity %d: expected %+v, got %+v", i, expected[i], watchlist[i])
+		}
+	}
+
+	if subjects := watchlist.Subjects(); len(subjects) != 1 || subjects[0] != "Client A" {
+		t.Errorf("Expected subject Client A, got %v", subjects)
+	}
+	if client := watchlist.Subject("Client A"); len(client) != 2 {
+		t.Errorf("Expected 2 entities for Client A, got %d", len(client))
+	}
+
+	// Entities take the subject's prompt template unless they name their own
+	if prompts := watchlist.Prompts(); len(prompts) != 3 || prompts[0] != "" || prompts[1] != "konkurs" || prompts[2] != "doedsbo" {
+		t.Errorf("Expected the default, konkurs and doedsbo prompts, got %q", prompts)
+	}
+	if defaults := watchlist.Prompt(""); len(defaults) != 3 {
+		t.Errorf("Expected 3 entities with the default prompt, got %d", len(defaults))
+	}
+}
+
+func TestParse_CommaSeparated(t *testing.T) {
+	watchlist, err := Parse("Benny Gotfred Schmidt, 0605410146")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(watchlist) != 2 || watchlist[1].Kind != KindCPR || watchlist[1].Value != "0605410146" {
+		t.Errorf("Unexpected watchlist: %+v", watchlist)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "invalid JSON", input: `["unterminated`},
+		{name: "unknown kind", input: `[{"kind": "ship", "value": "Titanic"}]`},
+		{name: "invalid CPR", input: `[{"kind": "cpr", "value": "3102410146"}]`},
+		{name: "invalid CVR prefix", input: `cvr:12345678`},
+		{name: "empty value", input: `[{"kind": "person", "value": " "}]`},
+		{name: "subject without name", input: `[{"entities": ["Jette Fries Lundsted"]}]`},
+		{name: "nested subjects", input: `[{"subject": "A", "entities": [{"subject": "B", "entities": []}]}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.input); err == nil {
+				t.Errorf("Expected an error for %s", tt.input)
+			}
+		})
+	}
+}